@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+type fieldKind int
+
+const (
+	kindOther fieldKind = iota // encoded by the reflective codecs
+	kindString
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindSlice  // slice of one of the kinds above
+	kindStruct // struct type generated in the same run
+)
+
+type fieldType struct {
+	kind   fieldKind
+	goType string
+	bits   int
+	elem   *fieldType
+}
+
+type genField struct {
+	goName   string
+	jsonName string
+	tomlName string
+	jsonSkip bool
+	tomlSkip bool
+	jsonOmit omit
+	tomlOmit omit
+	aliases  []string
+	typ      fieldType
+}
+
+// omit holds the omitempty and omitzero options of a field.
+type omit struct {
+	empty, zero bool
+}
+
+type genType struct {
+	name   string
+	fields []genField
+}
+
+type generator struct {
+	pkg     string
+	types   []genType
+	formats map[string]bool
+
+	// skipped explains for each type left to reflection why no methods
+	// were generated for it.
+	skipped []string
+}
+
+var basicTypes = map[string]fieldType{
+	"string":  {kind: kindString, goType: "string"},
+	"bool":    {kind: kindBool, goType: "bool"},
+	"int":     {kind: kindInt, goType: "int", bits: 64},
+	"int8":    {kind: kindInt, goType: "int8", bits: 8},
+	"int16":   {kind: kindInt, goType: "int16", bits: 16},
+	"int32":   {kind: kindInt, goType: "int32", bits: 32},
+	"rune":    {kind: kindInt, goType: "rune", bits: 32},
+	"int64":   {kind: kindInt, goType: "int64", bits: 64},
+	"uint":    {kind: kindUint, goType: "uint", bits: 64},
+	"uint8":   {kind: kindUint, goType: "uint8", bits: 8},
+	"byte":    {kind: kindUint, goType: "byte", bits: 8},
+	"uint16":  {kind: kindUint, goType: "uint16", bits: 16},
+	"uint32":  {kind: kindUint, goType: "uint32", bits: 32},
+	"uint64":  {kind: kindUint, goType: "uint64", bits: 64},
+	"float32": {kind: kindFloat, goType: "float32", bits: 32},
+	"float64": {kind: kindFloat, goType: "float64", bits: 64},
+}
+
+// parseFile collects the struct types to generate. With no names given,
+// every struct type declared in the file is used.
+func parseFile(filename string, src any, names []string, formats []string) (*generator, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := make(map[string]*ast.StructType)
+	var order []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok && ts.TypeParams == nil {
+				structs[ts.Name.Name] = st
+				order = append(order, ts.Name.Name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		names = order
+	}
+
+	g := &generator{pkg: file.Name.Name, formats: make(map[string]bool)}
+	for _, f := range formats {
+		g.formats[f] = true
+	}
+
+	// Types whose fields use tag options the generated code does not
+	// implement are left to reflection, as are fields of their type.
+	selected := make(map[string]bool)
+	var generated []string
+	for _, name := range names {
+		st, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: struct type %s not found", filename, name)
+		}
+		if reason := unsupportedOptions(st); reason != "" {
+			g.skipped = append(g.skipped, fmt.Sprintf("%s: %s", name, reason))
+			continue
+		}
+		selected[name] = true
+		generated = append(generated, name)
+	}
+
+	for _, name := range generated {
+		t := genType{name: name}
+		for _, field := range structs[name].Fields.List {
+			if len(field.Names) == 0 {
+				return nil, fmt.Errorf("%s: embedded field %s in %s is not supported", filename, exprString(field.Type), name)
+			}
+
+			var tag reflect.StructTag
+			if field.Tag != nil {
+				unquoted, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					return nil, err
+				}
+				tag = reflect.StructTag(unquoted)
+			}
+
+			typ := classify(field.Type, selected)
+			for _, ident := range field.Names {
+				if !ident.IsExported() {
+					continue
+				}
+				f := genField{goName: ident.Name, typ: typ, jsonOmit: omitOptions(tag.Get("json")), tomlOmit: omitOptions(tag.Get("toml"))}
+				if alias := tag.Get("alias"); alias != "" {
+					f.aliases = strings.Split(alias, ",")
+				}
+				f.jsonName, f.jsonSkip = tagName(tag.Get("json"), ident.Name)
+				f.tomlName, f.tomlSkip = tagName(tag.Get("toml"), ident.Name)
+				t.fields = append(t.fields, f)
+			}
+		}
+		g.types = append(g.types, t)
+	}
+
+	return g, nil
+}
+
+// unsupportedOptions describes the first exported field of st with a tag
+// option other than omitempty and omitzero, or returns "".
+func unsupportedOptions(st *ast.StructType) string {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		unquoted, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		tag := reflect.StructTag(unquoted)
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			for _, key := range []string{"json", "toml"} {
+				_, opts, _ := strings.Cut(tag.Get(key), ",")
+				for _, opt := range strings.Split(opts, ",") {
+					if opt != "" && opt != "omitempty" && opt != "omitzero" {
+						return fmt.Sprintf("tag option %q of field %s is not supported", opt, ident.Name)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// omitOptions returns the omitempty and omitzero options of a tag.
+func omitOptions(tag string) omit {
+	_, opts, _ := strings.Cut(tag, ",")
+	list := strings.Split(opts, ",")
+	return omit{empty: slices.Contains(list, "omitempty"), zero: slices.Contains(list, "omitzero")}
+}
+
+func classify(expr ast.Expr, generated map[string]bool) fieldType {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if basic, ok := basicTypes[t.Name]; ok {
+			return basic
+		}
+		if generated[t.Name] {
+			return fieldType{kind: kindStruct, goType: t.Name}
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		if ident, ok := t.Elt.(*ast.Ident); ok {
+			if basic, ok := basicTypes[ident.Name]; ok {
+				return fieldType{kind: kindSlice, goType: "[]" + ident.Name, elem: &basic}
+			}
+		}
+	}
+	return fieldType{kind: kindOther}
+}
+
+// tagName mirrors how the codecs name fields: the tag name when present
+// and the Go field name otherwise. skip is set for "-".
+func tagName(tag, fieldName string) (name string, skip bool) {
+	if tag == "-" {
+		return "", true
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, false
+	}
+	return fieldName, false
+}
+
+func exprString(expr ast.Expr) string {
+	var b bytes.Buffer
+	format.Node(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+func (g *generator) generate() ([]byte, error) {
+	var body bytes.Buffer
+	for _, t := range g.types {
+		if g.formats["json"] {
+			g.jsonMethods(&body, t)
+		}
+		if g.formats["toml"] {
+			g.tomlMethods(&body, t)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by serializer-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n", g.pkg)
+	var imports bytes.Buffer
+	if bytes.Contains(body.Bytes(), []byte("strconv.")) {
+		imports.WriteString("\t\"strconv\"\n\n")
+	}
+	for _, pkg := range []string{"json", "toml"} {
+		if bytes.Contains(body.Bytes(), []byte(pkg+".")) {
+			fmt.Fprintf(&imports, "\t\"github.com/saneechka/serializer/%s\"\n", pkg)
+		}
+	}
+	if imports.Len() > 0 {
+		fmt.Fprintf(&out, "\nimport (\n%s)\n", imports.Bytes())
+	}
+	out.Write(body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, out.Bytes())
+	}
+	return formatted, nil
+}
+
+// convert returns expr converted from type from to type to.
+func convert(to, from, expr string) string {
+	if to == from {
+		return expr
+	}
+	return to + "(" + expr + ")"
+}
+
+// present returns the condition under which expr, of type t, is encoded
+// given the omit options of its field in the format pkg, or "" if it
+// always is. As in the reflective encoders, empty means false, 0, "" or
+// an empty slice, and zero the zero value or what an IsZero method
+// reports.
+func present(pkg string, t fieldType, expr string, o omit) string {
+	if !o.empty && !o.zero {
+		return ""
+	}
+	switch t.kind {
+	case kindString:
+		return expr + ` != ""`
+	case kindBool:
+		return expr
+	case kindInt, kindUint, kindFloat:
+		return expr + " != 0"
+	case kindSlice:
+		if o.empty {
+			return "len(" + expr + ") != 0"
+		}
+		return expr + " != nil"
+	}
+	var conds []string
+	if o.empty {
+		conds = append(conds, fmt.Sprintf("!%s.IsEmpty(&%s)", pkg, expr))
+	}
+	if o.zero {
+		conds = append(conds, fmt.Sprintf("!%s.IsZero(&%s)", pkg, expr))
+	}
+	return strings.Join(conds, " && ")
+}
+
+// enter opens the value in a generated encoder, checking the depth.
+const enter = "if err := w.Enter(); err != nil {\nreturn nil, err\n}\n"
+
+// withErr declares err only when the function body assigns it.
+func withErr(body string) string {
+	if strings.Contains(body, ", err = ") {
+		return "var err error\n" + body
+	}
+	return body
+}
+
+func (g *generator) jsonMethods(w *bytes.Buffer, t genType) {
+	// Keys carry the separator before them while it is known when the code
+	// is generated. Once a field that may be omitted comes first, n marks
+	// the start of the members and the comma is decided at run time.
+	var enc bytes.Buffer
+	sep := "{"
+	for _, f := range t.fields {
+		if f.jsonSkip {
+			continue
+		}
+		expr := "x." + f.goName
+		cond := present("json", f.typ, expr, f.jsonOmit)
+		if cond != "" && sep == "{" {
+			enc.WriteString("buf = append(buf, '{')\nn := len(buf)\n")
+			sep = ""
+		}
+		if cond != "" {
+			fmt.Fprintf(&enc, "if %s {\n", cond)
+		}
+		if sep == "" {
+			enc.WriteString("if len(buf) > n {\nbuf = append(buf, ',')\n}\n")
+		}
+		key := append([]byte(sep), json.AppendString(nil, f.jsonName)...)
+		fmt.Fprintf(&enc, "buf = append(buf, %s...)\n", strconv.Quote(string(key)+":"))
+		jsonAppend(&enc, f.typ, expr, f.jsonName)
+		if cond != "" {
+			enc.WriteString("}\n")
+		} else {
+			sep = ","
+		}
+	}
+	if sep == "{" {
+		enc.WriteString("buf = append(buf, '{')\n")
+	}
+	enc.WriteString("w.Leave()\nbuf = append(buf, '}')\nreturn buf, nil\n")
+
+	fmt.Fprintf(w, "\n// MarshalJSON implements json.Marshaler.\nfunc (x %s) MarshalJSON() ([]byte, error) {\nreturn x.AppendJSON(nil)\n}\n", t.name)
+	fmt.Fprintf(w, "\n// AppendJSON implements json.Appender.\nfunc (x %s) AppendJSON(buf []byte) ([]byte, error) {\nreturn x.EncodeJSON(json.NewWriter(), buf)\n}\n", t.name)
+	fmt.Fprintf(w, "\n// EncodeJSON implements json.Encoder.\nfunc (x %s) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {\n%s%s}\n", t.name, enter, withErr(enc.String()))
+
+	fmt.Fprintf(w, "\n// UnmarshalJSON implements json.Unmarshaler.\nfunc (x *%s) UnmarshalJSON(data []byte) error {\nreturn x.DecodeJSON(json.NewReader(data))\n}\n", t.name)
+	fmt.Fprintf(w, "\n// DecodeJSON implements json.Decoder.\nfunc (x *%s) DecodeJSON(r *json.Reader) error {\n", t.name)
+	fmt.Fprintf(w, "if r.Null() {\n*x = %s{}\nreturn nil\n}\n", t.name)
+	fmt.Fprintf(w, "return r.Object(func(key []byte) error {\nname, err := r.Key(key, jsonKeys%s)\nif err != nil {\nreturn err\n}\nswitch name {\n", t.name)
+	for _, f := range t.fields {
+		if f.jsonSkip {
+			continue
+		}
+		fmt.Fprintf(w, "case %q:\n", f.jsonName)
+		jsonDecode(w, f.typ, "x."+f.goName)
+	}
+	w.WriteString("}\nreturn r.UnknownField()\n})\n}\n")
+	writeKeys(w, "json", t, func(f genField) (string, bool) { return f.jsonName, f.jsonSkip })
+}
+
+// jsonAppend writes code appending expr, the value of the field name.
+// Errors are returned with name added to their field path.
+func jsonAppend(w *bytes.Buffer, t fieldType, expr, name string) {
+	fail := fmt.Sprintf("json.WithField(err, %q)", name)
+	switch t.kind {
+	case kindSlice:
+		if t.elem.bits == 8 && t.elem.kind == kindUint {
+			fmt.Fprintf(w, "buf = w.Bytes(buf, %s)\n", expr)
+			return
+		}
+		fmt.Fprintf(w, "if %s == nil {\nbuf = append(buf, \"null\"...)\n} else {\n", expr)
+		fmt.Fprintf(w, "if err := w.Enter(); err != nil {\nreturn nil, %s\n}\nbuf = append(buf, '[')\n", fail)
+		fmt.Fprintf(w, "for i, v := range %s {\nif i > 0 {\nbuf = append(buf, ',')\n}\n", expr)
+		jsonAppendBasic(w, *t.elem, "v", fmt.Sprintf("json.WithField(json.WithField(err, strconv.Itoa(i)), %q)", name))
+		w.WriteString("}\nw.Leave()\nbuf = append(buf, ']')\n}\n")
+	case kindStruct:
+		fmt.Fprintf(w, "if buf, err = %s.EncodeJSON(w, buf); err != nil {\nreturn nil, %s\n}\n", expr, fail)
+	case kindOther:
+		fmt.Fprintf(w, "if buf, err = w.Value(buf, %s); err != nil {\nreturn nil, %s\n}\n", expr, fail)
+	default:
+		jsonAppendBasic(w, t, expr, fail)
+	}
+}
+
+// jsonAppendBasic writes code appending expr of a basic kind, returning
+// fail on error.
+func jsonAppendBasic(w *bytes.Buffer, t fieldType, expr, fail string) {
+	switch t.kind {
+	case kindString:
+		fmt.Fprintf(w, "if buf, err = w.String(buf, %s); err != nil {\nreturn nil, %s\n}\n", expr, fail)
+	case kindBool:
+		fmt.Fprintf(w, "buf = strconv.AppendBool(buf, %s)\n", expr)
+	case kindInt:
+		fmt.Fprintf(w, "buf = strconv.AppendInt(buf, %s, 10)\n", convert("int64", t.goType, expr))
+	case kindUint:
+		fmt.Fprintf(w, "buf = strconv.AppendUint(buf, %s, 10)\n", convert("uint64", t.goType, expr))
+	case kindFloat:
+		fmt.Fprintf(w, "if buf, err = w.Float(buf, %s, %d); err != nil {\nreturn nil, %s\n}\n", convert("float64", t.goType, expr), t.bits, fail)
+	}
+}
+
+var jsonReaders = map[fieldKind]string{
+	kindString: "String",
+	kindBool:   "Bool",
+	kindInt:    "Int",
+	kindUint:   "Uint",
+	kindFloat:  "Float",
+}
+
+// jsonReader returns the Reader call decoding a value of type t. Sized
+// numbers use the Bits variants, which check the range.
+func jsonReader(t fieldType) string {
+	return jsonReaders[t.kind] + bitsArgs(t, "")
+}
+
+// bitsArgs returns the argument list for the decoder of t, with args
+// first, choosing the Bits variant for numbers narrower than 64 bits.
+func bitsArgs(t fieldType, args string) string {
+	if t.kind != kindInt && t.kind != kindUint && t.kind != kindFloat {
+		return "(" + args + ")"
+	}
+	if args != "" {
+		args += ", "
+	}
+	switch {
+	case t.goType == "int" || t.goType == "uint":
+		return "Bits(" + args + "strconv.IntSize)"
+	case t.bits < 64:
+		return fmt.Sprintf("Bits(%s%d)", args, t.bits)
+	}
+	return "(" + strings.TrimSuffix(args, ", ") + ")"
+}
+
+// decodedTypes are the types returned by the Reader methods and the toml
+// Decode functions for each kind.
+var decodedTypes = map[fieldKind]string{
+	kindString: "string",
+	kindBool:   "bool",
+	kindInt:    "int64",
+	kindUint:   "uint64",
+	kindFloat:  "float64",
+}
+
+// jsonDecode writes a case body reading one value into expr.
+func jsonDecode(w *bytes.Buffer, t fieldType, expr string) {
+	switch t.kind {
+	case kindString, kindBool, kindInt, kindUint, kindFloat:
+		fmt.Fprintf(w, "v, err := r.%s\nif err != nil {\nreturn err\n}\n%s = %s\nreturn nil\n", jsonReader(t), expr, convert(t.goType, decodedTypes[t.kind], "v"))
+	case kindSlice:
+		if t.elem.bits == 8 && t.elem.kind == kindUint {
+			fmt.Fprintf(w, "v, err := r.Bytes()\nif err != nil {\nreturn err\n}\n%s = v\nreturn nil\n", expr)
+			return
+		}
+		fmt.Fprintf(w, "if r.Null() {\n%s = nil\nreturn nil\n}\n", expr)
+		fmt.Fprintf(w, "keep, i := r.Slice(len(%s))\ns := %s[:keep:keep]\nerr := r.Array(func() error {\n", expr, expr)
+		fmt.Fprintf(w, "v, err := r.%s\nif err != nil {\nreturn err\n}\n", jsonReader(*t.elem))
+		fmt.Fprintf(w, "if i < len(s) {\ns[i] = %s\n} else {\ns = append(s, %s)\n}\ni++\nreturn nil\n})\n", convert(t.elem.goType, decodedTypes[t.elem.kind], "v"), convert(t.elem.goType, decodedTypes[t.elem.kind], "v"))
+		fmt.Fprintf(w, "if s == nil {\ns = make(%s, 0)\n}\n%s = s\nreturn err\n", t.goType, expr)
+	case kindStruct:
+		fmt.Fprintf(w, "return %s.DecodeJSON(r)\n", expr)
+	default:
+		fmt.Fprintf(w, "return r.Decode(&%s)\n", expr)
+	}
+}
+
+func (g *generator) tomlMethods(w *bytes.Buffer, t genType) {
+	var allInline, allTables, allArrays bytes.Buffer
+	for _, f := range t.fields {
+		if f.tomlSkip {
+			continue
+		}
+		expr := "x." + f.goName
+		key := string(toml.AppendKey(nil, f.tomlName))
+		fail := fmt.Sprintf("toml.WithField(err, %q)", f.tomlName)
+		var inline, tables, arrays bytes.Buffer
+		switch f.typ.kind {
+		case kindStruct:
+			fmt.Fprintf(&tables, "{\nsub := append(path[:len(path):len(path)], %q)\nbuf = toml.AppendHeader(buf, sub)\n", f.tomlName)
+			fmt.Fprintf(&tables, "if buf, err = %s.EncodeTOML(w, buf, sub); err != nil {\nreturn nil, %s\n}\n}\n", expr, fail)
+		case kindOther:
+			fmt.Fprintf(&inline, "if !toml.IsTable(%s) && !toml.IsArrayOfTables(%s) {\n", expr, expr)
+			fmt.Fprintf(&inline, "if buf, err = w.KeyValue(buf, %q, %s); err != nil {\nreturn nil, %s\n}\n}\n", f.tomlName, expr, fail)
+			fmt.Fprintf(&tables, "if toml.IsTable(%s) {\n", expr)
+			fmt.Fprintf(&tables, "if buf, err = w.Table(buf, append(path[:len(path):len(path)], %q), %s); err != nil {\nreturn nil, %s\n}\n}\n", f.tomlName, expr, fail)
+			fmt.Fprintf(&arrays, "if toml.IsArrayOfTables(%s) {\n", expr)
+			fmt.Fprintf(&arrays, "if buf, err = w.Table(buf, append(path[:len(path):len(path)], %q), %s); err != nil {\nreturn nil, %s\n}\n}\n", f.tomlName, expr, fail)
+		case kindSlice:
+			fmt.Fprintf(&inline, "if %s != nil {\nif err := w.Enter(); err != nil {\nreturn nil, %s\n}\nbuf = append(buf, %q...)\n", expr, fail, key+" = [")
+			fmt.Fprintf(&inline, "for i, v := range %s {\nif i > 0 {\nbuf = append(buf, \", \"...)\n}\n", expr)
+			tomlAppend(&inline, *f.typ.elem, "v", fmt.Sprintf("toml.WithField(toml.WithField(err, strconv.Itoa(i)), %q)", f.tomlName))
+			inline.WriteString("}\nw.Leave()\nbuf = append(buf, \"]\\n\"...)\n}\n")
+		default:
+			fmt.Fprintf(&inline, "buf = append(buf, %q...)\n", key+" = ")
+			tomlAppend(&inline, f.typ, expr, fail)
+			inline.WriteString("buf = append(buf, '\\n')\n")
+		}
+		cond := present("toml", f.typ, expr, f.tomlOmit)
+		for _, part := range []struct{ all, code *bytes.Buffer }{{&allInline, &inline}, {&allTables, &tables}, {&allArrays, &arrays}} {
+			switch {
+			case part.code.Len() == 0:
+			case cond == "":
+				part.all.Write(part.code.Bytes())
+			default:
+				fmt.Fprintf(part.all, "if %s {\n%s}\n", cond, part.code.Bytes())
+			}
+		}
+	}
+	body := allInline.String() + allTables.String() + allArrays.String() + "w.Leave()\nreturn buf, nil\n"
+
+	fmt.Fprintf(w, "\n// MarshalTOML implements toml.Marshaler.\nfunc (x %s) MarshalTOML() ([]byte, error) {\nreturn x.AppendTOML(nil, nil)\n}\n", t.name)
+	fmt.Fprintf(w, "\n// AppendTOML implements toml.Appender.\nfunc (x %s) AppendTOML(buf []byte, path []string) ([]byte, error) {\nreturn x.EncodeTOML(toml.NewWriter(), buf, path)\n}\n", t.name)
+	fmt.Fprintf(w, "\n// EncodeTOML implements toml.Encoder.\nfunc (x %s) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {\n%s%s}\n", t.name, enter, withErr(body))
+
+	fmt.Fprintf(w, "\n// UnmarshalTOML implements toml.Unmarshaler.\nfunc (x *%s) UnmarshalTOML(value interface{}) error {\nreturn x.DecodeTOML(toml.NewReader(), value)\n}\n", t.name)
+	fmt.Fprintf(w, "\n// DecodeTOML implements toml.Decoder.\nfunc (x *%s) DecodeTOML(r *toml.Reader, value interface{}) error {\n", t.name)
+	fmt.Fprintf(w, "if value == nil {\n*x = %s{}\nreturn nil\n}\n", t.name)
+	fmt.Fprintf(w, "table, err := r.Table(value, tomlKeys%s)\nif err != nil {\nreturn err\n}\n", t.name)
+	for _, f := range t.fields {
+		if f.tomlSkip {
+			continue
+		}
+		expr := "x." + f.goName
+		fmt.Fprintf(w, "if v, ok := table[%q]; ok {\nif err := r.Field(%q, &%s, func() error {\n", f.tomlName, f.tomlName, expr)
+		tomlDecode(w, f.typ, expr)
+		w.WriteString("}); err != nil {\nreturn err\n}\n}\n")
+	}
+	w.WriteString("return nil\n}\n")
+	writeKeys(w, "toml", t, func(f genField) (string, bool) { return f.tomlName, f.tomlSkip })
+}
+
+// writeKeys declares the Keys that the decoder of t matches object keys
+// against, listing each field name with its aliases.
+func writeKeys(w *bytes.Buffer, pkg string, t genType, name func(genField) (string, bool)) {
+	fmt.Fprintf(w, "\nvar %sKeys%s = %s.NewKeys(\n", pkg, t.name, pkg)
+	for _, f := range t.fields {
+		n, skip := name(f)
+		if skip {
+			continue
+		}
+		names := []string{strconv.Quote(n)}
+		for _, alias := range f.aliases {
+			names = append(names, strconv.Quote(alias))
+		}
+		fmt.Fprintf(w, "[]string{%s},\n", strings.Join(names, ", "))
+	}
+	w.WriteString(")\n")
+}
+
+// tomlAppend writes code appending expr of a basic kind, returning fail
+// on error.
+func tomlAppend(w *bytes.Buffer, t fieldType, expr, fail string) {
+	switch t.kind {
+	case kindString:
+		fmt.Fprintf(w, "buf = toml.AppendString(buf, %s)\n", expr)
+	case kindBool:
+		fmt.Fprintf(w, "buf = strconv.AppendBool(buf, %s)\n", expr)
+	case kindInt:
+		fmt.Fprintf(w, "buf = strconv.AppendInt(buf, %s, 10)\n", convert("int64", t.goType, expr))
+	case kindUint:
+		fmt.Fprintf(w, "buf = strconv.AppendUint(buf, %s, 10)\n", convert("uint64", t.goType, expr))
+	case kindFloat:
+		fmt.Fprintf(w, "if buf, err = toml.AppendFloat(buf, %s, %d); err != nil {\nreturn nil, %s\n}\n", convert("float64", t.goType, expr), t.bits, fail)
+	}
+}
+
+var tomlDecoders = map[fieldKind]string{
+	kindString: "DecodeString",
+	kindBool:   "DecodeBool",
+	kindInt:    "DecodeInt",
+	kindUint:   "DecodeUint",
+	kindFloat:  "DecodeFloat",
+}
+
+// zeroValues are the literals appended to a slice of each kind before an
+// element is decoded into it.
+var zeroValues = map[fieldKind]string{
+	kindString: `""`,
+	kindBool:   "false",
+	kindInt:    "0",
+	kindUint:   "0",
+	kindFloat:  "0",
+}
+
+// tomlDecode writes the body of a function storing the parsed value v
+// into expr.
+func tomlDecode(w *bytes.Buffer, t fieldType, expr string) {
+	switch t.kind {
+	case kindString, kindBool, kindInt, kindUint, kindFloat:
+		fmt.Fprintf(w, "d, err := toml.%s%s\nif err != nil {\nreturn err\n}\n%s = %s\nreturn nil\n", tomlDecoders[t.kind], bitsArgs(t, "v"), expr, convert(t.goType, decodedTypes[t.kind], "d"))
+	case kindSlice:
+		fmt.Fprintf(w, "if v == nil {\n%s = nil\nreturn nil\n}\n", expr)
+		w.WriteString("arr, err := toml.DecodeArray(v)\nif err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(w, "keep, start := r.Slice(len(%s))\ns := %s[:keep:keep]\nfor i, e := range arr {\n", expr, expr)
+		fmt.Fprintf(w, "j := start + i\nif j == len(s) {\ns = append(s, %s)\n}\nif err := r.Element(i, &s[j], func() error {\n", zeroValues[t.elem.kind])
+		fmt.Fprintf(w, "d, err := toml.%s%s\nif err != nil {\nreturn err\n}\ns[j] = %s\nreturn nil\n", tomlDecoders[t.elem.kind], bitsArgs(*t.elem, "e"), convert(t.elem.goType, decodedTypes[t.elem.kind], "d"))
+		fmt.Fprintf(w, "}); err != nil {\nreturn err\n}\n}\nif s == nil {\ns = make(%s, 0)\n}\n%s = s\nreturn nil\n", t.goType, expr)
+	case kindStruct:
+		fmt.Fprintf(w, "return %s.DecodeTOML(r, v)\n", expr)
+	default:
+		fmt.Fprintf(w, "return r.Decode(v, &%s)\n", expr)
+	}
+}
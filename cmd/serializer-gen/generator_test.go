@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedUpToDate regenerates the example package and compares the
+// result with the committed file.
+func TestGeneratedUpToDate(t *testing.T) {
+	g, err := parseFile("internal/example/example.go", nil, []string{"Order", "Customer", "Line", "Node", "Profile"}, []string{"json", "toml"})
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	got, err := g.generate()
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	want, err := os.ReadFile("internal/example/example_serializer.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Error("example_serializer.go устарел, запустите go generate ./cmd/serializer-gen/...")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		types []string
+		want  string
+	}{
+		{
+			name: "встроенное поле",
+			src:  "package p\ntype Base struct{ ID int }\ntype T struct {\n\tBase\n}\n",
+			want: "embedded field Base",
+		},
+		{
+			name:  "неизвестный тип",
+			src:   "package p\ntype T struct{ ID int }\n",
+			types: []string{"Missing"},
+			want:  "struct type Missing not found",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := parseFile("p.go", tt.src, tt.types, []string{"json"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestGenerateFormats(t *testing.T) {
+	g, err := parseFile("p.go", "package p\ntype T struct {\n\tName string `json:\"name\"`\n\tSkip int `json:\"-\"`\n}\n", nil, []string{"json"})
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	src, err := g.generate()
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	code := string(src)
+	if strings.Contains(code, "serializer/toml") || strings.Contains(code, "MarshalTOML") || strings.Contains(code, "strconv") {
+		t.Errorf("лишние импорты или методы для TOML:\n%s", code)
+	}
+	if strings.Contains(code, "x.Skip") {
+		t.Errorf("поле с тегом \"-\" не должно попадать в код:\n%s", code)
+	}
+	if !strings.Contains(code, "func (x *T) DecodeJSON(r *json.Reader) error") {
+		t.Errorf("нет метода DecodeJSON:\n%s", code)
+	}
+}
+
+func TestGenerateTagOptions(t *testing.T) {
+	src := "package p\n" +
+		"type Base struct{ ID int `json:\"id,string\"` }\n" +
+		"type T struct {\n\tB Base\n\tName string `json:\"name,omitempty\"`\n\tTags []string `json:\",omitzero\"`\n}\n"
+	g, err := parseFile("p.go", src, nil, []string{"json"})
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	if want := []string{`Base: tag option "string" of field ID is not supported`}; !reflect.DeepEqual(g.skipped, want) {
+		t.Errorf("skipped = %q, want %q", g.skipped, want)
+	}
+	src2, err := g.generate()
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	code := string(src2)
+	if strings.Contains(code, "func (x Base)") || strings.Contains(code, "jsonKeysBase") {
+		t.Errorf("для Base не должно быть методов:\n%s", code)
+	}
+	for _, want := range []string{"w.Value(buf, x.B)", `if x.Name != "" {`, "if x.Tags != nil {"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("нет %q в коде:\n%s", want, code)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package example holds types used to check that code generated by
+// serializer-gen behaves like the reflective codecs.
+package example
+
+import "time"
+
+//go:generate go run github.com/saneechka/serializer/cmd/serializer-gen -type Order,Customer,Line,Node,Profile
+
+type Order struct {
+	ID       int64             `json:"id" toml:"id"`
+	Status   string            `json:"status" toml:"status"`
+	Paid     bool              `json:"paid" toml:"paid"`
+	Total    float64           `json:"total" toml:"total"`
+	Discount float32           `json:"discount" toml:"discount"`
+	Quantity uint16            `json:"quantity" toml:"quantity"`
+	Tags     []string          `json:"tags" toml:"tags"`
+	Scores   []int             `json:"scores" toml:"scores"`
+	Payload  []byte            `json:"payload" toml:"payload"`
+	Created  time.Time         `json:"created" toml:"created"`
+	Meta     map[string]string `json:"meta" toml:"meta"`
+	Customer Customer          `json:"customer" toml:"customer"`
+	Lines    []Line            `json:"lines" toml:"lines"`
+	Note     *string           `json:"note" toml:"note"`
+	Internal string            `json:"-" toml:"-"`
+	secret   string
+}
+
+type Customer struct {
+	Name  string `json:"name" toml:"name"`
+	Email string `json:"email" toml:"email"`
+}
+
+type Line struct {
+	SKU   string  `json:"sku" toml:"sku" alias:"article"`
+	Price float64 `json:"price" toml:"price"`
+	Count int     `json:"count" toml:"count"`
+}
+
+type Node struct {
+	Name  string     `json:"name" toml:"name"`
+	Value complex128 `json:"value" toml:"value"`
+	Next  *Node      `json:"next" toml:"next"`
+}
+
+// Profile has fields left out of the output when they are empty or zero.
+type Profile struct {
+	Name     string            `json:"name,omitempty" toml:"name,omitempty"`
+	Age      int               `json:"age,omitempty" toml:"age,omitzero"`
+	Score    float64           `json:"score,omitzero" toml:"score,omitempty"`
+	Active   bool              `json:"active,omitempty" toml:"active,omitempty"`
+	Tags     []string          `json:"tags,omitempty" toml:"tags,omitzero"`
+	Joined   time.Time         `json:"joined,omitzero" toml:"joined,omitzero"`
+	Meta     map[string]string `json:"meta,omitempty" toml:"meta,omitempty"`
+	Customer Customer          `json:"customer,omitzero" toml:"customer,omitzero"`
+	Note     *string           `json:"note,omitempty,omitzero" toml:"note,omitempty"`
+}
@@ -0,0 +1,1630 @@
+// Code generated by serializer-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"strconv"
+
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+// MarshalJSON implements json.Marshaler.
+func (x Order) MarshalJSON() ([]byte, error) {
+	return x.AppendJSON(nil)
+}
+
+// AppendJSON implements json.Appender.
+func (x Order) AppendJSON(buf []byte) ([]byte, error) {
+	return x.EncodeJSON(json.NewWriter(), buf)
+}
+
+// EncodeJSON implements json.Encoder.
+func (x Order) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "{\"id\":"...)
+	buf = strconv.AppendInt(buf, x.ID, 10)
+	buf = append(buf, ",\"status\":"...)
+	if buf, err = w.String(buf, x.Status); err != nil {
+		return nil, json.WithField(err, "status")
+	}
+	buf = append(buf, ",\"paid\":"...)
+	buf = strconv.AppendBool(buf, x.Paid)
+	buf = append(buf, ",\"total\":"...)
+	if buf, err = w.Float(buf, x.Total, 64); err != nil {
+		return nil, json.WithField(err, "total")
+	}
+	buf = append(buf, ",\"discount\":"...)
+	if buf, err = w.Float(buf, float64(x.Discount), 32); err != nil {
+		return nil, json.WithField(err, "discount")
+	}
+	buf = append(buf, ",\"quantity\":"...)
+	buf = strconv.AppendUint(buf, uint64(x.Quantity), 10)
+	buf = append(buf, ",\"tags\":"...)
+	if x.Tags == nil {
+		buf = append(buf, "null"...)
+	} else {
+		if err := w.Enter(); err != nil {
+			return nil, json.WithField(err, "tags")
+		}
+		buf = append(buf, '[')
+		for i, v := range x.Tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			if buf, err = w.String(buf, v); err != nil {
+				return nil, json.WithField(json.WithField(err, strconv.Itoa(i)), "tags")
+			}
+		}
+		w.Leave()
+		buf = append(buf, ']')
+	}
+	buf = append(buf, ",\"scores\":"...)
+	if x.Scores == nil {
+		buf = append(buf, "null"...)
+	} else {
+		if err := w.Enter(); err != nil {
+			return nil, json.WithField(err, "scores")
+		}
+		buf = append(buf, '[')
+		for i, v := range x.Scores {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = strconv.AppendInt(buf, int64(v), 10)
+		}
+		w.Leave()
+		buf = append(buf, ']')
+	}
+	buf = append(buf, ",\"payload\":"...)
+	buf = w.Bytes(buf, x.Payload)
+	buf = append(buf, ",\"created\":"...)
+	if buf, err = w.Value(buf, x.Created); err != nil {
+		return nil, json.WithField(err, "created")
+	}
+	buf = append(buf, ",\"meta\":"...)
+	if buf, err = w.Value(buf, x.Meta); err != nil {
+		return nil, json.WithField(err, "meta")
+	}
+	buf = append(buf, ",\"customer\":"...)
+	if buf, err = x.Customer.EncodeJSON(w, buf); err != nil {
+		return nil, json.WithField(err, "customer")
+	}
+	buf = append(buf, ",\"lines\":"...)
+	if buf, err = w.Value(buf, x.Lines); err != nil {
+		return nil, json.WithField(err, "lines")
+	}
+	buf = append(buf, ",\"note\":"...)
+	if buf, err = w.Value(buf, x.Note); err != nil {
+		return nil, json.WithField(err, "note")
+	}
+	w.Leave()
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (x *Order) UnmarshalJSON(data []byte) error {
+	return x.DecodeJSON(json.NewReader(data))
+}
+
+// DecodeJSON implements json.Decoder.
+func (x *Order) DecodeJSON(r *json.Reader) error {
+	if r.Null() {
+		*x = Order{}
+		return nil
+	}
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, jsonKeysOrder)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "id":
+			v, err := r.Int()
+			if err != nil {
+				return err
+			}
+			x.ID = v
+			return nil
+		case "status":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.Status = v
+			return nil
+		case "paid":
+			v, err := r.Bool()
+			if err != nil {
+				return err
+			}
+			x.Paid = v
+			return nil
+		case "total":
+			v, err := r.Float()
+			if err != nil {
+				return err
+			}
+			x.Total = v
+			return nil
+		case "discount":
+			v, err := r.FloatBits(32)
+			if err != nil {
+				return err
+			}
+			x.Discount = float32(v)
+			return nil
+		case "quantity":
+			v, err := r.UintBits(16)
+			if err != nil {
+				return err
+			}
+			x.Quantity = uint16(v)
+			return nil
+		case "tags":
+			if r.Null() {
+				x.Tags = nil
+				return nil
+			}
+			keep, i := r.Slice(len(x.Tags))
+			s := x.Tags[:keep:keep]
+			err := r.Array(func() error {
+				v, err := r.String()
+				if err != nil {
+					return err
+				}
+				if i < len(s) {
+					s[i] = v
+				} else {
+					s = append(s, v)
+				}
+				i++
+				return nil
+			})
+			if s == nil {
+				s = make([]string, 0)
+			}
+			x.Tags = s
+			return err
+		case "scores":
+			if r.Null() {
+				x.Scores = nil
+				return nil
+			}
+			keep, i := r.Slice(len(x.Scores))
+			s := x.Scores[:keep:keep]
+			err := r.Array(func() error {
+				v, err := r.IntBits(strconv.IntSize)
+				if err != nil {
+					return err
+				}
+				if i < len(s) {
+					s[i] = int(v)
+				} else {
+					s = append(s, int(v))
+				}
+				i++
+				return nil
+			})
+			if s == nil {
+				s = make([]int, 0)
+			}
+			x.Scores = s
+			return err
+		case "payload":
+			v, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			x.Payload = v
+			return nil
+		case "created":
+			return r.Decode(&x.Created)
+		case "meta":
+			return r.Decode(&x.Meta)
+		case "customer":
+			return x.Customer.DecodeJSON(r)
+		case "lines":
+			return r.Decode(&x.Lines)
+		case "note":
+			return r.Decode(&x.Note)
+		}
+		return r.UnknownField()
+	})
+}
+
+var jsonKeysOrder = json.NewKeys(
+	[]string{"id"},
+	[]string{"status"},
+	[]string{"paid"},
+	[]string{"total"},
+	[]string{"discount"},
+	[]string{"quantity"},
+	[]string{"tags"},
+	[]string{"scores"},
+	[]string{"payload"},
+	[]string{"created"},
+	[]string{"meta"},
+	[]string{"customer"},
+	[]string{"lines"},
+	[]string{"note"},
+)
+
+// MarshalTOML implements toml.Marshaler.
+func (x Order) MarshalTOML() ([]byte, error) {
+	return x.AppendTOML(nil, nil)
+}
+
+// AppendTOML implements toml.Appender.
+func (x Order) AppendTOML(buf []byte, path []string) ([]byte, error) {
+	return x.EncodeTOML(toml.NewWriter(), buf, path)
+}
+
+// EncodeTOML implements toml.Encoder.
+func (x Order) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "id = "...)
+	buf = strconv.AppendInt(buf, x.ID, 10)
+	buf = append(buf, '\n')
+	buf = append(buf, "status = "...)
+	buf = toml.AppendString(buf, x.Status)
+	buf = append(buf, '\n')
+	buf = append(buf, "paid = "...)
+	buf = strconv.AppendBool(buf, x.Paid)
+	buf = append(buf, '\n')
+	buf = append(buf, "total = "...)
+	if buf, err = toml.AppendFloat(buf, x.Total, 64); err != nil {
+		return nil, toml.WithField(err, "total")
+	}
+	buf = append(buf, '\n')
+	buf = append(buf, "discount = "...)
+	if buf, err = toml.AppendFloat(buf, float64(x.Discount), 32); err != nil {
+		return nil, toml.WithField(err, "discount")
+	}
+	buf = append(buf, '\n')
+	buf = append(buf, "quantity = "...)
+	buf = strconv.AppendUint(buf, uint64(x.Quantity), 10)
+	buf = append(buf, '\n')
+	if x.Tags != nil {
+		if err := w.Enter(); err != nil {
+			return nil, toml.WithField(err, "tags")
+		}
+		buf = append(buf, "tags = ["...)
+		for i, v := range x.Tags {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = toml.AppendString(buf, v)
+		}
+		w.Leave()
+		buf = append(buf, "]\n"...)
+	}
+	if x.Scores != nil {
+		if err := w.Enter(); err != nil {
+			return nil, toml.WithField(err, "scores")
+		}
+		buf = append(buf, "scores = ["...)
+		for i, v := range x.Scores {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = strconv.AppendInt(buf, int64(v), 10)
+		}
+		w.Leave()
+		buf = append(buf, "]\n"...)
+	}
+	if x.Payload != nil {
+		if err := w.Enter(); err != nil {
+			return nil, toml.WithField(err, "payload")
+		}
+		buf = append(buf, "payload = ["...)
+		for i, v := range x.Payload {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = strconv.AppendUint(buf, uint64(v), 10)
+		}
+		w.Leave()
+		buf = append(buf, "]\n"...)
+	}
+	if !toml.IsTable(x.Created) && !toml.IsArrayOfTables(x.Created) {
+		if buf, err = w.KeyValue(buf, "created", x.Created); err != nil {
+			return nil, toml.WithField(err, "created")
+		}
+	}
+	if !toml.IsTable(x.Meta) && !toml.IsArrayOfTables(x.Meta) {
+		if buf, err = w.KeyValue(buf, "meta", x.Meta); err != nil {
+			return nil, toml.WithField(err, "meta")
+		}
+	}
+	if !toml.IsTable(x.Lines) && !toml.IsArrayOfTables(x.Lines) {
+		if buf, err = w.KeyValue(buf, "lines", x.Lines); err != nil {
+			return nil, toml.WithField(err, "lines")
+		}
+	}
+	if !toml.IsTable(x.Note) && !toml.IsArrayOfTables(x.Note) {
+		if buf, err = w.KeyValue(buf, "note", x.Note); err != nil {
+			return nil, toml.WithField(err, "note")
+		}
+	}
+	if toml.IsTable(x.Created) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "created"), x.Created); err != nil {
+			return nil, toml.WithField(err, "created")
+		}
+	}
+	if toml.IsTable(x.Meta) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "meta"), x.Meta); err != nil {
+			return nil, toml.WithField(err, "meta")
+		}
+	}
+	{
+		sub := append(path[:len(path):len(path)], "customer")
+		buf = toml.AppendHeader(buf, sub)
+		if buf, err = x.Customer.EncodeTOML(w, buf, sub); err != nil {
+			return nil, toml.WithField(err, "customer")
+		}
+	}
+	if toml.IsTable(x.Lines) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "lines"), x.Lines); err != nil {
+			return nil, toml.WithField(err, "lines")
+		}
+	}
+	if toml.IsTable(x.Note) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "note"), x.Note); err != nil {
+			return nil, toml.WithField(err, "note")
+		}
+	}
+	if toml.IsArrayOfTables(x.Created) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "created"), x.Created); err != nil {
+			return nil, toml.WithField(err, "created")
+		}
+	}
+	if toml.IsArrayOfTables(x.Meta) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "meta"), x.Meta); err != nil {
+			return nil, toml.WithField(err, "meta")
+		}
+	}
+	if toml.IsArrayOfTables(x.Lines) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "lines"), x.Lines); err != nil {
+			return nil, toml.WithField(err, "lines")
+		}
+	}
+	if toml.IsArrayOfTables(x.Note) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "note"), x.Note); err != nil {
+			return nil, toml.WithField(err, "note")
+		}
+	}
+	w.Leave()
+	return buf, nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (x *Order) UnmarshalTOML(value interface{}) error {
+	return x.DecodeTOML(toml.NewReader(), value)
+}
+
+// DecodeTOML implements toml.Decoder.
+func (x *Order) DecodeTOML(r *toml.Reader, value interface{}) error {
+	if value == nil {
+		*x = Order{}
+		return nil
+	}
+	table, err := r.Table(value, tomlKeysOrder)
+	if err != nil {
+		return err
+	}
+	if v, ok := table["id"]; ok {
+		if err := r.Field("id", &x.ID, func() error {
+			d, err := toml.DecodeInt(v)
+			if err != nil {
+				return err
+			}
+			x.ID = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["status"]; ok {
+		if err := r.Field("status", &x.Status, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.Status = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["paid"]; ok {
+		if err := r.Field("paid", &x.Paid, func() error {
+			d, err := toml.DecodeBool(v)
+			if err != nil {
+				return err
+			}
+			x.Paid = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["total"]; ok {
+		if err := r.Field("total", &x.Total, func() error {
+			d, err := toml.DecodeFloat(v)
+			if err != nil {
+				return err
+			}
+			x.Total = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["discount"]; ok {
+		if err := r.Field("discount", &x.Discount, func() error {
+			d, err := toml.DecodeFloatBits(v, 32)
+			if err != nil {
+				return err
+			}
+			x.Discount = float32(d)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["quantity"]; ok {
+		if err := r.Field("quantity", &x.Quantity, func() error {
+			d, err := toml.DecodeUintBits(v, 16)
+			if err != nil {
+				return err
+			}
+			x.Quantity = uint16(d)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["tags"]; ok {
+		if err := r.Field("tags", &x.Tags, func() error {
+			if v == nil {
+				x.Tags = nil
+				return nil
+			}
+			arr, err := toml.DecodeArray(v)
+			if err != nil {
+				return err
+			}
+			keep, start := r.Slice(len(x.Tags))
+			s := x.Tags[:keep:keep]
+			for i, e := range arr {
+				j := start + i
+				if j == len(s) {
+					s = append(s, "")
+				}
+				if err := r.Element(i, &s[j], func() error {
+					d, err := toml.DecodeString(e)
+					if err != nil {
+						return err
+					}
+					s[j] = d
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if s == nil {
+				s = make([]string, 0)
+			}
+			x.Tags = s
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["scores"]; ok {
+		if err := r.Field("scores", &x.Scores, func() error {
+			if v == nil {
+				x.Scores = nil
+				return nil
+			}
+			arr, err := toml.DecodeArray(v)
+			if err != nil {
+				return err
+			}
+			keep, start := r.Slice(len(x.Scores))
+			s := x.Scores[:keep:keep]
+			for i, e := range arr {
+				j := start + i
+				if j == len(s) {
+					s = append(s, 0)
+				}
+				if err := r.Element(i, &s[j], func() error {
+					d, err := toml.DecodeIntBits(e, strconv.IntSize)
+					if err != nil {
+						return err
+					}
+					s[j] = int(d)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if s == nil {
+				s = make([]int, 0)
+			}
+			x.Scores = s
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["payload"]; ok {
+		if err := r.Field("payload", &x.Payload, func() error {
+			if v == nil {
+				x.Payload = nil
+				return nil
+			}
+			arr, err := toml.DecodeArray(v)
+			if err != nil {
+				return err
+			}
+			keep, start := r.Slice(len(x.Payload))
+			s := x.Payload[:keep:keep]
+			for i, e := range arr {
+				j := start + i
+				if j == len(s) {
+					s = append(s, 0)
+				}
+				if err := r.Element(i, &s[j], func() error {
+					d, err := toml.DecodeUintBits(e, 8)
+					if err != nil {
+						return err
+					}
+					s[j] = byte(d)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if s == nil {
+				s = make([]byte, 0)
+			}
+			x.Payload = s
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["created"]; ok {
+		if err := r.Field("created", &x.Created, func() error {
+			return r.Decode(v, &x.Created)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["meta"]; ok {
+		if err := r.Field("meta", &x.Meta, func() error {
+			return r.Decode(v, &x.Meta)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["customer"]; ok {
+		if err := r.Field("customer", &x.Customer, func() error {
+			return x.Customer.DecodeTOML(r, v)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["lines"]; ok {
+		if err := r.Field("lines", &x.Lines, func() error {
+			return r.Decode(v, &x.Lines)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["note"]; ok {
+		if err := r.Field("note", &x.Note, func() error {
+			return r.Decode(v, &x.Note)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tomlKeysOrder = toml.NewKeys(
+	[]string{"id"},
+	[]string{"status"},
+	[]string{"paid"},
+	[]string{"total"},
+	[]string{"discount"},
+	[]string{"quantity"},
+	[]string{"tags"},
+	[]string{"scores"},
+	[]string{"payload"},
+	[]string{"created"},
+	[]string{"meta"},
+	[]string{"customer"},
+	[]string{"lines"},
+	[]string{"note"},
+)
+
+// MarshalJSON implements json.Marshaler.
+func (x Customer) MarshalJSON() ([]byte, error) {
+	return x.AppendJSON(nil)
+}
+
+// AppendJSON implements json.Appender.
+func (x Customer) AppendJSON(buf []byte) ([]byte, error) {
+	return x.EncodeJSON(json.NewWriter(), buf)
+}
+
+// EncodeJSON implements json.Encoder.
+func (x Customer) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "{\"name\":"...)
+	if buf, err = w.String(buf, x.Name); err != nil {
+		return nil, json.WithField(err, "name")
+	}
+	buf = append(buf, ",\"email\":"...)
+	if buf, err = w.String(buf, x.Email); err != nil {
+		return nil, json.WithField(err, "email")
+	}
+	w.Leave()
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (x *Customer) UnmarshalJSON(data []byte) error {
+	return x.DecodeJSON(json.NewReader(data))
+}
+
+// DecodeJSON implements json.Decoder.
+func (x *Customer) DecodeJSON(r *json.Reader) error {
+	if r.Null() {
+		*x = Customer{}
+		return nil
+	}
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, jsonKeysCustomer)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "name":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.Name = v
+			return nil
+		case "email":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.Email = v
+			return nil
+		}
+		return r.UnknownField()
+	})
+}
+
+var jsonKeysCustomer = json.NewKeys(
+	[]string{"name"},
+	[]string{"email"},
+)
+
+// MarshalTOML implements toml.Marshaler.
+func (x Customer) MarshalTOML() ([]byte, error) {
+	return x.AppendTOML(nil, nil)
+}
+
+// AppendTOML implements toml.Appender.
+func (x Customer) AppendTOML(buf []byte, path []string) ([]byte, error) {
+	return x.EncodeTOML(toml.NewWriter(), buf, path)
+}
+
+// EncodeTOML implements toml.Encoder.
+func (x Customer) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	buf = append(buf, "name = "...)
+	buf = toml.AppendString(buf, x.Name)
+	buf = append(buf, '\n')
+	buf = append(buf, "email = "...)
+	buf = toml.AppendString(buf, x.Email)
+	buf = append(buf, '\n')
+	w.Leave()
+	return buf, nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (x *Customer) UnmarshalTOML(value interface{}) error {
+	return x.DecodeTOML(toml.NewReader(), value)
+}
+
+// DecodeTOML implements toml.Decoder.
+func (x *Customer) DecodeTOML(r *toml.Reader, value interface{}) error {
+	if value == nil {
+		*x = Customer{}
+		return nil
+	}
+	table, err := r.Table(value, tomlKeysCustomer)
+	if err != nil {
+		return err
+	}
+	if v, ok := table["name"]; ok {
+		if err := r.Field("name", &x.Name, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.Name = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["email"]; ok {
+		if err := r.Field("email", &x.Email, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.Email = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tomlKeysCustomer = toml.NewKeys(
+	[]string{"name"},
+	[]string{"email"},
+)
+
+// MarshalJSON implements json.Marshaler.
+func (x Line) MarshalJSON() ([]byte, error) {
+	return x.AppendJSON(nil)
+}
+
+// AppendJSON implements json.Appender.
+func (x Line) AppendJSON(buf []byte) ([]byte, error) {
+	return x.EncodeJSON(json.NewWriter(), buf)
+}
+
+// EncodeJSON implements json.Encoder.
+func (x Line) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "{\"sku\":"...)
+	if buf, err = w.String(buf, x.SKU); err != nil {
+		return nil, json.WithField(err, "sku")
+	}
+	buf = append(buf, ",\"price\":"...)
+	if buf, err = w.Float(buf, x.Price, 64); err != nil {
+		return nil, json.WithField(err, "price")
+	}
+	buf = append(buf, ",\"count\":"...)
+	buf = strconv.AppendInt(buf, int64(x.Count), 10)
+	w.Leave()
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (x *Line) UnmarshalJSON(data []byte) error {
+	return x.DecodeJSON(json.NewReader(data))
+}
+
+// DecodeJSON implements json.Decoder.
+func (x *Line) DecodeJSON(r *json.Reader) error {
+	if r.Null() {
+		*x = Line{}
+		return nil
+	}
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, jsonKeysLine)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "sku":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.SKU = v
+			return nil
+		case "price":
+			v, err := r.Float()
+			if err != nil {
+				return err
+			}
+			x.Price = v
+			return nil
+		case "count":
+			v, err := r.IntBits(strconv.IntSize)
+			if err != nil {
+				return err
+			}
+			x.Count = int(v)
+			return nil
+		}
+		return r.UnknownField()
+	})
+}
+
+var jsonKeysLine = json.NewKeys(
+	[]string{"sku", "article"},
+	[]string{"price"},
+	[]string{"count"},
+)
+
+// MarshalTOML implements toml.Marshaler.
+func (x Line) MarshalTOML() ([]byte, error) {
+	return x.AppendTOML(nil, nil)
+}
+
+// AppendTOML implements toml.Appender.
+func (x Line) AppendTOML(buf []byte, path []string) ([]byte, error) {
+	return x.EncodeTOML(toml.NewWriter(), buf, path)
+}
+
+// EncodeTOML implements toml.Encoder.
+func (x Line) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "sku = "...)
+	buf = toml.AppendString(buf, x.SKU)
+	buf = append(buf, '\n')
+	buf = append(buf, "price = "...)
+	if buf, err = toml.AppendFloat(buf, x.Price, 64); err != nil {
+		return nil, toml.WithField(err, "price")
+	}
+	buf = append(buf, '\n')
+	buf = append(buf, "count = "...)
+	buf = strconv.AppendInt(buf, int64(x.Count), 10)
+	buf = append(buf, '\n')
+	w.Leave()
+	return buf, nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (x *Line) UnmarshalTOML(value interface{}) error {
+	return x.DecodeTOML(toml.NewReader(), value)
+}
+
+// DecodeTOML implements toml.Decoder.
+func (x *Line) DecodeTOML(r *toml.Reader, value interface{}) error {
+	if value == nil {
+		*x = Line{}
+		return nil
+	}
+	table, err := r.Table(value, tomlKeysLine)
+	if err != nil {
+		return err
+	}
+	if v, ok := table["sku"]; ok {
+		if err := r.Field("sku", &x.SKU, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.SKU = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["price"]; ok {
+		if err := r.Field("price", &x.Price, func() error {
+			d, err := toml.DecodeFloat(v)
+			if err != nil {
+				return err
+			}
+			x.Price = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["count"]; ok {
+		if err := r.Field("count", &x.Count, func() error {
+			d, err := toml.DecodeIntBits(v, strconv.IntSize)
+			if err != nil {
+				return err
+			}
+			x.Count = int(d)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tomlKeysLine = toml.NewKeys(
+	[]string{"sku", "article"},
+	[]string{"price"},
+	[]string{"count"},
+)
+
+// MarshalJSON implements json.Marshaler.
+func (x Node) MarshalJSON() ([]byte, error) {
+	return x.AppendJSON(nil)
+}
+
+// AppendJSON implements json.Appender.
+func (x Node) AppendJSON(buf []byte) ([]byte, error) {
+	return x.EncodeJSON(json.NewWriter(), buf)
+}
+
+// EncodeJSON implements json.Encoder.
+func (x Node) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "{\"name\":"...)
+	if buf, err = w.String(buf, x.Name); err != nil {
+		return nil, json.WithField(err, "name")
+	}
+	buf = append(buf, ",\"value\":"...)
+	if buf, err = w.Value(buf, x.Value); err != nil {
+		return nil, json.WithField(err, "value")
+	}
+	buf = append(buf, ",\"next\":"...)
+	if buf, err = w.Value(buf, x.Next); err != nil {
+		return nil, json.WithField(err, "next")
+	}
+	w.Leave()
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (x *Node) UnmarshalJSON(data []byte) error {
+	return x.DecodeJSON(json.NewReader(data))
+}
+
+// DecodeJSON implements json.Decoder.
+func (x *Node) DecodeJSON(r *json.Reader) error {
+	if r.Null() {
+		*x = Node{}
+		return nil
+	}
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, jsonKeysNode)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "name":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.Name = v
+			return nil
+		case "value":
+			return r.Decode(&x.Value)
+		case "next":
+			return r.Decode(&x.Next)
+		}
+		return r.UnknownField()
+	})
+}
+
+var jsonKeysNode = json.NewKeys(
+	[]string{"name"},
+	[]string{"value"},
+	[]string{"next"},
+)
+
+// MarshalTOML implements toml.Marshaler.
+func (x Node) MarshalTOML() ([]byte, error) {
+	return x.AppendTOML(nil, nil)
+}
+
+// AppendTOML implements toml.Appender.
+func (x Node) AppendTOML(buf []byte, path []string) ([]byte, error) {
+	return x.EncodeTOML(toml.NewWriter(), buf, path)
+}
+
+// EncodeTOML implements toml.Encoder.
+func (x Node) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, "name = "...)
+	buf = toml.AppendString(buf, x.Name)
+	buf = append(buf, '\n')
+	if !toml.IsTable(x.Value) && !toml.IsArrayOfTables(x.Value) {
+		if buf, err = w.KeyValue(buf, "value", x.Value); err != nil {
+			return nil, toml.WithField(err, "value")
+		}
+	}
+	if !toml.IsTable(x.Next) && !toml.IsArrayOfTables(x.Next) {
+		if buf, err = w.KeyValue(buf, "next", x.Next); err != nil {
+			return nil, toml.WithField(err, "next")
+		}
+	}
+	if toml.IsTable(x.Value) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "value"), x.Value); err != nil {
+			return nil, toml.WithField(err, "value")
+		}
+	}
+	if toml.IsTable(x.Next) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "next"), x.Next); err != nil {
+			return nil, toml.WithField(err, "next")
+		}
+	}
+	if toml.IsArrayOfTables(x.Value) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "value"), x.Value); err != nil {
+			return nil, toml.WithField(err, "value")
+		}
+	}
+	if toml.IsArrayOfTables(x.Next) {
+		if buf, err = w.Table(buf, append(path[:len(path):len(path)], "next"), x.Next); err != nil {
+			return nil, toml.WithField(err, "next")
+		}
+	}
+	w.Leave()
+	return buf, nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (x *Node) UnmarshalTOML(value interface{}) error {
+	return x.DecodeTOML(toml.NewReader(), value)
+}
+
+// DecodeTOML implements toml.Decoder.
+func (x *Node) DecodeTOML(r *toml.Reader, value interface{}) error {
+	if value == nil {
+		*x = Node{}
+		return nil
+	}
+	table, err := r.Table(value, tomlKeysNode)
+	if err != nil {
+		return err
+	}
+	if v, ok := table["name"]; ok {
+		if err := r.Field("name", &x.Name, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.Name = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["value"]; ok {
+		if err := r.Field("value", &x.Value, func() error {
+			return r.Decode(v, &x.Value)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["next"]; ok {
+		if err := r.Field("next", &x.Next, func() error {
+			return r.Decode(v, &x.Next)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tomlKeysNode = toml.NewKeys(
+	[]string{"name"},
+	[]string{"value"},
+	[]string{"next"},
+)
+
+// MarshalJSON implements json.Marshaler.
+func (x Profile) MarshalJSON() ([]byte, error) {
+	return x.AppendJSON(nil)
+}
+
+// AppendJSON implements json.Appender.
+func (x Profile) AppendJSON(buf []byte) ([]byte, error) {
+	return x.EncodeJSON(json.NewWriter(), buf)
+}
+
+// EncodeJSON implements json.Encoder.
+func (x Profile) EncodeJSON(w *json.Writer, buf []byte) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	buf = append(buf, '{')
+	n := len(buf)
+	if x.Name != "" {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"name\":"...)
+		if buf, err = w.String(buf, x.Name); err != nil {
+			return nil, json.WithField(err, "name")
+		}
+	}
+	if x.Age != 0 {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"age\":"...)
+		buf = strconv.AppendInt(buf, int64(x.Age), 10)
+	}
+	if x.Score != 0 {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"score\":"...)
+		if buf, err = w.Float(buf, x.Score, 64); err != nil {
+			return nil, json.WithField(err, "score")
+		}
+	}
+	if x.Active {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"active\":"...)
+		buf = strconv.AppendBool(buf, x.Active)
+	}
+	if len(x.Tags) != 0 {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"tags\":"...)
+		if x.Tags == nil {
+			buf = append(buf, "null"...)
+		} else {
+			if err := w.Enter(); err != nil {
+				return nil, json.WithField(err, "tags")
+			}
+			buf = append(buf, '[')
+			for i, v := range x.Tags {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				if buf, err = w.String(buf, v); err != nil {
+					return nil, json.WithField(json.WithField(err, strconv.Itoa(i)), "tags")
+				}
+			}
+			w.Leave()
+			buf = append(buf, ']')
+		}
+	}
+	if !json.IsZero(&x.Joined) {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"joined\":"...)
+		if buf, err = w.Value(buf, x.Joined); err != nil {
+			return nil, json.WithField(err, "joined")
+		}
+	}
+	if !json.IsEmpty(&x.Meta) {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"meta\":"...)
+		if buf, err = w.Value(buf, x.Meta); err != nil {
+			return nil, json.WithField(err, "meta")
+		}
+	}
+	if !json.IsZero(&x.Customer) {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"customer\":"...)
+		if buf, err = x.Customer.EncodeJSON(w, buf); err != nil {
+			return nil, json.WithField(err, "customer")
+		}
+	}
+	if !json.IsEmpty(&x.Note) && !json.IsZero(&x.Note) {
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"note\":"...)
+		if buf, err = w.Value(buf, x.Note); err != nil {
+			return nil, json.WithField(err, "note")
+		}
+	}
+	w.Leave()
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (x *Profile) UnmarshalJSON(data []byte) error {
+	return x.DecodeJSON(json.NewReader(data))
+}
+
+// DecodeJSON implements json.Decoder.
+func (x *Profile) DecodeJSON(r *json.Reader) error {
+	if r.Null() {
+		*x = Profile{}
+		return nil
+	}
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, jsonKeysProfile)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "name":
+			v, err := r.String()
+			if err != nil {
+				return err
+			}
+			x.Name = v
+			return nil
+		case "age":
+			v, err := r.IntBits(strconv.IntSize)
+			if err != nil {
+				return err
+			}
+			x.Age = int(v)
+			return nil
+		case "score":
+			v, err := r.Float()
+			if err != nil {
+				return err
+			}
+			x.Score = v
+			return nil
+		case "active":
+			v, err := r.Bool()
+			if err != nil {
+				return err
+			}
+			x.Active = v
+			return nil
+		case "tags":
+			if r.Null() {
+				x.Tags = nil
+				return nil
+			}
+			keep, i := r.Slice(len(x.Tags))
+			s := x.Tags[:keep:keep]
+			err := r.Array(func() error {
+				v, err := r.String()
+				if err != nil {
+					return err
+				}
+				if i < len(s) {
+					s[i] = v
+				} else {
+					s = append(s, v)
+				}
+				i++
+				return nil
+			})
+			if s == nil {
+				s = make([]string, 0)
+			}
+			x.Tags = s
+			return err
+		case "joined":
+			return r.Decode(&x.Joined)
+		case "meta":
+			return r.Decode(&x.Meta)
+		case "customer":
+			return x.Customer.DecodeJSON(r)
+		case "note":
+			return r.Decode(&x.Note)
+		}
+		return r.UnknownField()
+	})
+}
+
+var jsonKeysProfile = json.NewKeys(
+	[]string{"name"},
+	[]string{"age"},
+	[]string{"score"},
+	[]string{"active"},
+	[]string{"tags"},
+	[]string{"joined"},
+	[]string{"meta"},
+	[]string{"customer"},
+	[]string{"note"},
+)
+
+// MarshalTOML implements toml.Marshaler.
+func (x Profile) MarshalTOML() ([]byte, error) {
+	return x.AppendTOML(nil, nil)
+}
+
+// AppendTOML implements toml.Appender.
+func (x Profile) AppendTOML(buf []byte, path []string) ([]byte, error) {
+	return x.EncodeTOML(toml.NewWriter(), buf, path)
+}
+
+// EncodeTOML implements toml.Encoder.
+func (x Profile) EncodeTOML(w *toml.Writer, buf []byte, path []string) ([]byte, error) {
+	if err := w.Enter(); err != nil {
+		return nil, err
+	}
+	var err error
+	if x.Name != "" {
+		buf = append(buf, "name = "...)
+		buf = toml.AppendString(buf, x.Name)
+		buf = append(buf, '\n')
+	}
+	if x.Age != 0 {
+		buf = append(buf, "age = "...)
+		buf = strconv.AppendInt(buf, int64(x.Age), 10)
+		buf = append(buf, '\n')
+	}
+	if x.Score != 0 {
+		buf = append(buf, "score = "...)
+		if buf, err = toml.AppendFloat(buf, x.Score, 64); err != nil {
+			return nil, toml.WithField(err, "score")
+		}
+		buf = append(buf, '\n')
+	}
+	if x.Active {
+		buf = append(buf, "active = "...)
+		buf = strconv.AppendBool(buf, x.Active)
+		buf = append(buf, '\n')
+	}
+	if x.Tags != nil {
+		if x.Tags != nil {
+			if err := w.Enter(); err != nil {
+				return nil, toml.WithField(err, "tags")
+			}
+			buf = append(buf, "tags = ["...)
+			for i, v := range x.Tags {
+				if i > 0 {
+					buf = append(buf, ", "...)
+				}
+				buf = toml.AppendString(buf, v)
+			}
+			w.Leave()
+			buf = append(buf, "]\n"...)
+		}
+	}
+	if !toml.IsZero(&x.Joined) {
+		if !toml.IsTable(x.Joined) && !toml.IsArrayOfTables(x.Joined) {
+			if buf, err = w.KeyValue(buf, "joined", x.Joined); err != nil {
+				return nil, toml.WithField(err, "joined")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Meta) {
+		if !toml.IsTable(x.Meta) && !toml.IsArrayOfTables(x.Meta) {
+			if buf, err = w.KeyValue(buf, "meta", x.Meta); err != nil {
+				return nil, toml.WithField(err, "meta")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Note) {
+		if !toml.IsTable(x.Note) && !toml.IsArrayOfTables(x.Note) {
+			if buf, err = w.KeyValue(buf, "note", x.Note); err != nil {
+				return nil, toml.WithField(err, "note")
+			}
+		}
+	}
+	if !toml.IsZero(&x.Joined) {
+		if toml.IsTable(x.Joined) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "joined"), x.Joined); err != nil {
+				return nil, toml.WithField(err, "joined")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Meta) {
+		if toml.IsTable(x.Meta) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "meta"), x.Meta); err != nil {
+				return nil, toml.WithField(err, "meta")
+			}
+		}
+	}
+	if !toml.IsZero(&x.Customer) {
+		{
+			sub := append(path[:len(path):len(path)], "customer")
+			buf = toml.AppendHeader(buf, sub)
+			if buf, err = x.Customer.EncodeTOML(w, buf, sub); err != nil {
+				return nil, toml.WithField(err, "customer")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Note) {
+		if toml.IsTable(x.Note) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "note"), x.Note); err != nil {
+				return nil, toml.WithField(err, "note")
+			}
+		}
+	}
+	if !toml.IsZero(&x.Joined) {
+		if toml.IsArrayOfTables(x.Joined) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "joined"), x.Joined); err != nil {
+				return nil, toml.WithField(err, "joined")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Meta) {
+		if toml.IsArrayOfTables(x.Meta) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "meta"), x.Meta); err != nil {
+				return nil, toml.WithField(err, "meta")
+			}
+		}
+	}
+	if !toml.IsEmpty(&x.Note) {
+		if toml.IsArrayOfTables(x.Note) {
+			if buf, err = w.Table(buf, append(path[:len(path):len(path)], "note"), x.Note); err != nil {
+				return nil, toml.WithField(err, "note")
+			}
+		}
+	}
+	w.Leave()
+	return buf, nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (x *Profile) UnmarshalTOML(value interface{}) error {
+	return x.DecodeTOML(toml.NewReader(), value)
+}
+
+// DecodeTOML implements toml.Decoder.
+func (x *Profile) DecodeTOML(r *toml.Reader, value interface{}) error {
+	if value == nil {
+		*x = Profile{}
+		return nil
+	}
+	table, err := r.Table(value, tomlKeysProfile)
+	if err != nil {
+		return err
+	}
+	if v, ok := table["name"]; ok {
+		if err := r.Field("name", &x.Name, func() error {
+			d, err := toml.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			x.Name = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["age"]; ok {
+		if err := r.Field("age", &x.Age, func() error {
+			d, err := toml.DecodeIntBits(v, strconv.IntSize)
+			if err != nil {
+				return err
+			}
+			x.Age = int(d)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["score"]; ok {
+		if err := r.Field("score", &x.Score, func() error {
+			d, err := toml.DecodeFloat(v)
+			if err != nil {
+				return err
+			}
+			x.Score = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["active"]; ok {
+		if err := r.Field("active", &x.Active, func() error {
+			d, err := toml.DecodeBool(v)
+			if err != nil {
+				return err
+			}
+			x.Active = d
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["tags"]; ok {
+		if err := r.Field("tags", &x.Tags, func() error {
+			if v == nil {
+				x.Tags = nil
+				return nil
+			}
+			arr, err := toml.DecodeArray(v)
+			if err != nil {
+				return err
+			}
+			keep, start := r.Slice(len(x.Tags))
+			s := x.Tags[:keep:keep]
+			for i, e := range arr {
+				j := start + i
+				if j == len(s) {
+					s = append(s, "")
+				}
+				if err := r.Element(i, &s[j], func() error {
+					d, err := toml.DecodeString(e)
+					if err != nil {
+						return err
+					}
+					s[j] = d
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if s == nil {
+				s = make([]string, 0)
+			}
+			x.Tags = s
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["joined"]; ok {
+		if err := r.Field("joined", &x.Joined, func() error {
+			return r.Decode(v, &x.Joined)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["meta"]; ok {
+		if err := r.Field("meta", &x.Meta, func() error {
+			return r.Decode(v, &x.Meta)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["customer"]; ok {
+		if err := r.Field("customer", &x.Customer, func() error {
+			return x.Customer.DecodeTOML(r, v)
+		}); err != nil {
+			return err
+		}
+	}
+	if v, ok := table["note"]; ok {
+		if err := r.Field("note", &x.Note, func() error {
+			return r.Decode(v, &x.Note)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tomlKeysProfile = toml.NewKeys(
+	[]string{"name"},
+	[]string{"age"},
+	[]string{"score"},
+	[]string{"active"},
+	[]string{"tags"},
+	[]string{"joined"},
+	[]string{"meta"},
+	[]string{"customer"},
+	[]string{"note"},
+)
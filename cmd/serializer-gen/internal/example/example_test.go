@@ -0,0 +1,584 @@
+package example
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+// plainOrder has the fields of Order but none of its methods, so the
+// codecs fall back to reflection for it.
+type plainOrder Order
+
+type plainCustomer Customer
+
+func testOrder() Order {
+	note := "позвонить перед доставкой"
+	return Order{
+		ID:       42,
+		Status:   "paid \"fast\"\n",
+		Paid:     true,
+		Total:    1234.5,
+		Discount: 0.25,
+		Quantity: 3,
+		Tags:     []string{"новый", "vip"},
+		Scores:   []int{1, -2, 3},
+		Payload:  []byte("payload"),
+		Created:  time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Meta:     map[string]string{"source": "web"},
+		Customer: Customer{Name: "Иван", Email: "ivan@example.com"},
+		Lines: []Line{
+			{SKU: "A-1", Price: 9.99, Count: 2},
+			{SKU: "B-2", Price: 100, Count: 1},
+		},
+		Note:     &note,
+		Internal: "не сериализуется",
+	}
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+	serializers := map[string]interface {
+		Marshal(any) ([]byte, error)
+		Unmarshal([]byte, any) error
+	}{
+		"JSON":             json.New(),
+		"TOML":             toml.New(),
+		"JSON с отступами": json.New(json.WithIndent("  ")),
+		"TOML с отступами": toml.New(toml.WithIndent("  ")),
+	}
+
+	orders := map[string]Order{
+		"заполненный": testOrder(),
+		"пустой":      {},
+	}
+
+	for format, s := range serializers {
+		for name, order := range orders {
+			order.Internal = ""
+
+			generated, err := s.Marshal(order)
+			if err != nil {
+				t.Fatalf("%s, %s: Marshal() error = %v", format, name, err)
+			}
+			reflected, err := s.Marshal(plainOrder(order))
+			if err != nil {
+				t.Fatalf("%s, %s: Marshal() через рефлексию error = %v", format, name, err)
+			}
+			if string(generated) != string(reflected) {
+				t.Errorf("%s, %s: сгенерированный код выдал\n%s\nа рефлексия\n%s", format, name, generated, reflected)
+			}
+
+			var decoded Order
+			if err := s.Unmarshal(reflected, &decoded); err != nil {
+				t.Fatalf("%s, %s: Unmarshal() error = %v", format, name, err)
+			}
+			var plain plainOrder
+			if err := s.Unmarshal(reflected, &plain); err != nil {
+				t.Fatalf("%s, %s: Unmarshal() через рефлексию error = %v", format, name, err)
+			}
+			if !reflect.DeepEqual(decoded, Order(plain)) {
+				t.Errorf("%s, %s: Unmarshal() = %+v, через рефлексию %+v", format, name, decoded, plain)
+			}
+
+			customer := order.Customer
+			generated, _ = s.Marshal(customer)
+			reflected, _ = s.Marshal(plainCustomer(customer))
+			if string(generated) != string(reflected) {
+				t.Errorf("%s, %s: Customer: сгенерированный код выдал %s, а рефлексия %s", format, name, generated, reflected)
+			}
+		}
+	}
+}
+
+func TestGeneratedJSONNull(t *testing.T) {
+	order := testOrder()
+	if err := order.UnmarshalJSON([]byte("null")); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	if !reflect.DeepEqual(order, Order{}) {
+		t.Errorf("null должен обнулять значение, получено %+v", order)
+	}
+
+	var customer Customer
+	err := customer.UnmarshalJSON([]byte(`{"name":"Иван","unknown":[1,{"a":2}],"email":"e"}`))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	if customer != (Customer{Name: "Иван", Email: "e"}) {
+		t.Errorf("UnmarshalJSON() = %+v", customer)
+	}
+}
+
+func TestGeneratedTypeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		toml  string
+		field string
+	}{
+		{"переполнение", `{"quantity": 70000}`, "quantity = 70000\n", "quantity"},
+		{"отрицательное", `{"quantity": -1}`, "quantity = -1\n", "quantity"},
+		{"элемент массива", `{"scores": [1, 2.5]}`, "scores = [1, 2.5]\n", "scores.1"},
+		{"вложенная структура", `{"lines": [{"count": "x"}]}`, "[[lines]]\ncount = \"x\"\n", "lines.0.count"},
+	}
+	for _, tt := range tests {
+		inputs := map[string]struct {
+			data string
+			s    interface{ Unmarshal([]byte, any) error }
+		}{
+			"JSON": {tt.json, json.New()},
+			"TOML": {tt.toml, toml.New()},
+		}
+		for format, in := range inputs {
+			var order Order
+			genErr := in.s.Unmarshal([]byte(in.data), &order)
+			var plain plainOrder
+			reflectErr := in.s.Unmarshal([]byte(in.data), &plain)
+
+			for _, err := range []error{genErr, reflectErr} {
+				var field string
+				var jsonErr *json.TypeError
+				var tomlErr *toml.TypeError
+				switch {
+				case errors.As(err, &jsonErr):
+					field = jsonErr.Field
+				case errors.As(err, &tomlErr):
+					field = tomlErr.Field
+				default:
+					t.Errorf("%s, %s: ожидалась TypeError, получено %v", format, tt.name, err)
+					continue
+				}
+				if field != tt.field {
+					t.Errorf("%s, %s: Field = %q, want %q", format, tt.name, field, tt.field)
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratedStrict(t *testing.T) {
+	data := []byte(`{"id": 1, "lines": [{"sku": "a", "color": "red"}]}`)
+
+	var order Order
+	genErr := json.New(json.Strict()).Unmarshal(data, &order)
+	var plain plainOrder
+	reflectErr := json.New(json.Strict()).Unmarshal(data, &plain)
+
+	for _, err := range []error{genErr, reflectErr} {
+		var unknown *json.UnknownFieldError
+		if !errors.As(err, &unknown) || unknown.Field != "lines.0.color" {
+			t.Errorf("Unmarshal() error = %v, want unknown lines.0.color", err)
+		}
+	}
+
+	data = []byte("id = 1\n\n[[lines]]\nsku = \"a\"\ncolor = \"red\"\n")
+	genErr = toml.New(toml.Strict()).Unmarshal(data, &order)
+	reflectErr = toml.New(toml.Strict()).Unmarshal(data, &plain)
+
+	for _, err := range []error{genErr, reflectErr} {
+		var unknown *toml.UnknownFieldError
+		if !errors.As(err, &unknown) || unknown.Field != "lines.0.color" {
+			t.Errorf("TOML: Unmarshal() error = %v, want unknown lines.0.color", err)
+		}
+	}
+}
+
+// TestGeneratedMerge checks that generated decoders update the value they
+// decode into with WithMerge, as reflection does.
+func TestGeneratedMerge(t *testing.T) {
+	jsonInput := `{"status": "new", "tags": ["x"], "scores": [7, 8, 9, 10], "meta": {"ref": "mail"}, "customer": {"email": "e"}}`
+	tomlInput := "status = \"new\"\ntags = [\"x\"]\nscores = [7, 8, 9, 10]\n\n[meta]\nref = \"mail\"\n\n[customer]\nemail = \"e\"\n"
+
+	tests := []struct {
+		name   string
+		data   string
+		s      interface{ Unmarshal([]byte, any) error }
+		tags   []string
+		scores []int
+	}{
+		{"JSON ReplaceSlices", jsonInput, json.New(json.WithMerge(json.ReplaceSlices)), []string{"x"}, []int{7, 8, 9, 10}},
+		{"JSON AppendSlices", jsonInput, json.New(json.WithMerge(json.AppendSlices)), []string{"новый", "vip", "x"}, []int{1, -2, 3, 7, 8, 9, 10}},
+		{"JSON MergeSliceElements", jsonInput, json.New(json.WithMerge(json.MergeSliceElements)), []string{"x", "vip"}, []int{7, 8, 9, 10}},
+		{"TOML ReplaceSlices", tomlInput, toml.New(toml.WithMerge(toml.ReplaceSlices)), []string{"x"}, []int{7, 8, 9, 10}},
+		{"TOML AppendSlices", tomlInput, toml.New(toml.WithMerge(toml.AppendSlices)), []string{"новый", "vip", "x"}, []int{1, -2, 3, 7, 8, 9, 10}},
+		{"TOML MergeSliceElements", tomlInput, toml.New(toml.WithMerge(toml.MergeSliceElements)), []string{"x", "vip"}, []int{7, 8, 9, 10}},
+	}
+	for _, tt := range tests {
+		order := testOrder()
+		if err := tt.s.Unmarshal([]byte(tt.data), &order); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", tt.name, err)
+		}
+		plain := plainOrder(testOrder())
+		if err := tt.s.Unmarshal([]byte(tt.data), &plain); err != nil {
+			t.Fatalf("%s: Unmarshal() через рефлексию error = %v", tt.name, err)
+		}
+
+		if order.Status != "new" || order.Customer.Name != "Иван" || order.Meta["source"] != "web" || order.Meta["ref"] != "mail" {
+			t.Errorf("%s: Unmarshal() = %+v", tt.name, order)
+		}
+		if !reflect.DeepEqual(order.Tags, tt.tags) || !reflect.DeepEqual(order.Scores, tt.scores) {
+			t.Errorf("%s: tags = %q, scores = %v, want %q, %v", tt.name, order.Tags, order.Scores, tt.tags, tt.scores)
+		}
+		if !reflect.DeepEqual(order, Order(plain)) {
+			t.Errorf("%s: Unmarshal() = %+v, через рефлексию %+v", tt.name, order, plain)
+		}
+	}
+}
+
+func TestGeneratedKeyMatching(t *testing.T) {
+	inputs := map[string]struct {
+		data string
+		s    interface{ Unmarshal([]byte, any) error }
+	}{
+		"JSON": {`{"ID": 7, "Lines": [{"Article": "a-1", "COUNT": 2}]}`, json.New()},
+		"TOML": {"ID = 7\n\n[[Lines]]\nArticle = \"a-1\"\nCOUNT = 2\n", toml.New()},
+	}
+	for format, in := range inputs {
+		var order Order
+		if err := in.s.Unmarshal([]byte(in.data), &order); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", format, err)
+		}
+		var plain plainOrder
+		if err := in.s.Unmarshal([]byte(in.data), &plain); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", format, err)
+		}
+		if order.ID != 7 || len(order.Lines) != 1 || order.Lines[0].SKU != "a-1" || order.Lines[0].Count != 2 {
+			t.Errorf("%s: Unmarshal() = %+v", format, order)
+		}
+		if plain.ID != order.ID || len(plain.Lines) != 1 || plain.Lines[0].SKU != order.Lines[0].SKU {
+			t.Errorf("%s: рефлексия = %+v, сгенерированный код = %+v", format, plain, order)
+		}
+	}
+}
+
+type codec interface {
+	Marshal(any) ([]byte, error)
+	Unmarshal([]byte, any) error
+}
+
+// TestGeneratedOptions checks that generated code follows the options of
+// the serializer it is called from, as reflection does.
+func TestGeneratedOptions(t *testing.T) {
+	special := testOrder()
+	special.Status = "неверный UTF-8: \xff"
+	special.Total = math.NaN()
+	special.Discount = float32(math.Inf(-1))
+
+	orders := map[string]Order{
+		"заполненный":     testOrder(),
+		"особые значения": special,
+	}
+
+	serializers := map[string]codec{
+		"JSON Base64URL":        json.New(json.WithBytesEncoding(json.Base64URL)),
+		"JSON Base64RawStd":     json.New(json.WithBytesEncoding(json.Base64RawStd)),
+		"JSON Base64RawURL":     json.New(json.WithBytesEncoding(json.Base64RawURL)),
+		"JSON Hex":              json.New(json.WithBytesEncoding(json.Hex)),
+		"JSON NonFiniteNull":    json.New(json.WithNonFinite(json.NonFiniteNull)),
+		"JSON NonFiniteString":  json.New(json.WithNonFinite(json.NonFiniteString)),
+		"JSON InvalidUTF8Error": json.New(json.WithInvalidUTF8(json.InvalidUTF8Error), json.WithNonFinite(json.NonFiniteNull)),
+		"JSON глубина 1":        json.New(json.WithMaxDepth(1)),
+		"JSON глубина 2":        json.New(json.WithMaxDepth(2)),
+		"JSON глубина 3":        json.New(json.WithMaxDepth(3)),
+		"TOML глубина 1":        toml.New(toml.WithMaxDepth(1)),
+		"TOML глубина 2":        toml.New(toml.WithMaxDepth(2)),
+	}
+
+	for format, s := range serializers {
+		for name, order := range orders {
+			order.Internal = ""
+
+			generated, genErr := s.Marshal(order)
+			reflected, reflectErr := s.Marshal(plainOrder(order))
+			if fmt.Sprint(genErr) != fmt.Sprint(reflectErr) {
+				t.Errorf("%s, %s: Marshal() error = %v, через рефлексию %v", format, name, genErr, reflectErr)
+				continue
+			}
+			if string(generated) != string(reflected) {
+				t.Errorf("%s, %s: сгенерированный код выдал\n%s\nа рефлексия\n%s", format, name, generated, reflected)
+			}
+			if genErr != nil || name != "заполненный" {
+				continue
+			}
+
+			var decoded Order
+			if err := s.Unmarshal(generated, &decoded); err != nil {
+				t.Fatalf("%s, %s: Unmarshal() error = %v", format, name, err)
+			}
+			var plain plainOrder
+			if err := s.Unmarshal(reflected, &plain); err != nil {
+				t.Fatalf("%s, %s: Unmarshal() через рефлексию error = %v", format, name, err)
+			}
+			if !reflect.DeepEqual(decoded, Order(plain)) {
+				t.Errorf("%s, %s: Unmarshal() = %+v, через рефлексию %+v", format, name, decoded, plain)
+			}
+		}
+	}
+}
+
+// plainNode mirrors Node without methods at any level of nesting.
+type plainNode struct {
+	Name  string     `json:"name" toml:"name"`
+	Value complex128 `json:"value" toml:"value"`
+	Next  *plainNode `json:"next" toml:"next"`
+}
+
+// describe returns err without the Go type a cycle is reported for, which
+// differs between Node and plainNode.
+func describe(err error) string {
+	var jsonCycle *json.CycleError
+	var tomlCycle *toml.CycleError
+	switch {
+	case errors.As(err, &jsonCycle):
+		return "цикл в " + jsonCycle.Field
+	case errors.As(err, &tomlCycle):
+		return "цикл в " + tomlCycle.Field
+	}
+	return fmt.Sprint(err)
+}
+
+func TestGeneratedNestingAndComplex(t *testing.T) {
+	chain := &Node{Name: "a", Value: 1 + 2i, Next: &Node{Name: "b", Next: &Node{Name: "c", Value: -0.5i}}}
+	plainChain := &plainNode{Name: "a", Value: 1 + 2i, Next: &plainNode{Name: "b", Next: &plainNode{Name: "c", Value: -0.5i}}}
+
+	loop := &Node{Name: "a", Next: &Node{Name: "b"}}
+	loop.Next.Next = loop
+	plainLoop := &plainNode{Name: "a", Next: &plainNode{Name: "b"}}
+	plainLoop.Next.Next = plainLoop
+
+	serializers := map[string]codec{
+		"JSON":               json.New(),
+		"JSON ComplexString": json.New(json.WithComplexFormat(json.ComplexString)),
+		"JSON ComplexArray":  json.New(json.WithComplexFormat(json.ComplexArray)),
+		"JSON глубина 2":     json.New(json.WithComplexFormat(json.ComplexString), json.WithMaxDepth(2)),
+		"TOML":               toml.New(),
+		"TOML ComplexString": toml.New(toml.WithComplexFormat(toml.ComplexString)),
+		"TOML ComplexArray":  toml.New(toml.WithComplexFormat(toml.ComplexArray)),
+		"TOML глубина 2":     toml.New(toml.WithComplexFormat(toml.ComplexString), toml.WithMaxDepth(2)),
+		"TOML с отступами":   toml.New(toml.WithComplexFormat(toml.ComplexArray), toml.WithIndent("  ")),
+		"JSON с отступами":   json.New(json.WithComplexFormat(json.ComplexArray), json.WithIndent("  ")),
+	}
+
+	for format, s := range serializers {
+		for _, tt := range []struct {
+			name      string
+			gen       *Node
+			reflected *plainNode
+		}{
+			{"цепочка", chain, plainChain},
+			{"цикл", loop, plainLoop},
+		} {
+			generated, genErr := s.Marshal(tt.gen)
+			reflected, reflectErr := s.Marshal(tt.reflected)
+			if describe(genErr) != describe(reflectErr) {
+				t.Errorf("%s, %s: Marshal() error = %v, через рефлексию %v", format, tt.name, genErr, reflectErr)
+				continue
+			}
+			if string(generated) != string(reflected) {
+				t.Errorf("%s, %s: сгенерированный код выдал\n%s\nа рефлексия\n%s", format, tt.name, generated, reflected)
+			}
+		}
+	}
+}
+
+func TestGeneratedKeyOrder(t *testing.T) {
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+	order := Order{Meta: map[string]string{"a": "1", "b": "2", "c": "3"}}
+
+	serializers := map[string]codec{
+		"JSON":             json.New(json.WithKeyComparator(reverse)),
+		"TOML":             toml.New(toml.WithKeyComparator(reverse)),
+		"JSON с отступами": json.New(json.WithKeyComparator(reverse), json.WithIndent("  ")),
+		"TOML с отступами": toml.New(toml.WithKeyComparator(reverse), toml.WithIndent("  ")),
+	}
+	for format, s := range serializers {
+		generated, err := s.Marshal(order)
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", format, err)
+		}
+		reflected, err := s.Marshal(plainOrder(order))
+		if err != nil {
+			t.Fatalf("%s: Marshal() через рефлексию error = %v", format, err)
+		}
+		if string(generated) != string(reflected) {
+			t.Errorf("%s: сгенерированный код выдал\n%s\nа рефлексия\n%s", format, generated, reflected)
+		}
+		c, b, a := strings.Index(string(generated), `"3"`), strings.Index(string(generated), `"2"`), strings.Index(string(generated), `"1"`)
+		if !(c < b && b < a) {
+			t.Errorf("%s: ключи meta должны идти в обратном порядке:\n%s", format, generated)
+		}
+	}
+
+	// Без сортировки порядок не определён, но содержимое то же.
+	unsorted := map[string]codec{
+		"JSON": json.New(json.WithUnsortedKeys()),
+		"TOML": toml.New(toml.WithUnsortedKeys()),
+	}
+	for format, s := range unsorted {
+		data, err := s.Marshal(order)
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", format, err)
+		}
+		var decoded Order
+		if err := s.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", format, err)
+		}
+		if !reflect.DeepEqual(decoded.Meta, order.Meta) {
+			t.Errorf("%s: meta = %v, want %v", format, decoded.Meta, order.Meta)
+		}
+	}
+}
+
+// TestGeneratedCollectErrors checks that generated decoders go on past
+// field errors with CollectErrors, as reflection does.
+func TestGeneratedCollectErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      *json.JSONSerializer
+		input  string
+		fields []string
+	}{
+		{
+			"типы", json.New(json.CollectErrors()),
+			`{"id": "x", "status": 5, "paid": true, "quantity": 70000, "scores": [1, "a", 3], "customer": {"name": 1, "email": "e"}, "lines": [{"sku": "a", "count": "x"}], "total": 2.5}`,
+			[]string{"id", "status", "quantity", "scores.1", "customer.name", "lines.0.count"},
+		},
+		{
+			"строгий режим", json.New(json.Strict(), json.CollectErrors()),
+			`{"extra": 1, "customer": {"x": 2, "name": "n"}, "paid": true, "discount": "0.5"}`,
+			[]string{"extra", "customer.x", "discount"},
+		},
+	}
+	for _, tt := range tests {
+		var order Order
+		genErr := tt.s.Unmarshal([]byte(tt.input), &order)
+		var plain plainOrder
+		reflectErr := tt.s.Unmarshal([]byte(tt.input), &plain)
+
+		for _, err := range []error{genErr, reflectErr} {
+			var multi *json.MultiError
+			if !errors.As(err, &multi) {
+				t.Errorf("%s: Unmarshal() error = %v, want *json.MultiError", tt.name, err)
+				continue
+			}
+			var fields []string
+			for _, err := range multi.Errors {
+				var typeErr *json.TypeError
+				var unknown *json.UnknownFieldError
+				switch {
+				case errors.As(err, &typeErr):
+					fields = append(fields, typeErr.Field)
+				case errors.As(err, &unknown):
+					fields = append(fields, unknown.Field)
+				default:
+					fields = append(fields, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("%s: ошибки в полях %q, want %q", tt.name, fields, tt.fields)
+			}
+		}
+		if !order.Paid || !reflect.DeepEqual(order, Order(plain)) {
+			t.Errorf("%s: Unmarshal() = %+v, через рефлексию %+v", tt.name, order, plain)
+		}
+	}
+}
+
+func TestGeneratedCollectErrorsTOML(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      *toml.TOMLSerializer
+		input  string
+		fields []string
+	}{
+		{
+			"типы", toml.New(toml.CollectErrors()),
+			"id = \"x\"\nstatus = 5\npaid = true\nquantity = 70000\nscores = [1, \"a\", 3]\ntotal = 2.5\n\n[customer]\nname = 1\nemail = \"e\"\n\n[[lines]]\nsku = \"a\"\ncount = \"x\"\n",
+			[]string{"customer.name", "id", "lines.0.count", "quantity", "scores.1", "status"},
+		},
+		{
+			"строгий режим", toml.New(toml.Strict(), toml.CollectErrors()),
+			"extra = 1\npaid = true\ndiscount = \"0.5\"\n\n[customer]\nx = 2\nname = \"n\"\n",
+			[]string{"customer.x", "discount", "extra"},
+		},
+	}
+	for _, tt := range tests {
+		var order Order
+		genErr := tt.s.Unmarshal([]byte(tt.input), &order)
+		var plain plainOrder
+		reflectErr := tt.s.Unmarshal([]byte(tt.input), &plain)
+
+		var positions [2][]string
+		for n, err := range []error{genErr, reflectErr} {
+			var multi *toml.MultiError
+			if !errors.As(err, &multi) {
+				t.Errorf("%s: Unmarshal() error = %v, want *toml.MultiError", tt.name, err)
+				continue
+			}
+			var fields []string
+			for _, err := range multi.Errors {
+				var typeErr *toml.TypeError
+				var unknown *toml.UnknownFieldError
+				switch {
+				case errors.As(err, &typeErr):
+					fields = append(fields, typeErr.Field)
+					positions[n] = append(positions[n], fmt.Sprintf("%d:%d", typeErr.Line, typeErr.Column))
+				case errors.As(err, &unknown):
+					fields = append(fields, unknown.Field)
+					positions[n] = append(positions[n], fmt.Sprintf("%d:%d", unknown.Line, unknown.Column))
+				default:
+					fields = append(fields, err.Error())
+				}
+			}
+			slices.Sort(fields)
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("%s: ошибки в полях %q, want %q", tt.name, fields, tt.fields)
+			}
+		}
+		if !reflect.DeepEqual(positions[0], positions[1]) {
+			t.Errorf("%s: позиции ошибок %q, через рефлексию %q", tt.name, positions[0], positions[1])
+		}
+		if !order.Paid || !reflect.DeepEqual(order, Order(plain)) {
+			t.Errorf("%s: Unmarshal() = %+v, через рефлексию %+v", tt.name, order, plain)
+		}
+	}
+}
+
+type plainProfile Profile
+
+func TestGeneratedOmit(t *testing.T) {
+	note := ""
+	profiles := map[string]Profile{
+		"пустой":           {},
+		"заполненный":      {Name: "Иван", Age: 30, Score: 4.5, Active: true, Tags: []string{"a"}, Joined: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Meta: map[string]string{"k": "v"}, Customer: Customer{Name: "Иван"}, Note: &note},
+		"пустые срезы":     {Tags: []string{}, Meta: map[string]string{}, Score: math.Copysign(0, -1)},
+		"только последний": {Note: &note},
+	}
+	serializers := map[string]codec{
+		"JSON": json.New(),
+		"TOML": toml.New(),
+	}
+	for format, s := range serializers {
+		for name, profile := range profiles {
+			generated, err := s.Marshal(profile)
+			if err != nil {
+				t.Fatalf("%s, %s: Marshal() error = %v", format, name, err)
+			}
+			reflected, err := s.Marshal(plainProfile(profile))
+			if err != nil {
+				t.Fatalf("%s, %s: Marshal() через рефлексию error = %v", format, name, err)
+			}
+			if string(generated) != string(reflected) {
+				t.Errorf("%s, %s: сгенерированный код выдал\n%s\nа рефлексия\n%s", format, name, generated, reflected)
+			}
+		}
+	}
+}
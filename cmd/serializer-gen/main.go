@@ -0,0 +1,78 @@
+// Command serializer-gen generates reflection-free JSON and TOML methods
+// for struct types, which the json and toml codecs use instead of
+// reflection.
+//
+// Usage:
+//
+//	//go:generate serializer-gen -type Order,Customer
+//
+// The input file defaults to $GOFILE, as set by go generate, and the
+// output to <file>_serializer.go next to it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct types; all structs in the file if empty")
+	output := flag.String("output", "", "output file name; default <file>_serializer.go")
+	formats := flag.String("formats", "json,toml", "comma-separated list of formats to generate")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: serializer-gen [flags] [file.go]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	filename := os.Getenv("GOFILE")
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
+	}
+	if filename == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(filename, *output, split(*typeNames), split(*formats)); err != nil {
+		fmt.Fprintf(os.Stderr, "serializer-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(filename, output string, typeNames, formats []string) error {
+	for _, f := range formats {
+		if f != "json" && f != "toml" {
+			return fmt.Errorf("unsupported format %q", f)
+		}
+	}
+
+	g, err := parseFile(filename, nil, typeNames, formats)
+	if err != nil {
+		return err
+	}
+	for _, reason := range g.skipped {
+		fmt.Fprintf(os.Stderr, "serializer-gen: %s; left to reflection\n", reason)
+	}
+	src, err := g.generate()
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(filename, ".go") + "_serializer.go"
+	}
+	return os.WriteFile(output, src, 0o644)
+}
+
+func split(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,510 @@
+// Command serializer converts, validates and queries JSON and TOML files.
+//
+// Usage:
+//
+//	serializer convert [-from format] [-to format] [-o file] [file]
+//	serializer validate [-format format] file...
+//	serializer get [-format format] file key
+//	serializer fmt [-format format] [-sort] [-indent n] [-w | -check] [file...]
+//	serializer lint [-format format] [-schema file] [-check] [file...]
+//
+// Formats are taken from file extensions unless given explicitly; "-" or
+// a missing file name reads standard input. Errors in documents are
+// reported as file:line:column. The exit code is 0 on success, 1 when a
+// document is invalid or a key is missing, and 2 on usage errors.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/saneechka/serializer"
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+const usage = `usage: serializer <command> [flags] [args]
+
+commands:
+  convert   convert a document between formats
+  validate  check that documents are well-formed
+  get       print the value at a dotted key
+  fmt       rewrite documents in canonical style
+  lint      report suspicious content in documents
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// command holds what every subcommand needs, so that run can be tested
+// without touching the process's standard streams.
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	c := &command{stdin: stdin, stdout: stdout, stderr: stderr}
+	switch args[0] {
+	case "convert":
+		return c.convert(args[1:])
+	case "validate":
+		return c.validate(args[1:])
+	case "get":
+		return c.get(args[1:])
+	case "fmt":
+		return c.format(args[1:])
+	case "lint":
+		return c.lint(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "serializer: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
+
+func (c *command) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("serializer "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+func (c *command) convert(args []string) int {
+	fs := c.flags("convert")
+	from := fs.String("from", "", "input format; taken from the file extension by default")
+	to := fs.String("to", "", "output format; taken from the -o extension by default")
+	output := fs.String("o", "", "output file; standard output by default")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		return c.usageError(errors.New("convert takes a single input file"))
+	}
+	if *to == "" && *output == "" {
+		return c.usageError(errors.New("output format unknown, use -to"))
+	}
+
+	name := fs.Arg(0)
+	in, err := formatFor(*from, name, "-from", serializer.NewStrict)
+	if err != nil {
+		return c.usageError(err)
+	}
+	out, err := formatFor(*to, *output, "-to", serializer.New)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	data, err := c.read(name)
+	if err != nil {
+		return c.fail(err)
+	}
+	var doc interface{}
+	if err := in.Unmarshal(data, &doc); err != nil {
+		return c.documentError(name, data, err)
+	}
+	if out.Format() == "TOML" {
+		if err := checkTOML(doc); err != nil {
+			return c.documentError(name, data, err)
+		}
+	}
+
+	result, err := out.Marshal(doc)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, result, 0o644); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+	c.stdout.Write(result)
+	if len(result) > 0 && result[len(result)-1] != '\n' {
+		fmt.Fprintln(c.stdout)
+	}
+	return exitOK
+}
+
+func (c *command) validate(args []string) int {
+	fs := c.flags("validate")
+	format := fs.String("format", "", "document format; taken from each file extension by default")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	code := exitOK
+	for _, name := range names {
+		s, err := formatFor(*format, name, "-format", serializer.NewStrict)
+		if err != nil {
+			return c.usageError(err)
+		}
+		data, err := c.read(name)
+		if err != nil {
+			code = c.fail(err)
+			continue
+		}
+		var doc interface{}
+		if err := s.Unmarshal(data, &doc); err != nil {
+			code = c.documentError(name, data, err)
+		}
+	}
+	return code
+}
+
+func (c *command) get(args []string) int {
+	fs := c.flags("get")
+	format := fs.String("format", "", "document format; taken from the file extension by default")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		fmt.Fprintln(c.stderr, "usage: serializer get [-format format] file key")
+		return exitUsage
+	}
+
+	name, key := fs.Arg(0), fs.Arg(1)
+	s, err := formatFor(*format, name, "-format", serializer.New)
+	if err != nil {
+		return c.usageError(err)
+	}
+
+	data, err := c.read(name)
+	if err != nil {
+		return c.fail(err)
+	}
+	var doc interface{}
+	if err := s.Unmarshal(data, &doc); err != nil {
+		return c.documentError(name, data, err)
+	}
+
+	value, ok := lookup(doc, strings.Split(key, "."))
+	if !ok {
+		return c.fail(fmt.Errorf("key %s not found in %s", key, displayName(name)))
+	}
+
+	// Strings are printed as is, so that they can be used in scripts;
+	// everything else is encoded in the format of the document.
+	if str, ok := value.(string); ok {
+		fmt.Fprintln(c.stdout, str)
+		return exitOK
+	}
+	result, err := s.Marshal(value)
+	if err != nil {
+		return c.fail(err)
+	}
+	fmt.Fprintln(c.stdout, strings.TrimSuffix(string(result), "\n"))
+	return exitOK
+}
+
+func (c *command) format(args []string) int {
+	fs := c.flags("fmt")
+	format := fs.String("format", "", "document format; taken from each file extension by default")
+	sortKeys := fs.Bool("sort", false, "sort keys instead of keeping their order")
+	indent := fs.Int("indent", 2, "JSON indentation width; 0 indents with tabs")
+	write := fs.Bool("w", false, "write the result back to the files")
+	check := fs.Bool("check", false, "list files that are not formatted and exit with 1 if there are any")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *write && *check {
+		return c.usageError(errors.New("-w and -check cannot be used together"))
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		if *write {
+			return c.usageError(errors.New("-w needs file names"))
+		}
+		names = []string{"-"}
+	}
+
+	jsonOpts := json.FormatOptions{Indent: strings.Repeat(" ", *indent), SortKeys: *sortKeys}
+	if *indent == 0 {
+		jsonOpts.Indent = "\t"
+	}
+	tomlOpts := toml.FormatOptions{SortKeys: *sortKeys}
+
+	code := exitOK
+	for _, name := range names {
+		s, err := formatFor(*format, name, "-format", serializer.New)
+		if err != nil {
+			return c.usageError(err)
+		}
+		data, err := c.read(name)
+		if err != nil {
+			code = c.fail(err)
+			continue
+		}
+
+		var result []byte
+		if s.Format() == "TOML" {
+			result, err = toml.Format(data, tomlOpts)
+		} else {
+			result, err = json.Format(data, jsonOpts)
+		}
+		if err != nil {
+			code = c.documentError(name, data, err)
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(data, result) {
+				fmt.Fprintln(c.stdout, displayName(name))
+				code = exitInvalid
+			}
+		case *write:
+			if bytes.Equal(data, result) {
+				continue
+			}
+			info, err := os.Stat(name)
+			if err == nil {
+				err = os.WriteFile(name, result, info.Mode().Perm())
+			}
+			if err != nil {
+				code = c.fail(err)
+			}
+		default:
+			c.stdout.Write(result)
+		}
+	}
+	return code
+}
+
+func (c *command) lint(args []string) int {
+	fs := c.flags("lint")
+	format := fs.String("format", "", "document format; taken from each file extension by default")
+	schemaFile := fs.String("schema", "", "document listing the allowed keys")
+	check := fs.Bool("check", false, "exit with 1 if any issue is found")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var schema interface{}
+	if *schemaFile != "" {
+		s, err := formatFor("", *schemaFile, "a .json or .toml schema", serializer.New)
+		if err != nil {
+			return c.usageError(err)
+		}
+		data, err := c.read(*schemaFile)
+		if err != nil {
+			return c.usageError(err)
+		}
+		if err := s.Unmarshal(data, &schema); err != nil {
+			c.documentError(*schemaFile, data, err)
+			return exitUsage
+		}
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	code := exitOK
+	for _, name := range names {
+		s, err := formatFor(*format, name, "-format", serializer.New)
+		if err != nil {
+			return c.usageError(err)
+		}
+		data, err := c.read(name)
+		if err != nil {
+			code = c.fail(err)
+			continue
+		}
+
+		found := 0
+		if s.Format() == "TOML" {
+			var issues []toml.Issue
+			issues, err = toml.Lint(data, toml.LintOptions{Schema: schema})
+			for _, issue := range issues {
+				fmt.Fprintf(c.stdout, "%s:%d:%d: %s\n", displayName(name), issue.Line, issue.Column, issue.Message)
+			}
+			found = len(issues)
+		} else {
+			var issues []json.Issue
+			issues, err = json.Lint(data, json.LintOptions{Schema: schema})
+			for _, issue := range issues {
+				line, col := position(data, int(issue.Offset))
+				fmt.Fprintf(c.stdout, "%s:%d:%d: %s\n", displayName(name), line, col, issue.Message)
+			}
+			found = len(issues)
+		}
+		if err != nil {
+			code = c.documentError(name, data, err)
+			continue
+		}
+		if *check && found > 0 {
+			code = exitInvalid
+		}
+	}
+	return code
+}
+
+// formatFor returns the serializer named by format, or the one matching
+// the extension of name when format is empty, made by newSerializer.
+func formatFor(format, name, flagName string, newSerializer func(string) (serializer.Serializer, error)) (serializer.Serializer, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(name), ".")
+		if format == "" {
+			return nil, fmt.Errorf("cannot determine format of %s, use %s", displayName(name), flagName)
+		}
+	}
+	s, err := newSerializer(strings.ToLower(format))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, format)
+	}
+	return s, nil
+}
+
+func (c *command) read(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func (c *command) usageError(err error) int {
+	fmt.Fprintf(c.stderr, "serializer: %v\n", err)
+	return exitUsage
+}
+
+func (c *command) fail(err error) int {
+	fmt.Fprintf(c.stderr, "serializer: %v\n", err)
+	return exitInvalid
+}
+
+// documentError reports err as file:line:column when it carries a
+// position.
+func (c *command) documentError(name string, data []byte, err error) int {
+	var jsonErr *json.SyntaxError
+	var tomlErr *toml.SyntaxError
+	switch {
+	case errors.As(err, &jsonErr):
+		line, col := position(data, int(jsonErr.Offset))
+		fmt.Fprintf(c.stderr, "%s:%d:%d: %v\n", displayName(name), line, col, jsonErr)
+	case errors.As(err, &tomlErr):
+		fmt.Fprintf(c.stderr, "%s:%d:%d: %s\n", displayName(name), tomlErr.Line, tomlErr.Column, tomlErr.Msg)
+	default:
+		fmt.Fprintf(c.stderr, "%s: %v\n", displayName(name), err)
+	}
+	return exitInvalid
+}
+
+// position converts a byte offset into a 1-based line and column, the
+// column counting characters.
+func position(data []byte, offset int) (line, col int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := string(data[:offset])
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return strings.Count(before, "\n") + 1, len([]rune(before[lineStart:])) + 1
+}
+
+func displayName(name string) string {
+	if name == "" || name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+// checkTOML reports why a decoded document cannot be written as TOML,
+// whose documents are tables and which has no null.
+func checkTOML(doc interface{}) error {
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return fmt.Errorf("cannot convert %s to TOML: the document must be a table", describe(doc))
+	}
+	return checkNull(doc, "")
+}
+
+func checkNull(value interface{}, path string) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("cannot convert null at %s to TOML", path)
+	case map[string]interface{}:
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if err := checkNull(v[key], joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			if err := checkNull(e, joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describe(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
+
+// lookup follows a dotted path through decoded tables and arrays, arrays
+// being indexed by number.
+func lookup(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
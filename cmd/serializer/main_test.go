@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/saneechka/serializer/json"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const config = `title = "test"
+
+[server]
+host = "localhost"
+ports = [8080, 8081]
+
+[[users]]
+name = "admin"
+`
+
+func TestConvert(t *testing.T) {
+	path := writeFile(t, "config.toml", config)
+
+	code, stdout, stderr := runCLI(t, "", "convert", "-to", "json", path)
+	if code != exitOK {
+		t.Fatalf("код выхода = %d, stderr: %s", code, stderr)
+	}
+	var got map[string]interface{}
+	if err := json.New().Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("convert выдал некорректный JSON %s: %v", stdout, err)
+	}
+	want := map[string]interface{}{
+		"title":  "test",
+		"server": map[string]interface{}{"host": "localhost", "ports": []interface{}{int64(8080), int64(8081)}},
+		"users":  []interface{}{map[string]interface{}{"name": "admin"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("convert = %s", stdout)
+	}
+
+	// Обратное преобразование через stdin и запись в файл
+	output := filepath.Join(t.TempDir(), "config.toml")
+	code, _, stderr = runCLI(t, stdout, "convert", "-from", "json", "-o", output)
+	if code != exitOK {
+		t.Fatalf("код выхода = %d, stderr: %s", code, stderr)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[[users]]") || !strings.Contains(string(data), `host = "localhost"`) {
+		t.Errorf("convert в TOML = %s", data)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := writeFile(t, "valid.toml", config)
+	invalid := writeFile(t, "invalid.json", "{\n  \"a\": [1, 2,, 3]\n}")
+
+	if code, _, stderr := runCLI(t, "", "validate", valid); code != exitOK {
+		t.Errorf("validate корректного файла: код %d, stderr: %s", code, stderr)
+	}
+
+	code, _, stderr := runCLI(t, "", "validate", valid, invalid)
+	if code != exitInvalid {
+		t.Errorf("validate некорректного файла: код %d, want %d", code, exitInvalid)
+	}
+	if !strings.HasPrefix(stderr, invalid+":2:14: ") {
+		t.Errorf("ошибка должна содержать позицию, получено %q", stderr)
+	}
+
+	code, _, stderr = runCLI(t, "a = 1\nb = \"oops\n", "validate", "-format", "toml")
+	if code != exitInvalid || !strings.HasPrefix(stderr, "<stdin>:2:5: ") {
+		t.Errorf("validate stdin: код %d, stderr %q", code, stderr)
+	}
+
+	// Проверка строгая: данные после документа и повторяющиеся ключи
+	// недопустимы.
+	for _, tt := range []struct{ format, input string }{
+		{"json", `{"a":1} garbage`},
+		{"json", `{"a":1,"a":2}`},
+		{"toml", "a = 1\na = 2\n"},
+	} {
+		if code, _, _ := runCLI(t, tt.input, "validate", "-format", tt.format); code != exitInvalid {
+			t.Errorf("validate %q: код %d, want %d", tt.input, code, exitInvalid)
+		}
+	}
+}
+
+func TestConvertToTOMLErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`[1, 2]`, "the document must be a table"},
+		{`"text"`, "the document must be a table"},
+		{`{"b":[1,null]}`, "null at b.1"},
+		{`{"a":{"b":null}}`, "null at a.b"},
+		{`{"a":1} garbage`, "unexpected data after top-level value"},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := runCLI(t, tt.input, "convert", "-from", "json", "-to", "toml")
+		if code != exitInvalid {
+			t.Errorf("convert %s: код %d, want %d, вывод %q", tt.input, code, exitInvalid, stdout)
+		}
+		if !strings.Contains(stderr, tt.want) {
+			t.Errorf("convert %s: stderr = %q, want %q", tt.input, stderr, tt.want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	path := writeFile(t, "config.toml", config)
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"title", "test"},
+		{"server.ports", "[8080, 8081]"},
+		{"server.ports.1", "8081"},
+		{"users.0.name", "admin"},
+		{"server", "host = \"localhost\"\nports = [8080, 8081]"},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := runCLI(t, "", "get", path, tt.key)
+		if code != exitOK {
+			t.Errorf("get %s: код %d, stderr: %s", tt.key, code, stderr)
+			continue
+		}
+		// Порядок ключей таблицы не проверяется.
+		if sortedLines(stdout) != sortedLines(tt.want+"\n") {
+			t.Errorf("get %s = %q, want %q", tt.key, stdout, tt.want)
+		}
+	}
+
+	if code, _, _ := runCLI(t, "", "get", path, "server.missing"); code != exitInvalid {
+		t.Errorf("get отсутствующего ключа: код %d, want %d", code, exitInvalid)
+	}
+}
+
+func sortedLines(s string) string {
+	lines := strings.Split(s, "\n")
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestFmt(t *testing.T) {
+	path := writeFile(t, "data.json", `{"b":1,"a":[1,2]}`)
+
+	code, stdout, stderr := runCLI(t, "", "fmt", "-sort", "-indent", "4", path)
+	if code != exitOK {
+		t.Fatalf("код выхода = %d, stderr: %s", code, stderr)
+	}
+	want := "{\n    \"a\": [\n        1,\n        2\n    ],\n    \"b\": 1\n}\n"
+	if stdout != want {
+		t.Errorf("fmt = %q, want %q", stdout, want)
+	}
+
+	code, stdout, _ = runCLI(t, "", "fmt", "-check", path)
+	if code != exitInvalid || stdout != path+"\n" {
+		t.Errorf("fmt -check неформатированного файла: код %d, вывод %q", code, stdout)
+	}
+
+	if code, _, stderr := runCLI(t, "", "fmt", "-w", path); code != exitOK {
+		t.Fatalf("fmt -w: код %d, stderr: %s", code, stderr)
+	}
+	if code, stdout, _ := runCLI(t, "", "fmt", "-check", path); code != exitOK || stdout != "" {
+		t.Errorf("fmt -check после -w: код %d, вывод %q", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "a='x'\n", "fmt", "-format", "toml")
+	if code != exitOK || stdout != "a = \"x\"\n" {
+		t.Errorf("fmt stdin: код %d, вывод %q", code, stdout)
+	}
+
+	code, _, stderr = runCLI(t, "{\"a\":}", "fmt", "-format", "json")
+	if code != exitInvalid || !strings.HasPrefix(stderr, "<stdin>:1:6: ") {
+		t.Errorf("fmt неверного документа: код %d, stderr %q", code, stderr)
+	}
+}
+
+func TestLint(t *testing.T) {
+	path := writeFile(t, "config.toml", "name = \"x\"\nname = \"y\"\nextra = [1, \"a\"]\n")
+	schema := writeFile(t, "schema.json", `{"name": ""}`)
+
+	code, stdout, stderr := runCLI(t, "", "lint", "-schema", schema, path)
+	if code != exitOK {
+		t.Fatalf("код выхода = %d, stderr: %s", code, stderr)
+	}
+	want := path + ":2:1: duplicate key \"name\"\n" +
+		path + ":3:1: key \"extra\" is not in the schema\n" +
+		path + ":3:9: array \"extra\" mixes integer and string\n"
+	if stdout != want {
+		t.Errorf("lint =\n%s\nwant\n%s", stdout, want)
+	}
+
+	if code, _, _ := runCLI(t, "", "lint", "-check", path); code != exitInvalid {
+		t.Errorf("lint -check с замечаниями: код %d, want %d", code, exitInvalid)
+	}
+	if code, _, _ := runCLI(t, `{"a": 1}`, "lint", "-check", "-format", "json"); code != exitOK {
+		t.Errorf("lint -check без замечаний: код %d, want %d", code, exitOK)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"unknown"},
+		{"convert", "config"},
+		{"convert", "-to", "yaml", "config.json"},
+		{"get", "config.json"},
+		{"validate", "-bad-flag"},
+		{"fmt", "-w"},
+		{"fmt", "-w", "-check", "config.json"},
+		{"lint", "-schema", "schema.yaml", "config.json"},
+	}
+	for _, args := range tests {
+		if code, _, _ := runCLI(t, "", args...); code != exitUsage {
+			t.Errorf("%v: код %d, want %d", args, code, exitUsage)
+		}
+	}
+}
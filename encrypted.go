@@ -0,0 +1,228 @@
+package serializer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher identifies the AEAD algorithm used to seal a payload.
+type Cipher byte
+
+const (
+	AESGCM Cipher = iota + 1
+	ChaCha20Poly1305
+)
+
+const (
+	encryptedMagic   = "SENC"
+	encryptedVersion = 1
+	maxKeyIDLength   = 255
+)
+
+type keyringEntry struct {
+	cipher Cipher
+	aead   cipher.AEAD
+}
+
+// Keyring holds the keys an encrypted serializer may open payloads with.
+// New payloads are always sealed with the primary key, so keys can be
+// rotated by adding a new one and making it primary while old payloads
+// remain readable.
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]keyringEntry
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]keyringEntry)}
+}
+
+// Add registers a key under id. The first key added becomes the primary one.
+// An id can only be added once, so that a key is never replaced by mistake.
+func (k *Keyring) Add(id string, c Cipher, secret []byte) error {
+	if id == "" || len(id) > maxKeyIDLength {
+		return fmt.Errorf("%w: key id must be 1-%d bytes", ErrInvalidKey, maxKeyIDLength)
+	}
+
+	aead, err := newAEAD(c, secret)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("%w: key id %q is already in use", ErrInvalidKey, id)
+	}
+	k.keys[id] = keyringEntry{cipher: c, aead: aead}
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+func (k *Keyring) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	k.primary = id
+	return nil
+}
+
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+func (k *Keyring) lookup(id string) (keyringEntry, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	entry, ok := k.keys[id]
+	return entry, ok
+}
+
+func newAEAD(c Cipher, secret []byte) (cipher.AEAD, error) {
+	switch c {
+	case AESGCM:
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		aead, err := chacha20poly1305.New(secret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return aead, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown cipher %d", ErrInvalidKey, c)
+	}
+}
+
+// EncryptedSerializer seals the output of another serializer.
+//
+// Payload layout:
+//
+//	"SENC" | version (1) | cipher (1) | key id length (1) | key id | nonce | ciphertext
+//
+// Everything before the nonce is authenticated as additional data.
+type EncryptedSerializer struct {
+	inner   Serializer
+	keyring *Keyring
+}
+
+func Encrypted(inner Serializer, keyring *Keyring) *EncryptedSerializer {
+	return &EncryptedSerializer{inner: inner, keyring: keyring}
+}
+
+func NewEncrypted(format string, keyring *Keyring) (*EncryptedSerializer, error) {
+	if keyring == nil {
+		return nil, errNilKeyring
+	}
+	s, err := New(format)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypted(s, keyring), nil
+}
+
+// errNilKeyring is returned by serializers created without a keyring.
+var errNilKeyring = fmt.Errorf("%w: nil keyring", ErrUnknownKey)
+
+func (e *EncryptedSerializer) Marshal(v any) ([]byte, error) {
+	if e.keyring == nil {
+		return nil, errNilKeyring
+	}
+	plaintext, err := e.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	id := e.keyring.Primary()
+	entry, ok := e.keyring.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: keyring has no primary key", ErrUnknownKey)
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+3+len(id))
+	header = append(header, encryptedMagic...)
+	header = append(header, encryptedVersion, byte(entry.cipher), byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, entry.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+entry.aead.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return entry.aead.Seal(out, nonce, plaintext, header), nil
+}
+
+func (e *EncryptedSerializer) Unmarshal(data []byte, v any) error {
+	plaintext, err := e.open(data)
+	if err != nil {
+		return err
+	}
+	return e.inner.Unmarshal(plaintext, v)
+}
+
+func (e *EncryptedSerializer) open(data []byte) ([]byte, error) {
+	if e.keyring == nil {
+		return nil, errNilKeyring
+	}
+	fixed := len(encryptedMagic) + 3
+	if len(data) < fixed || string(data[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, ErrInvalidCiphertext
+	}
+
+	version := data[len(encryptedMagic)]
+	if version != encryptedVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	c := Cipher(data[len(encryptedMagic)+1])
+	idLen := int(data[len(encryptedMagic)+2])
+	if len(data) < fixed+idLen {
+		return nil, ErrInvalidCiphertext
+	}
+	id := string(data[fixed : fixed+idLen])
+
+	entry, ok := e.keyring.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	if entry.cipher != c {
+		return nil, ErrInvalidCiphertext
+	}
+
+	header := data[:fixed+idLen]
+	rest := data[fixed+idLen:]
+	nonceSize := entry.aead.NonceSize()
+	if len(rest) < nonceSize+entry.aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	plaintext, err := entry.aead.Open(nil, rest[:nonceSize], rest[nonceSize:], header)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+func (e *EncryptedSerializer) Format() string {
+	return e.inner.Format()
+}
@@ -0,0 +1,152 @@
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type encryptedConfig struct {
+	Name   string `json:"name" toml:"name"`
+	Secret string `json:"secret" toml:"secret"`
+}
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	keyring := NewKeyring()
+	if err := keyring.Add("k1", AESGCM, bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	return keyring
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+		keyring := NewKeyring()
+		if err := keyring.Add("main", c, bytes.Repeat([]byte{7}, 32)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+
+		s, err := NewEncrypted("json", keyring)
+		if err != nil {
+			t.Fatalf("NewEncrypted() error = %v", err)
+		}
+
+		original := encryptedConfig{Name: "db", Secret: "пароль"}
+		data, err := s.Marshal(original)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if bytes.Contains(data, []byte("пароль")) {
+			t.Errorf("Marshal() output contains plaintext: %q", data)
+		}
+
+		var result encryptedConfig
+		if err := s.Unmarshal(data, &result); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if result != original {
+			t.Errorf("Unmarshal() = %v, want %v", result, original)
+		}
+	}
+}
+
+func TestEncryptedTampered(t *testing.T) {
+	s := Encrypted(mustNew(t, "json"), testKeyring(t))
+
+	data, err := s.Marshal(encryptedConfig{Name: "db"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	for _, i := range []int{len(encryptedMagic) + 3, len(data) - 1} {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0xff
+
+		var result encryptedConfig
+		if err := s.Unmarshal(tampered, &result); err == nil {
+			t.Errorf("Unmarshal() с изменённым байтом %d должен возвращать ошибку", i)
+		}
+	}
+
+	var result encryptedConfig
+	if err := s.Unmarshal([]byte(`{"name":"db"}`), &result); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Unmarshal(plaintext) error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
+
+func TestEncryptedKeyRotation(t *testing.T) {
+	keyring := testKeyring(t)
+	s := Encrypted(mustNew(t, "toml"), keyring)
+
+	old, err := s.Marshal(encryptedConfig{Name: "old"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if err := keyring.Add("k2", ChaCha20Poly1305, bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := keyring.SetPrimary("k2"); err != nil {
+		t.Fatalf("SetPrimary() error = %v", err)
+	}
+
+	var result encryptedConfig
+	if err := s.Unmarshal(old, &result); err != nil {
+		t.Fatalf("Unmarshal() старого ключа error = %v", err)
+	}
+
+	other := Encrypted(mustNew(t, "toml"), NewKeyring())
+	if err := other.Unmarshal(old, &result); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	if err := keyring.SetPrimary("missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("SetPrimary() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestKeyringInvalidKey(t *testing.T) {
+	keyring := NewKeyring()
+	if err := keyring.Add("short", AESGCM, []byte("short")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Add() error = %v, want %v", err, ErrInvalidKey)
+	}
+	if err := keyring.Add("", AESGCM, bytes.Repeat([]byte{1}, 16)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Add() error = %v, want %v", err, ErrInvalidKey)
+	}
+
+	// Повторный id не заменяет существующий ключ.
+	if err := keyring.Add("k1", AESGCM, bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := keyring.Add("k1", ChaCha20Poly1305, bytes.Repeat([]byte{2}, 32)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Add() повторного id error = %v, want %v", err, ErrInvalidKey)
+	}
+	if entry, _ := keyring.lookup("k1"); entry.cipher != AESGCM {
+		t.Errorf("ключ k1 заменён")
+	}
+}
+
+func TestEncryptedNilKeyring(t *testing.T) {
+	if _, err := NewEncrypted("json", nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("NewEncrypted() error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	s := Encrypted(mustNew(t, "json"), nil)
+	if _, err := s.Marshal(encryptedConfig{Name: "db"}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Marshal() error = %v, want %v", err, ErrUnknownKey)
+	}
+	var result encryptedConfig
+	if err := s.Unmarshal([]byte("SENC\x01\x01\x01k"), &result); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func mustNew(t *testing.T, format string) Serializer {
+	t.Helper()
+	s, err := New(format)
+	if err != nil {
+		t.Fatalf("New(%q) error = %v", format, err)
+	}
+	return s
+}
@@ -1,9 +1,15 @@
 package gin
 
 import (
+	"errors"
+	"io"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/saneechka/serializer"
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
 )
 
 func MyBindJSON(c *gin.Context, obj any) error {
@@ -34,6 +40,103 @@ func MyBindTOML(c *gin.Context, obj any) error {
 	return s.Unmarshal(data, obj)
 }
 
+// MyBindJSONWith is like MyBindJSON, decoding with the given options,
+// which combine: json.Strict for public APIs, json.WithLimits for
+// untrusted clients and json.CollectErrors to report every field. With a
+// MaxInputSize limit the body is read no further than that. Use
+// BindStatus to pick the response code and FieldErrors to render the
+// errors.
+func MyBindJSONWith(c *gin.Context, obj any, opts ...json.Option) error {
+	s := json.New(opts...)
+	data, err := readBody(c, s.Limits().MaxInputSize)
+	if err != nil {
+		return err
+	}
+	return s.Unmarshal(data, obj)
+}
+
+// MyBindTOMLWith is like MyBindJSONWith for TOML bodies.
+func MyBindTOMLWith(c *gin.Context, obj any, opts ...toml.Option) error {
+	s := toml.New(opts...)
+	data, err := readBody(c, s.Limits().MaxInputSize)
+	if err != nil {
+		return err
+	}
+	return s.Unmarshal(data, obj)
+}
+
+// FieldError describes one problem with a request body, for sending back
+// to the client. Offset is set for JSON bodies, Line and Column for TOML.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Offset  int64  `json:"offset,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// FieldErrors lists the errors held by a *json.MultiError or
+// *toml.MultiError from the bind helpers. Any other error is returned as a
+// single FieldError.
+func FieldErrors(err error) []FieldError {
+	errs := []error{err}
+	var jsonErr *json.MultiError
+	var tomlErr *toml.MultiError
+	switch {
+	case errors.As(err, &jsonErr):
+		errs = jsonErr.Errors
+	case errors.As(err, &tomlErr):
+		errs = tomlErr.Errors
+	}
+
+	fields := make([]FieldError, len(errs))
+	for i, err := range errs {
+		f := FieldError{Message: err.Error()}
+		switch e := err.(type) {
+		case *json.TypeError:
+			f.Field, f.Offset = e.Field, e.Offset
+		case *json.MissingFieldError:
+			f.Field, f.Offset = e.Field, e.Offset
+		case *json.UnknownFieldError:
+			f.Field, f.Offset = e.Field, e.Offset
+		case *json.AmbiguousFieldError:
+			f.Field, f.Offset = e.Field, e.Offset
+		case *toml.TypeError:
+			f.Field, f.Line, f.Column = e.Field, e.Line, e.Column
+		case *toml.MissingFieldError:
+			f.Field, f.Line, f.Column = e.Field, e.Line, e.Column
+		case *toml.UnknownFieldError:
+			f.Field, f.Line, f.Column = e.Field, e.Line, e.Column
+		case *toml.AmbiguousFieldError:
+			f.Field, f.Line, f.Column = e.Field, e.Line, e.Column
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+// readBody reads the request body, stopping one byte past max so that an
+// oversized body is rejected without reading all of it.
+func readBody(c *gin.Context, max int) ([]byte, error) {
+	if max <= 0 {
+		return c.GetRawData()
+	}
+	return io.ReadAll(io.LimitReader(c.Request.Body, int64(max)+1))
+}
+
+// BindStatus returns the HTTP status for an error from the bind helpers:
+// 413 when the body is over the input size limit and 400 otherwise.
+func BindStatus(err error) int {
+	var jsonErr *json.LimitError
+	var tomlErr *toml.LimitError
+	switch {
+	case errors.As(err, &jsonErr) && jsonErr.Limit == "input size",
+		errors.As(err, &tomlErr) && tomlErr.Limit == "input size":
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
 func MyJSON(c *gin.Context, code int, obj any) error {
 	s, err := serializer.New("json")
 	if err != nil {
@@ -3,11 +3,16 @@ package gin
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	sjson "github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
 )
 
 func init() {
@@ -99,3 +104,148 @@ func TestTOML(t *testing.T) {
 		t.Errorf("TOML() = %v, want %v", result, testUser)
 	}
 }
+
+func jsonWith(opts ...sjson.Option) func(*gin.Context, any) error {
+	return func(c *gin.Context, v any) error { return MyBindJSONWith(c, v, opts...) }
+}
+
+func tomlWith(opts ...toml.Option) func(*gin.Context, any) error {
+	return func(c *gin.Context, v any) error { return MyBindTOMLWith(c, v, opts...) }
+}
+
+func TestBindStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		bind func(*gin.Context, any) error
+		body string
+	}{
+		{"json", jsonWith(sjson.Strict()), `{"id": 1, "role": "admin"}`},
+		{"json trailing", jsonWith(sjson.Strict()), `{"id": 1} {"id": 2}`},
+		{"toml", tomlWith(toml.Strict()), "id = 1\nrole = \"admin\"\n"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(tt.body))
+
+		var result TestUser
+		if err := tt.bind(c, &result); err == nil {
+			t.Errorf("%s: ожидалась ошибка для %q", tt.name, tt.body)
+		}
+	}
+}
+
+func TestBindLimited(t *testing.T) {
+	jsonLimits := sjson.Limits{MaxInputSize: 64, MaxStringLength: 8}
+	tomlLimits := toml.Limits{MaxInputSize: 64, MaxStringLength: 8}
+	tests := []struct {
+		name   string
+		bind   func(*gin.Context, any) error
+		body   string
+		status int
+	}{
+		{"json", jsonWith(sjson.WithLimits(jsonLimits)), `{"id": 1, "name": "Иван"}`, 0},
+		{"json большое тело", jsonWith(sjson.WithLimits(jsonLimits)), `{"name": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge},
+		{"json длинная строка", jsonWith(sjson.WithLimits(jsonLimits)), `{"name": "abcdefghij"}`, http.StatusBadRequest},
+		{"toml", tomlWith(toml.WithLimits(tomlLimits)), "id = 1\nname = \"Иван\"\n", 0},
+		{"toml большое тело", tomlWith(toml.WithLimits(tomlLimits)), "name = \"" + strings.Repeat("a", 100) + "\"\n", http.StatusRequestEntityTooLarge},
+		{"toml длинная строка", tomlWith(toml.WithLimits(tomlLimits)), "name = \"abcdefghij\"\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(tt.body))
+
+		var result TestUser
+		err := tt.bind(c, &result)
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%s: error = %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, sjson.ErrLimitExceeded) && !errors.Is(err, toml.ErrLimitExceeded) {
+			t.Errorf("%s: error = %v, want ErrLimitExceeded", tt.name, err)
+		}
+		if got := BindStatus(err); got != tt.status {
+			t.Errorf("%s: BindStatus() = %d, want %d", tt.name, got, tt.status)
+		}
+	}
+}
+
+func TestBindCollect(t *testing.T) {
+	tests := []struct {
+		name string
+		bind func(*gin.Context, any) error
+		body string
+		want []FieldError
+	}{
+		{"json", jsonWith(sjson.CollectErrors()), `{"id": "1", "name": "Иван", "email": 5}`, []FieldError{
+			{Field: "id", Message: "cannot unmarshal string into field id of type int", Offset: 7},
+			{Field: "email", Message: "cannot unmarshal number 5 into field email of type string", Offset: 41},
+		}},
+		{"toml", tomlWith(toml.CollectErrors()), "id = \"1\"\nname = \"Иван\"\nemail = 5\n", []FieldError{
+			{Field: "id", Message: "cannot unmarshal string into field id of type int", Line: 1, Column: 1},
+			{Field: "email", Message: "cannot unmarshal integer 5 into field email of type string", Line: 3, Column: 1},
+		}},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(tt.body))
+
+		var result TestUser
+		err := tt.bind(c, &result)
+		if err == nil {
+			t.Fatalf("%s: ожидалась ошибка", tt.name)
+		}
+		if result.Name != "Иван" || result.ID != 0 || result.Email != "" {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+		got := FieldErrors(err)
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: FieldErrors() = %+v, want %+v", tt.name, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: FieldErrors()[%d] = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+// Опции сочетаются: строгий режим, ограничения и сбор ошибок вместе.
+func TestBindWithCombinedOptions(t *testing.T) {
+	jsonOpts := []sjson.Option{sjson.Strict(), sjson.WithLimits(sjson.Limits{MaxInputSize: 64}), sjson.CollectErrors()}
+	tomlOpts := []toml.Option{toml.Strict(), toml.WithLimits(toml.Limits{MaxInputSize: 64}), toml.CollectErrors()}
+	tests := []struct {
+		name   string
+		bind   func(*gin.Context, any) error
+		body   string
+		status int
+		fields []string
+	}{
+		{"json", jsonWith(jsonOpts...), `{"id": "1", "role": "admin"}`, http.StatusBadRequest, []string{"id", "role"}},
+		{"json большое тело", jsonWith(jsonOpts...), `{"name": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, nil},
+		{"toml", tomlWith(tomlOpts...), "id = \"1\"\nrole = \"admin\"\n", http.StatusBadRequest, []string{"role", "id"}},
+		{"toml большое тело", tomlWith(tomlOpts...), "name = \"" + strings.Repeat("a", 100) + "\"\n", http.StatusRequestEntityTooLarge, nil},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(tt.body))
+
+		var result TestUser
+		err := tt.bind(c, &result)
+		if got := BindStatus(err); err == nil || got != tt.status {
+			t.Errorf("%s: error = %v, BindStatus() = %d, want %d", tt.name, err, got, tt.status)
+			continue
+		}
+		if tt.fields == nil {
+			continue
+		}
+		var fields []string
+		for _, f := range FieldErrors(err) {
+			fields = append(fields, f.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%s: поля ошибок %q, want %q", tt.name, fields, tt.fields)
+		}
+	}
+}
@@ -19,5 +19,11 @@ func (e *Error) Error() string {
 }
 
 var (
-	ErrUnsupportedFormat = NewError("unsupported serialization format")
+	ErrUnsupportedFormat  = NewError("unsupported serialization format")
+	ErrInvalidKey         = NewError("invalid encryption key")
+	ErrUnknownKey         = NewError("unknown encryption key")
+	ErrInvalidCiphertext  = NewError("invalid or corrupted ciphertext")
+	ErrUnsupportedVersion = NewError("unsupported payload version")
+	ErrNoMigration        = NewError("no migration path for payload version")
+	ErrNewerVersion       = NewError("payload version is newer than supported")
 )
@@ -0,0 +1,183 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"testing"
+)
+
+type benchItem struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Price float64  `json:"price"`
+	Tags  []string `json:"tags"`
+	Note  string   `json:"note"`
+}
+
+type benchPayload struct {
+	Page  int         `json:"page"`
+	Total int         `json:"total"`
+	Items []benchItem `json:"items"`
+}
+
+func newBenchPayload() benchPayload {
+	p := benchPayload{Page: 1, Total: 100}
+	for i := 0; i < 100; i++ {
+		p.Items = append(p.Items, benchItem{
+			ID:    int64(i),
+			Name:  "item \"quoted\" name",
+			Price: 19.99,
+			Tags:  []string{"new", "sale", "popular"},
+			Note:  "line one\nline two\twith tab",
+		})
+	}
+	return p
+}
+
+func TestMarshalMatchesEncodingJSON(t *testing.T) {
+	payload := newBenchPayload()
+
+	got, err := New().Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want, err := stdjson.Marshal(payload)
+	if err != nil {
+		t.Fatalf("encoding/json Marshal() error = %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
+
+func TestUnmarshalAllocsBelowEncodingJSON(t *testing.T) {
+	data, err := stdjson.Marshal(newBenchPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New()
+
+	got := testing.AllocsPerRun(20, func() {
+		var payload benchPayload
+		if err := s.Unmarshal(data, &payload); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := testing.AllocsPerRun(20, func() {
+		var payload benchPayload
+		if err := stdjson.Unmarshal(data, &payload); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got >= want {
+		t.Errorf("Unmarshal() allocs = %v, encoding/json = %v", got, want)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	payload := newBenchPayload()
+	s := New()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Marshal(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	payload := newBenchPayload()
+	s := New()
+	var buf []byte
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = s.Append(buf[:0], payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalEncodingJSON(b *testing.B) {
+	payload := newBenchPayload()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := stdjson.Marshal(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := stdjson.Marshal(newBenchPayload())
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := New()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var payload benchPayload
+		if err := s.Unmarshal(data, &payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalInterface(b *testing.B) {
+	data, err := stdjson.Marshal(newBenchPayload())
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := New()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var payload any
+		if err := s.Unmarshal(data, &payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalEncodingJSON(b *testing.B) {
+	data, err := stdjson.Marshal(newBenchPayload())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var payload benchPayload
+		if err := stdjson.Unmarshal(data, &payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type benchNode struct {
+	Value int        `json:"value"`
+	Next  *benchNode `json:"next"`
+}
+
+// BenchmarkMarshalDeep encodes a long chain of pointers, where tracking
+// the open pointers must stay cheap as the chain grows.
+func BenchmarkMarshalDeep(b *testing.B) {
+	var head *benchNode
+	for i := 0; i < 5000; i++ {
+		head = &benchNode{Value: i, Next: head}
+	}
+	s := New()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Marshal(head); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
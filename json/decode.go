@@ -0,0 +1,1238 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeState reads JSON directly from the input bytes and stores values
+// straight into the target, without building an intermediate tree. Only
+// interface{} targets get generic maps and slices.
+type decodeState struct {
+	s     *JSONSerializer
+	data  []byte
+	off   int
+	depth int
+
+	// values counts the array elements and object members read so far,
+	// for Limits.MaxAllocations.
+	values int
+
+	// errs holds the field errors recorded with CollectErrors. Their paths
+	// are relative to the innermost object or array being read, and are
+	// completed as each one ends.
+	errs []error
+
+	// reader is handed to Decoder implementations, so that nested calls
+	// do not allocate a Reader each.
+	reader Reader
+
+	// rawKey is the current object key as written in the input, for
+	// Format and Lint.
+	rawKey []byte
+
+	scratch []byte
+
+	// strings, when set, shares the allocations of repeated short strings.
+	strings *stringCache
+}
+
+// stringCache holds recently decoded short strings, indexed by hash, so
+// that values repeated across a document, such as tags and enum-like
+// fields, are allocated once per Unmarshal.
+type stringCache [256]string
+
+// maxCachedString is the longest string kept in a stringCache.
+const maxCachedString = 16
+
+var stringCachePool = sync.Pool{
+	New: func() any {
+		return new(stringCache)
+	},
+}
+
+// makeString returns b as a string, reusing a cached copy when there is
+// one.
+func (d *decodeState) makeString(b []byte) string {
+	if d.strings == nil || len(b) == 0 || len(b) > maxCachedString {
+		return string(b)
+	}
+	// FNV-1a.
+	h := uint32(2166136261)
+	for _, c := range b {
+		h = (h ^ uint32(c)) * 16777619
+	}
+	slot := &d.strings[h%uint32(len(d.strings))]
+	if *slot == string(b) {
+		return *slot
+	}
+	*slot = string(b)
+	return *slot
+}
+
+func (s *JSONSerializer) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer")
+	}
+
+	if max := s.limits.MaxInputSize; max > 0 && len(data) > max {
+		return &LimitError{Limit: "input size", Max: max, Offset: int64(max)}
+	}
+	d := &decodeState{s: s, data: data, strings: stringCachePool.Get().(*stringCache)}
+	defer stringCachePool.Put(d.strings)
+	start := d.off
+	if err := d.collectError(d.value(rv.Elem()), start, rv.Elem()); err != nil {
+		return err
+	}
+	if s.strict {
+		if d.skipWhitespace(); d.off < len(d.data) {
+			return d.syntaxError("unexpected data after top-level value")
+		}
+	}
+	if len(d.errs) > 0 {
+		return &MultiError{Errors: d.errs}
+	}
+	return nil
+}
+
+// collectError records err, the result of decoding the value at start
+// into rv, when it is a field error and the serializer collects errors.
+// The rest of the value is skipped and rv, if valid, left zero. Other
+// errors are returned as is.
+func (d *decodeState) collectError(err error, start int, rv reflect.Value) error {
+	if err == nil || !d.s.collectErrors {
+		return err
+	}
+	switch err.(type) {
+	case *TypeError, *MissingFieldError, *UnknownFieldError, *AmbiguousFieldError:
+	default:
+		return err
+	}
+	d.off = start
+	if err := d.skip(); err != nil {
+		return err
+	}
+	if rv.IsValid() {
+		rv.SetZero()
+	}
+	d.errs = append(d.errs, err)
+	return nil
+}
+
+func (d *decodeState) skipWhitespace() {
+	for d.off < len(d.data) {
+		switch d.data[d.off] {
+		case ' ', '\t', '\n', '\r':
+			d.off++
+		default:
+			return
+		}
+	}
+}
+
+// peek skips whitespace and returns the next byte without consuming it.
+func (d *decodeState) peek() (byte, error) {
+	d.skipWhitespace()
+	if d.off >= len(d.data) {
+		return 0, &SyntaxError{msg: fmt.Sprintf("unexpected end of input at offset %d", d.off), Offset: int64(d.off)}
+	}
+	return d.data[d.off], nil
+}
+
+func (d *decodeState) expect(c byte) error {
+	next, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if next != c {
+		return d.syntaxError(fmt.Sprintf("expected %q", c))
+	}
+	d.off++
+	return nil
+}
+
+// SyntaxError reports malformed input. Offset is the byte offset at
+// which the error was detected.
+type SyntaxError struct {
+	msg    string
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string {
+	return e.msg
+}
+
+// TypeError reports a value that cannot be stored in the target type,
+// such as a negative number for an unsigned field or a string for a bool.
+// Field is the dotted path of the value in the document, e.g.
+// "items.2.price", and is empty for the top-level value.
+type TypeError struct {
+	Value  string
+	Type   reflect.Type
+	Field  string
+	Offset int64
+}
+
+func (e *TypeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("cannot unmarshal %s into value of type %v", e.Value, e.Type)
+	}
+	return fmt.Sprintf("cannot unmarshal %s into field %s of type %v", e.Value, e.Field, e.Type)
+}
+
+// MissingFieldError reports a struct field tagged required whose key is
+// absent from an object. Field is the dotted path of the field and Offset
+// that of the object.
+type MissingFieldError struct {
+	Field  string
+	Offset int64
+}
+
+func (e *MissingFieldError) Error() string {
+	return "missing required field " + e.Field
+}
+
+// UnknownFieldError reports, in strict mode, an object key that matches no
+// field of the target struct. Field is the dotted path of the key and
+// Offset that of its value.
+type UnknownFieldError struct {
+	Field  string
+	Offset int64
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "unknown field " + e.Field
+}
+
+// AmbiguousFieldError reports, in strict mode, an object key that matches
+// several struct fields ignoring case, or a key for a field that another
+// key of the same object, differing in case or using an alias, already
+// set. Field is the dotted path of the key and Offset that of its value.
+type AmbiguousFieldError struct {
+	Field  string
+	Offset int64
+}
+
+func (e *AmbiguousFieldError) Error() string {
+	return "ambiguous field " + e.Field
+}
+
+// MultiError lists the field errors found by Unmarshal with CollectErrors,
+// in the order they were found: *TypeError, *MissingFieldError,
+// *UnknownFieldError and *AmbiguousFieldError values, each with its path
+// and offset.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// ErrLimitExceeded is matched by errors.Is for every *LimitError, so that
+// handlers can tell input rejected by Limits from malformed input.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports input that exceeds one of the Limits or the maximum
+// depth. Limit is "input size", "depth", "string length", "elements" or
+// "allocations", Max the value it exceeded and Offset the byte offset at
+// which the decoder stopped.
+type LimitError struct {
+	Limit  string
+	Max    int
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// CycleError reports a value that Marshal reaches again while encoding
+// it, through a pointer, map or slice that refers back to it. Field is the
+// dotted path at which the value repeats.
+type CycleError struct {
+	Type  reflect.Type
+	Field string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("encountered a cycle via %v at field %s", e.Type, e.Field)
+}
+
+// DepthError reports a value that Marshal finds nested more deeply than
+// the maximum depth. Field is the dotted path of the value.
+type DepthError struct {
+	Depth int
+	Field string
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("exceeded maximum depth of %d at field %s", e.Depth, e.Field)
+}
+
+// withField prefixes the field path of a *TypeError, *MissingFieldError,
+// *UnknownFieldError, *AmbiguousFieldError, *CycleError or *DepthError
+// with key, as the error travels up from a nested value.
+func withField(err error, key string) error {
+	switch e := err.(type) {
+	case *TypeError:
+		e.Field = joinField(key, e.Field)
+	case *MissingFieldError:
+		e.Field = joinField(key, e.Field)
+	case *UnknownFieldError:
+		e.Field = joinField(key, e.Field)
+	case *AmbiguousFieldError:
+		e.Field = joinField(key, e.Field)
+	case *CycleError:
+		e.Field = joinField(key, e.Field)
+	case *DepthError:
+		e.Field = joinField(key, e.Field)
+	}
+	return err
+}
+
+func joinField(key, path string) string {
+	if path == "" {
+		return key
+	}
+	return key + "." + path
+}
+
+func (d *decodeState) syntaxError(msg string) error {
+	if d.off >= len(d.data) {
+		msg = fmt.Sprintf("%s, got end of input at offset %d", msg, d.off)
+	} else {
+		msg = fmt.Sprintf("%s, got %q at offset %d", msg, d.data[d.off], d.off)
+	}
+	return &SyntaxError{msg: msg, Offset: int64(d.off)}
+}
+
+// readString reads a string and returns its decoded contents. Strings
+// without escapes share memory with the input.
+func (d *decodeState) readString() ([]byte, error) {
+	raw, err := d.readRawString()
+	if err != nil {
+		return nil, err
+	}
+	return d.unquote(raw)
+}
+
+// readRawString returns the bytes between the quotes as written, after
+// checking the escapes and rejecting control characters.
+func (d *decodeState) readRawString() ([]byte, error) {
+	start := d.off
+	d.off++ // skip opening quote
+
+	for d.off < len(d.data) {
+		switch c := d.data[d.off]; {
+		case c == '"':
+			d.off++ // skip closing quote
+			return d.data[start+1 : d.off-1], nil
+		case c == '\\':
+			if d.off+1 >= len(d.data) {
+				d.off = len(d.data)
+				continue
+			}
+			switch d.data[d.off+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				d.off += 2
+			case 'u':
+				if _, ok := hex4(d.data[d.off+2:]); !ok {
+					return nil, d.syntaxError("invalid \\u escape")
+				}
+				d.off += 6
+			default:
+				return nil, d.syntaxError("invalid escape")
+			}
+		case c < 0x20:
+			return nil, d.syntaxError("control character in string")
+		default:
+			d.off++
+		}
+	}
+
+	d.off = start
+	return nil, &SyntaxError{msg: fmt.Sprintf("unterminated string at offset %d", start), Offset: int64(start)}
+}
+
+// unquote decodes the escapes in raw, a string read by readRawString, and
+// applies the invalid UTF-8 policy. Decoded strings are written to a
+// scratch buffer that the next call overwrites.
+func (d *decodeState) unquote(raw []byte) ([]byte, error) {
+	if bytes.IndexByte(raw, '\\') < 0 && utf8.Valid(raw) {
+		return raw, d.checkString(raw, len(raw))
+	}
+
+	buf := d.scratch[:0]
+	defer func() { d.scratch = buf }()
+	for i := 0; i < len(raw); {
+		c := raw[i]
+		switch {
+		case c == '\\':
+			if raw[i+1] != 'u' {
+				buf = append(buf, unescape[raw[i+1]])
+				i += 2
+				continue
+			}
+			r, _ := hex4(raw[i+2:])
+			i += 6
+			if utf16.IsSurrogate(r) {
+				r2, ok := rune(0), false
+				if i+6 <= len(raw) && raw[i] == '\\' && raw[i+1] == 'u' {
+					r2, ok = hex4(raw[i+2:])
+				}
+				if r = utf16.DecodeRune(r, r2); ok && r != utf8.RuneError {
+					i += 6
+				}
+			}
+			buf = utf8.AppendRune(buf, r)
+		case c < utf8.RuneSelf:
+			buf = append(buf, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(raw[i:])
+			if r == utf8.RuneError && size == 1 {
+				if d.s.invalidUTF8 == InvalidUTF8Error {
+					offset := d.offsetOf(raw) + i
+					return nil, &SyntaxError{msg: fmt.Sprintf("invalid UTF-8 in string at offset %d", offset), Offset: int64(offset)}
+				}
+				buf = utf8.AppendRune(buf, utf8.RuneError)
+				i++
+				continue
+			}
+			buf = append(buf, raw[i:i+size]...)
+			i += size
+		}
+	}
+	return buf, d.checkString(raw, len(buf))
+}
+
+// checkString enforces Limits.MaxStringLength on the string written as raw
+// that decodes to n bytes.
+func (d *decodeState) checkString(raw []byte, n int) error {
+	if max := d.s.limits.MaxStringLength; max > 0 && n > max {
+		return &LimitError{Limit: "string length", Max: max, Offset: int64(d.offsetOf(raw))}
+	}
+	return nil
+}
+
+var unescape = [256]byte{
+	'"':  '"',
+	'\\': '\\',
+	'/':  '/',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+}
+
+// hex4 parses the four hex digits of a \u escape.
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+func (d *decodeState) readNumber() []byte {
+	start := d.off
+	for d.off < len(d.data) {
+		c := d.data[d.off]
+		if !isDigit(c) && c != '.' && c != '-' && c != 'e' && c != 'E' && c != '+' {
+			break
+		}
+		d.off++
+	}
+	return d.data[start:d.off]
+}
+
+func (d *decodeState) readLiteral(lit string) bool {
+	if bytes.HasPrefix(d.data[d.off:], []byte(lit)) {
+		d.off += len(lit)
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (d *decodeState) value(rv reflect.Value) error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if rv.Kind() == reflect.Struct && (rv.Type() == bigIntType || rv.Type() == bigFloatType) {
+		return d.bigValue(rv)
+	}
+	if rv.CanAddr() && typeMethods(rv.Type())&(hasDecoder|hasUnmarshaler) != 0 {
+		switch u := rv.Addr().Interface().(type) {
+		case Decoder:
+			if d.reader.d == nil {
+				d.reader.d = d
+			}
+			return u.DecodeJSON(&d.reader)
+		case Unmarshaler:
+			start := d.off
+			if err := d.skip(); err != nil {
+				return err
+			}
+			return u.UnmarshalJSON(d.data[start:d.off])
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return fmt.Errorf("cannot unmarshal into non-empty interface %v", rv.Type())
+		}
+		if m, ok := rv.Interface().(map[string]interface{}); ok && m != nil && c == '{' && d.s.merge {
+			return d.object(reflect.ValueOf(m))
+		}
+		value, err := d.valueInterface()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(value))
+		}
+		return nil
+	case reflect.Ptr:
+		if c == 'n' && d.readLiteral("null") {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.value(rv.Elem())
+	}
+
+	start := d.off
+	switch {
+	case c == '"':
+		return d.stringValue(rv)
+	case c == '{':
+		return d.object(rv)
+	case c == '[':
+		return d.array(rv)
+	case c == '-' || isDigit(c):
+		return d.numberValue(rv)
+	case c == 't' && d.readLiteral("true"), c == 'f' && d.readLiteral("false"):
+		if rv.Kind() != reflect.Bool {
+			return &TypeError{Value: "boolean", Type: rv.Type(), Offset: int64(start)}
+		}
+		rv.SetBool(c == 't')
+		return nil
+	case c == 'n' && d.readLiteral("null"):
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		return &TypeError{Value: "null", Type: rv.Type(), Offset: int64(start)}
+	default:
+		return d.syntaxError("unexpected value")
+	}
+}
+
+func (d *decodeState) stringValue(rv reflect.Value) error {
+	start := d.off
+	str, err := d.readString()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case rv.Kind() == reflect.String:
+		rv.SetString(d.makeString(str))
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		b, err := d.s.bytesEncoding.decode(string(str))
+		if err != nil {
+			return fmt.Errorf("cannot decode %q as bytes: %v", str, err)
+		}
+		rv.SetBytes(b)
+	case (rv.Kind() == reflect.Complex64 || rv.Kind() == reflect.Complex128) && d.s.complexFormat != ComplexUnsupported:
+		c, err := strconv.ParseComplex(string(str), rv.Type().Bits())
+		if err != nil {
+			return &TypeError{Value: "string " + strconv.Quote(string(str)), Type: rv.Type(), Offset: int64(start)}
+		}
+		rv.SetComplex(c)
+	case (rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64) && d.s.nonFinite == NonFiniteString:
+		f, ok := parseNonFinite(str)
+		if !ok {
+			return &TypeError{Value: "string", Type: rv.Type(), Offset: int64(start)}
+		}
+		rv.SetFloat(f)
+	default:
+		return &TypeError{Value: "string", Type: rv.Type(), Offset: int64(start)}
+	}
+	return nil
+}
+
+// quotedValue decodes a field tagged ",string", whose number or bool is
+// written inside a string. Values written without quotes are accepted too.
+func (d *decodeState) quotedValue(rv reflect.Value) error {
+	c, err := d.peek()
+	if err != nil || c != '"' {
+		return d.value(rv)
+	}
+
+	start := d.off
+	str, err := d.readString()
+	if err != nil {
+		return err
+	}
+	if (rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64) && d.s.nonFinite == NonFiniteString {
+		// Marshal writes NaN and infinities of fields tagged string as
+		// plain strings.
+		if f, ok := parseNonFinite(str); ok {
+			rv.SetFloat(f)
+			return nil
+		}
+	}
+	inner := decodeState{s: d.s, data: str}
+	if c, err := inner.peek(); err == nil && c != '"' {
+		if err = inner.value(rv); err == nil {
+			if inner.skipWhitespace(); inner.off == len(str) {
+				return nil
+			}
+		}
+	}
+	return &TypeError{Value: "string " + strconv.Quote(string(str)), Type: rv.Type(), Offset: int64(start)}
+}
+
+// parseNonFinite reads the strings NonFiniteString writes.
+func parseNonFinite(str []byte) (float64, bool) {
+	switch string(str) {
+	case "NaN":
+		return math.NaN(), true
+	case "Infinity":
+		return math.Inf(1), true
+	case "-Infinity":
+		return math.Inf(-1), true
+	}
+	return 0, false
+}
+
+func (d *decodeState) numberValue(rv reflect.Value) error {
+	num := d.readNumber()
+
+	if rv.Type() == numberType {
+		if !isValidNumber(num) {
+			return d.numberError(num, rv.Type())
+		}
+		rv.SetString(string(num))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := d.convertInt(num, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := d.convertUint(num, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := d.convertFloat(num, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	default:
+		return d.numberError(num, rv.Type())
+	}
+	return nil
+}
+
+// convertInt, convertUint and convertFloat convert a number literal just
+// read for a target of type t, reporting values that t cannot hold.
+func (d *decodeState) convertInt(num []byte, t reflect.Type) (int64, error) {
+	i, err := intLiteral(num)
+	if bits := t.Bits(); err != nil || leadingZero(num) || bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		return 0, d.numberError(num, t)
+	}
+	return i, nil
+}
+
+func (d *decodeState) convertUint(num []byte, t reflect.Type) (uint64, error) {
+	u, err := uintLiteral(num)
+	if bits := t.Bits(); err != nil || leadingZero(num) || bits < 64 && u >= 1<<bits {
+		return 0, d.numberError(num, t)
+	}
+	return u, nil
+}
+
+func (d *decodeState) convertFloat(num []byte, t reflect.Type) (float64, error) {
+	f, err := floatLiteral(num)
+	if err != nil || leadingZero(num) || t.Bits() == 32 && math.Abs(f) > math.MaxFloat32 {
+		return 0, d.numberError(num, t)
+	}
+	return f, nil
+}
+
+// numberError reports the number literal just read: malformed ones as a
+// syntax error, the rest as not fitting t.
+func (d *decodeState) numberError(num []byte, t reflect.Type) error {
+	start := d.off - len(num)
+	if !isValidNumber(num) {
+		d.off = start
+		return d.syntaxError("invalid number")
+	}
+	return &TypeError{Value: "number " + string(num), Type: t, Offset: int64(start)}
+}
+
+func intLiteral(num []byte) (int64, error) {
+	if bytes.IndexAny(num, ".eE") < 0 {
+		return parseInt(num)
+	}
+	text, err := integerText(num)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+func uintLiteral(num []byte) (uint64, error) {
+	text := string(num)
+	if bytes.IndexAny(num, ".eE") >= 0 {
+		var err error
+		if text, err = integerText(num); err != nil {
+			return 0, err
+		}
+	}
+	return strconv.ParseUint(text, 10, 64)
+}
+
+func floatLiteral(num []byte) (float64, error) {
+	return strconv.ParseFloat(string(num), 64)
+}
+
+// parseInt parses plain decimal integers without converting the input to
+// a string, falling back to strconv for anything unusual.
+func parseInt(num []byte) (int64, error) {
+	digits := num
+	neg := len(digits) > 0 && digits[0] == '-'
+	if neg {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		return strconv.ParseInt(string(num), 10, 64)
+	}
+
+	var n int64
+	for _, c := range digits {
+		if !isDigit(c) {
+			return strconv.ParseInt(string(num), 10, 64)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+func (d *decodeState) object(rv reflect.Value) error {
+	start := d.off
+	switch rv.Kind() {
+	case reflect.Struct:
+		fields := cachedFields(rv.Type())
+		var seen []bool
+		if d.s.strict || slices.ContainsFunc(fields, func(f field) bool { return f.required }) {
+			seen = make([]bool, len(fields))
+		}
+		err := d.objectFields(func(key []byte) error {
+			i, ambiguous := lookupField(fields, key)
+			if i < 0 {
+				return d.unknownField()
+			}
+			if d.s.strict && (ambiguous || seen[i]) {
+				// objectFields fills in the key as the error passes through.
+				return d.collectError(&AmbiguousFieldError{Offset: int64(d.off)}, d.off, reflect.Value{})
+			}
+			if seen != nil {
+				seen[i] = true
+			}
+
+			f := &fields[i]
+			fv, err := fieldByIndexAlloc(rv, f.index)
+			if err != nil {
+				return err
+			}
+			valueStart := d.off
+			if f.quoted {
+				return d.collectError(d.quotedValue(fv), valueStart, fv)
+			}
+			return d.collectError(d.value(fv), valueStart, fv)
+		})
+		if err != nil || seen == nil {
+			return err
+		}
+		for i, f := range fields {
+			if f.required && !seen[i] {
+				err := &MissingFieldError{Field: f.name, Offset: int64(start)}
+				if !d.s.collectErrors {
+					return err
+				}
+				d.errs = append(d.errs, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		t := rv.Type()
+		if !isValidMapKey(t.Key()) && !reflect.PointerTo(t.Key()).Implements(textUnmarshalerType) {
+			return fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		if !d.s.merge || rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		}
+		return d.objectFields(func(key []byte) error {
+			kv, err := parseMapKey(t.Key(), string(key))
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if d.s.merge {
+				if existing := rv.MapIndex(kv); existing.IsValid() {
+					elem.Set(existing)
+				}
+			}
+			valueStart := d.off
+			if err := d.collectError(d.value(elem), valueStart, elem); err != nil {
+				return err
+			}
+			rv.SetMapIndex(kv, elem)
+			return nil
+		})
+	default:
+		return &TypeError{Value: "object", Type: rv.Type(), Offset: int64(start)}
+	}
+}
+
+// parseMapKey converts an object key back to a map key of type t, the
+// inverse of mapKeyName.
+func parseMapKey(t reflect.Type, key string) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		kv := reflect.New(t)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %v", key, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %v", key, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported map key type %v", t)
+}
+
+// unknownField skips the value of a key that matches no struct field, or
+// reports the key in strict mode.
+func (d *decodeState) unknownField() error {
+	if d.s.strict {
+		// objectFields fills in the key as the error passes through.
+		return d.collectError(&UnknownFieldError{Offset: int64(d.off)}, d.off, reflect.Value{})
+	}
+	return d.skip()
+}
+
+// objectFields walks the members of an object, calling fn for each key
+// with the decoder positioned at the member's value. In strict mode a key
+// seen twice is a syntax error.
+func (d *decodeState) objectFields(fn func(key []byte) error) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	d.off++ // skip {
+	var seen map[string]struct{}
+
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if c == '}' {
+		d.off++
+		d.depth--
+		return nil
+	}
+
+	for n := 1; ; n++ {
+		if err := d.element(n); err != nil {
+			return err
+		}
+		if c, err = d.peek(); err != nil {
+			return err
+		}
+		if c != '"' {
+			return d.syntaxError("expected string key")
+		}
+		start := d.off
+		raw, err := d.readRawString()
+		if err != nil {
+			return err
+		}
+		key, err := d.unquote(raw)
+		if err != nil {
+			return err
+		}
+		d.rawKey = raw
+
+		if d.s.strict {
+			if _, ok := seen[string(key)]; ok {
+				return &SyntaxError{msg: fmt.Sprintf("duplicate key %q at offset %d", key, start), Offset: int64(start)}
+			}
+			if seen == nil {
+				seen = make(map[string]struct{})
+			}
+			seen[string(key)] = struct{}{}
+		}
+
+		if err := d.expect(':'); err != nil {
+			return err
+		}
+		mark := len(d.errs)
+		if err := fn(key); err != nil {
+			switch err.(type) {
+			case *TypeError, *MissingFieldError, *UnknownFieldError, *AmbiguousFieldError:
+				name, _ := d.unquote(raw)
+				return withField(err, string(name))
+			}
+			return err
+		}
+		if len(d.errs) > mark {
+			name, _ := d.unquote(raw)
+			for _, err := range d.errs[mark:] {
+				withField(err, string(name))
+			}
+		}
+
+		if c, err = d.peek(); err != nil {
+			return err
+		}
+		d.off++
+		switch c {
+		case ',':
+		case '}':
+			d.depth--
+			return nil
+		default:
+			d.off--
+			return d.syntaxError("expected comma or }")
+		}
+	}
+}
+
+func (d *decodeState) array(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice:
+	case reflect.Array:
+		return d.fixedArray(rv)
+	case reflect.Complex64, reflect.Complex128:
+		if d.s.complexFormat != ComplexUnsupported {
+			return d.complexArray(rv)
+		}
+		fallthrough
+	default:
+		return &TypeError{Value: "array", Type: rv.Type(), Offset: int64(d.off)}
+	}
+
+	t := rv.Type()
+	i := 0
+	switch {
+	case !d.s.merge || d.s.sliceMerge == ReplaceSlices:
+		rv.Set(reflect.Zero(t))
+	case d.s.sliceMerge == AppendSlices:
+		i = rv.Len()
+	}
+
+	// Elements below rv.Len() are decoded into in place when merging.
+	err := d.arrayElements(func() error {
+		if i >= rv.Len() {
+			if i >= rv.Cap() {
+				rv.Grow(rv.Cap()/2 + 4)
+			}
+			rv.SetLen(i + 1)
+			rv.Index(i).SetZero()
+		}
+		i++
+		start := d.off
+		return d.collectError(d.value(rv.Index(i-1)), start, rv.Index(i-1))
+	})
+	if err == nil && rv.IsNil() {
+		rv.Set(reflect.MakeSlice(t, 0, 0))
+	}
+	return err
+}
+
+// fixedArray decodes into a Go array, whose length the input must match
+// unless the serializer truncates.
+func (d *decodeState) fixedArray(rv reflect.Value) error {
+	start := d.off
+	n := 0
+	err := d.arrayElements(func() error {
+		n++
+		if n > rv.Len() {
+			return d.skip()
+		}
+		start := d.off
+		return d.collectError(d.value(rv.Index(n-1)), start, rv.Index(n-1))
+	})
+	if err != nil {
+		return err
+	}
+	if n != rv.Len() && d.s.arrayLength == ArrayExact {
+		return &TypeError{Value: fmt.Sprintf("array of %d elements", n), Type: rv.Type(), Offset: int64(start)}
+	}
+	for i := n; i < rv.Len(); i++ {
+		rv.Index(i).SetZero()
+	}
+	return nil
+}
+
+// complexArray decodes a complex number written as [real, imaginary].
+func (d *decodeState) complexArray(rv reflect.Value) error {
+	part := float64Type
+	if rv.Kind() == reflect.Complex64 {
+		part = float32Type
+	}
+	parts := reflect.New(reflect.ArrayOf(2, part)).Elem()
+	if err := d.fixedArray(parts); err != nil {
+		if te, ok := err.(*TypeError); ok && te.Field == "" {
+			te.Type = rv.Type()
+		}
+		return err
+	}
+	rv.SetComplex(complex(parts.Index(0).Float(), parts.Index(1).Float()))
+	return nil
+}
+
+// enter counts the object or array about to be read, which must not be
+// nested deeper than the serializer allows. objectFields and arrayElements
+// leave the level when the value ends; after an error the decoder is not
+// used again.
+func (d *decodeState) enter() error {
+	d.depth++
+	if d.s.maxDepth > 0 && d.depth > d.s.maxDepth {
+		return &LimitError{Limit: "depth", Max: d.s.maxDepth, Offset: int64(d.off)}
+	}
+	return nil
+}
+
+// element counts the nth element of an array or member of an object
+// against Limits.MaxElements and Limits.MaxAllocations.
+func (d *decodeState) element(n int) error {
+	d.values++
+	limits := &d.s.limits
+	switch {
+	case limits.MaxElements > 0 && n > limits.MaxElements:
+		return &LimitError{Limit: "elements", Max: limits.MaxElements, Offset: int64(d.off)}
+	case limits.MaxAllocations > 0 && d.values > limits.MaxAllocations:
+		return &LimitError{Limit: "allocations", Max: limits.MaxAllocations, Offset: int64(d.off)}
+	}
+	return nil
+}
+
+// arrayElements calls fn for every element with the decoder positioned at
+// the element.
+func (d *decodeState) arrayElements(fn func() error) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	d.off++ // skip [
+
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if c == ']' {
+		d.off++
+		d.depth--
+		return nil
+	}
+
+	for i := 0; ; i++ {
+		if err := d.element(i + 1); err != nil {
+			return err
+		}
+		mark := len(d.errs)
+		if err := fn(); err != nil {
+			return withField(err, strconv.Itoa(i))
+		}
+		for _, err := range d.errs[mark:] {
+			withField(err, strconv.Itoa(i))
+		}
+
+		if c, err = d.peek(); err != nil {
+			return err
+		}
+		d.off++
+		switch c {
+		case ',':
+		case ']':
+			d.depth--
+			return nil
+		default:
+			d.off--
+			return d.syntaxError("expected comma or ]")
+		}
+	}
+}
+
+// valueInterface decodes the next value into the generic representation
+// used for interface{} targets.
+func (d *decodeState) valueInterface() (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == '"':
+		str, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return d.makeString(str), nil
+	case c == '{':
+		obj := make(map[string]interface{})
+		err := d.objectFields(func(key []byte) error {
+			name := d.makeString(key)
+			value, err := d.valueInterface()
+			obj[name] = value
+			return err
+		})
+		return obj, err
+	case c == '[':
+		arr := make([]interface{}, 0)
+		err := d.arrayElements(func() error {
+			value, err := d.valueInterface()
+			arr = append(arr, value)
+			return err
+		})
+		return arr, err
+	case c == '-' || isDigit(c):
+		start := d.off
+		num := d.readNumber()
+		if !isValidNumber(num) {
+			d.off = start
+			return nil, d.syntaxError("invalid number")
+		}
+		if d.s.useNumber {
+			return Number(num), nil
+		}
+		if bytes.IndexAny(num, ".eE") < 0 {
+			// Integers that do not fit int64 become uint64, and failing
+			// that float64.
+			if i, err := parseInt(num); err == nil {
+				return i, nil
+			}
+			if u, err := strconv.ParseUint(string(num), 10, 64); err == nil {
+				return u, nil
+			}
+		}
+		f, err := strconv.ParseFloat(string(num), 64)
+		if err != nil {
+			return nil, &TypeError{Value: "number " + string(num), Type: float64Type, Offset: int64(start)}
+		}
+		return f, nil
+	case c == 't' && d.readLiteral("true"):
+		return true, nil
+	case c == 'f' && d.readLiteral("false"):
+		return false, nil
+	case c == 'n' && d.readLiteral("null"):
+		return nil, nil
+	default:
+		return nil, d.syntaxError("unexpected value")
+	}
+}
+
+// skip consumes the next value, checking its syntax without storing it.
+func (d *decodeState) skip() error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c == '"':
+		_, err := d.readString()
+		return err
+	case c == '{':
+		return d.objectFields(func([]byte) error { return d.skip() })
+	case c == '[':
+		return d.arrayElements(d.skip)
+	case c == '-' || isDigit(c):
+		start := d.off
+		if !isValidNumber(d.readNumber()) {
+			d.off = start
+			return d.syntaxError("invalid number")
+		}
+		return nil
+	case c == 't' && d.readLiteral("true"), c == 'f' && d.readLiteral("false"), c == 'n' && d.readLiteral("null"):
+		return nil
+	default:
+		return d.syntaxError("unexpected value")
+	}
+}
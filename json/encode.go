@@ -0,0 +1,627 @@
+package json
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Buffers larger than this are not returned to the pool, so that a single
+// huge document does not pin its memory for the lifetime of the process.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// encodeState tracks the nesting of the value being encoded: its depth,
+// and the pointers, maps and slices currently open, so that a value which
+// contains itself is reported instead of overflowing the stack.
+type encodeState struct {
+	s      *JSONSerializer
+	depth  int
+	visits []visit
+	deep   map[visit]struct{}
+	writer Writer
+}
+
+// visit identifies a pointer, map or slice by the memory it refers to.
+// Slices sharing a pointer differ by length, pointers to a struct and to
+// its first field by type.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() any {
+		e := new(encodeState)
+		e.writer.e = e
+		return e
+	},
+}
+
+// appendValue encodes v with a pooled encodeState.
+func (s *JSONSerializer) appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	e := encodeStatePool.Get().(*encodeState)
+	e.s, e.depth, e.visits = s, 0, e.visits[:0]
+	clear(e.deep)
+	buf, err := e.appendValue(buf, v)
+	e.s = nil
+	encodeStatePool.Put(e)
+	return buf, err
+}
+
+// enter opens a struct, map, slice or array, failing if it is nested too
+// deeply or, for maps and slices, already open further up. After an error
+// the state is discarded, so only successful paths call leave.
+func (e *encodeState) enter(v reflect.Value) error {
+	e.depth++
+	if e.s.maxDepth > 0 && e.depth > e.s.maxDepth {
+		return &DepthError{Depth: e.s.maxDepth}
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		return e.visit(visit{ptr: v.Pointer(), typ: v.Type()})
+	case reflect.Slice:
+		return e.visit(visit{ptr: v.Pointer(), typ: v.Type(), len: v.Len()})
+	}
+	return nil
+}
+
+func (e *encodeState) leave(v reflect.Value) {
+	e.depth--
+	switch v.Kind() {
+	case reflect.Map:
+		e.unvisit(visit{ptr: v.Pointer(), typ: v.Type()})
+	case reflect.Slice:
+		e.unvisit(visit{ptr: v.Pointer(), typ: v.Type(), len: v.Len()})
+	}
+}
+
+// shallowVisits is how many open values are kept in a slice and searched
+// linearly. Values nested deeper go into a map, so long chains of pointers
+// are checked in constant time.
+const shallowVisits = 32
+
+func (e *encodeState) visit(key visit) error {
+	if slices.Contains(e.visits, key) {
+		return &CycleError{Type: key.typ}
+	}
+	if len(e.visits) < shallowVisits {
+		e.visits = append(e.visits, key)
+		return nil
+	}
+	if _, ok := e.deep[key]; ok {
+		return &CycleError{Type: key.typ}
+	}
+	if e.deep == nil {
+		e.deep = make(map[visit]struct{})
+	}
+	e.deep[key] = struct{}{}
+	return nil
+}
+
+// unvisit closes key, the value opened last.
+func (e *encodeState) unvisit(key visit) {
+	if len(e.deep) > 0 {
+		delete(e.deep, key)
+		return
+	}
+	e.visits = e.visits[:len(e.visits)-1]
+}
+
+func (s *JSONSerializer) Marshal(v any) ([]byte, error) {
+	bp := bufferPool.Get().(*[]byte)
+	buf, err := s.appendValue((*bp)[:0], reflect.ValueOf(v))
+
+	var out []byte
+	if err == nil && s.indent != "" {
+		out = appendIndent(make([]byte, 0, len(buf)*2), buf, s.indent)
+	} else if err == nil {
+		out = make([]byte, len(buf))
+		copy(out, buf)
+	}
+
+	if cap(buf) <= maxPooledBuffer {
+		*bp = buf
+		bufferPool.Put(bp)
+	}
+	return out, err
+}
+
+// Append appends the JSON encoding of v to dst and returns the extended
+// buffer, allowing callers to reuse their own buffers between calls. On
+// error dst is returned unchanged.
+func (s *JSONSerializer) Append(dst []byte, v any) ([]byte, error) {
+	start := len(dst)
+	buf, err := s.appendValue(dst, reflect.ValueOf(v))
+	if err != nil {
+		return dst, err
+	}
+	if s.indent == "" {
+		return buf, nil
+	}
+	indented := appendIndent(nil, buf[start:], s.indent)
+	return append(buf[:start], indented...), nil
+}
+
+func (e *encodeState) appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.IsValid() && typeMethods(v.Type())&(hasEncoder|hasAppender|hasMarshaler) != 0 && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		// Boxing an addressable value copies it, its address does not.
+		m := v.Interface
+		if v.CanAddr() && v.Kind() != reflect.Ptr {
+			m = v.Addr().Interface
+		}
+		switch m := m().(type) {
+		case Encoder:
+			if v.Kind() != reflect.Ptr {
+				return m.EncodeJSON(&e.writer, buf)
+			}
+			// A pointer is tracked as in the reflective path below, which
+			// catches cycles through generated types.
+			key := visit{ptr: v.Pointer(), typ: v.Type()}
+			if err := e.visit(key); err != nil {
+				return nil, err
+			}
+			buf, err := m.EncodeJSON(&e.writer, buf)
+			if err == nil {
+				e.unvisit(key)
+			}
+			return buf, err
+		case Appender:
+			return m.AppendJSON(buf)
+		case Marshaler:
+			b, err := m.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			return append(buf, b...), nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type() == numberType {
+			return appendNumber(buf, Number(v.String()))
+		}
+		return e.s.appendStringValue(buf, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(buf, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(buf, v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return e.s.appendFloatValue(buf, v.Float(), v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return e.s.appendComplex(buf, v.Complex(), v.Type().Bits())
+	case reflect.Bool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case reflect.Slice, reflect.Array:
+		return e.appendArray(buf, v)
+	case reflect.Map:
+		return e.appendMap(buf, v)
+	case reflect.Struct:
+		if v.Type() == bigIntType || v.Type() == bigFloatType {
+			return appendBig(buf, v)
+		}
+		return e.appendStruct(buf, v)
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(buf, "null"...), nil
+		}
+		return e.appendValue(buf, v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return append(buf, "null"...), nil
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if err := e.visit(key); err != nil {
+			return nil, err
+		}
+		buf, err := e.appendValue(buf, v.Elem())
+		if err == nil {
+			e.unvisit(key)
+		}
+		return buf, err
+	case reflect.Invalid:
+		return append(buf, "null"...), nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %v", v.Kind())
+	}
+}
+
+func (e *encodeState) appendArray(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return append(buf, "null"...), nil
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		buf = append(buf, '"')
+		buf = e.s.bytesEncoding.appendEncode(buf, v.Bytes())
+		return append(buf, '"'), nil
+	}
+
+	err := e.enter(v)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, '[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = e.appendValue(buf, v.Index(i)); err != nil {
+			return nil, withField(err, strconv.Itoa(i))
+		}
+	}
+	e.leave(v)
+	return append(buf, ']'), nil
+}
+
+func (e *encodeState) appendMap(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return append(buf, "null"...), nil
+	}
+	if !isValidMapKey(v.Type().Key()) {
+		return nil, fmt.Errorf("unsupported map key type %v", v.Type().Key())
+	}
+	if err := e.enter(v); err != nil {
+		return nil, err
+	}
+
+	buf = append(buf, '{')
+	if e.s.unsortedKeys {
+		iter := v.MapRange()
+		for i := 0; iter.Next(); i++ {
+			name, err := mapKeyName(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if buf, err = e.appendMapEntry(buf, i, name, iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		e.leave(v)
+		return append(buf, '}'), nil
+	}
+
+	keys, err := sortedMapKeys(v, e.s.compareKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		if buf, err = e.appendMapEntry(buf, i, key.name, v.MapIndex(key.value)); err != nil {
+			return nil, err
+		}
+	}
+	e.leave(v)
+	return append(buf, '}'), nil
+}
+
+func (e *encodeState) appendMapEntry(buf []byte, i int, name string, value reflect.Value) ([]byte, error) {
+	if i > 0 {
+		buf = append(buf, ',')
+	}
+	buf, err := e.s.appendStringValue(buf, name)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, ':')
+	if buf, err = e.appendValue(buf, value); err != nil {
+		return nil, withField(err, name)
+	}
+	return buf, nil
+}
+
+type mapKey struct {
+	name  string
+	value reflect.Value
+}
+
+// sortedMapKeys returns the keys of v ordered by cmp, or by byte order if
+// cmp is nil.
+func sortedMapKeys(v reflect.Value, cmp func(a, b string) int) ([]mapKey, error) {
+	if cmp == nil {
+		cmp = strings.Compare
+	}
+
+	keys := make([]mapKey, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyName(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, mapKey{name, iter.Key()})
+	}
+	slices.SortFunc(keys, func(a, b mapKey) int {
+		return cmp(a.name, b.name)
+	})
+	return keys, nil
+}
+
+// isValidMapKey reports whether maps keyed by t can be encoded: strings,
+// integers and encoding.TextMarshaler implementations.
+func isValidMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshalerType)
+}
+
+// mapKeyName returns the object key for a map key. String kinds are used
+// as is, even if they implement encoding.TextMarshaler.
+func mapKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %v", k.Type())
+}
+
+func (e *encodeState) appendStruct(buf []byte, v reflect.Value) ([]byte, error) {
+	err := e.enter(v)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, '{')
+	n := 0
+	fields := cachedFields(v.Type())
+	for i := range fields {
+		f := &fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitted(fv) {
+			continue
+		}
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		n++
+		buf = append(buf, f.key...)
+		quoted := f.quoted && isFinite(fv)
+		if quoted {
+			buf = append(buf, '"')
+		}
+		if buf, err = e.appendValue(buf, fv); err != nil {
+			return nil, withField(err, f.name)
+		}
+		if quoted {
+			buf = append(buf, '"')
+		}
+	}
+	e.leave(v)
+	return append(buf, '}'), nil
+}
+
+// appendFloat writes the shortest text that reads back as the same value
+// of the given bit size, switching to exponent notation for very large and
+// very small magnitudes the way encoding/json does.
+func appendFloat(buf []byte, f float64, bitSize int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported float value: %v", f)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bitSize == 32 {
+			abs = float64(float32(abs))
+		}
+		if abs < 1e-6 || abs >= 1e21 {
+			format = 'e'
+		}
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, bitSize)
+	if format == 'e' {
+		// Shorten e-09 to e-9.
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+func (s *JSONSerializer) appendFloatValue(buf []byte, f float64, bitSize int) ([]byte, error) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return appendFloat(buf, f, bitSize)
+	}
+	switch s.nonFinite {
+	case NonFiniteNull:
+		return append(buf, "null"...), nil
+	case NonFiniteString:
+		buf = append(append(buf, '"'), nonFiniteName(f)...)
+		return append(buf, '"'), nil
+	default:
+		return appendFloat(buf, f, bitSize)
+	}
+}
+
+func (s *JSONSerializer) appendComplex(buf []byte, c complex128, bitSize int) ([]byte, error) {
+	switch s.complexFormat {
+	case ComplexString:
+		text := strconv.FormatComplex(c, 'g', -1, bitSize)
+		return appendString(buf, text[1:len(text)-1]), nil
+	case ComplexArray:
+		var err error
+		buf = append(buf, '[')
+		if buf, err = s.appendFloatValue(buf, real(c), bitSize/2); err != nil {
+			return nil, err
+		}
+		buf = append(buf, ',')
+		if buf, err = s.appendFloatValue(buf, imag(c), bitSize/2); err != nil {
+			return nil, err
+		}
+		return append(buf, ']'), nil
+	default:
+		return nil, fmt.Errorf("unsupported type: complex%d", bitSize)
+	}
+}
+
+// isFinite reports whether v, through any pointers, is not a NaN or
+// infinite float. Fields tagged string leave those to the NonFinite policy,
+// which writes a string or null.
+func isFinite(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return true
+	}
+	return !math.IsNaN(v.Float()) && !math.IsInf(v.Float(), 0)
+}
+
+func nonFiniteName(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case f > 0:
+		return "Infinity"
+	default:
+		return "-Infinity"
+	}
+}
+
+// appendString quotes s in a single pass, copying runs of bytes that need
+// no escaping at once. Quotes, backslashes and control characters are
+// escaped, and bytes that are not valid UTF-8 are replaced with U+FFFD.
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r != utf8.RuneError || size != 1 {
+				i += size
+				continue
+			}
+		}
+
+		buf = append(buf, s[start:i]...)
+		switch c {
+		case '\\', '"':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		case '\t':
+			buf = append(buf, `\t`...)
+		case '\b':
+			buf = append(buf, `\b`...)
+		case '\f':
+			buf = append(buf, `\f`...)
+		default:
+			if c < 0x20 {
+				buf = append(buf, `\u00`...)
+				buf = append(buf, hexDigits[c>>4], hexDigits[c&0xF])
+			} else {
+				buf = append(buf, `\ufffd`...)
+			}
+		}
+		i++
+		start = i
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendStringValue appends a string value or map key, applying the
+// invalid UTF-8 policy.
+func (s *JSONSerializer) appendStringValue(buf []byte, str string) ([]byte, error) {
+	if s.invalidUTF8 == InvalidUTF8Error && !utf8.ValidString(str) {
+		return nil, fmt.Errorf("invalid UTF-8 in string %q", str)
+	}
+	return appendString(buf, str), nil
+}
+
+// appendIndent appends the compact document src with one member or
+// element per line. Indenting after encoding also covers the output of
+// Appender and Marshaler implementations.
+func appendIndent(dst, src []byte, indent string) []byte {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch c {
+		case '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			dst = append(dst, src[i:end+1]...)
+			i = end
+		case '{', '[':
+			j := i + 1
+			for j < len(src) && isSpace(src[j]) {
+				j++
+			}
+			if j < len(src) && (src[j] == '}' || src[j] == ']') {
+				dst = append(dst, c, src[j])
+				i = j
+				continue
+			}
+			depth++
+			dst = append(dst, c)
+			dst = appendNewline(dst, indent, depth)
+		case '}', ']':
+			depth--
+			dst = appendNewline(dst, indent, depth)
+			dst = append(dst, c)
+		case ',':
+			dst = append(dst, c)
+			dst = appendNewline(dst, indent, depth)
+		case ':':
+			dst = append(dst, ": "...)
+		case ' ', '\t', '\n', '\r':
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+func appendNewline(dst []byte, indent string, depth int) []byte {
+	dst = append(dst, '\n')
+	for i := 0; i < depth; i++ {
+		dst = append(dst, indent...)
+	}
+	return dst
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
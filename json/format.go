@@ -0,0 +1,166 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// FormatOptions control Format. The zero value indents by two spaces and
+// keeps keys in their original order.
+type FormatOptions struct {
+	Indent   string
+	SortKeys bool
+}
+
+// Format rewrites a JSON document in canonical style: one member or
+// element per line, a space after colons and no trailing whitespace.
+// Strings and numbers are kept exactly as written.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+
+	f := &formatter{d: decodeState{s: defaultSerializer, data: data}, opts: opts}
+	buf, err := f.value(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.d.end(); err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+type formatter struct {
+	d    decodeState
+	opts FormatOptions
+}
+
+type member struct {
+	key   []byte
+	value []byte
+}
+
+func (f *formatter) value(buf []byte, depth int) ([]byte, error) {
+	c, err := f.d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == '"':
+		str, err := f.d.readRawString()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, '"')
+		buf = append(buf, str...)
+		return append(buf, '"'), nil
+	case c == '{':
+		return f.object(buf, depth)
+	case c == '[':
+		return f.array(buf, depth)
+	case c == '-' || isDigit(c):
+		num, err := f.d.number()
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, num...), nil
+	case c == 't' && f.d.readLiteral("true"):
+		return append(buf, "true"...), nil
+	case c == 'f' && f.d.readLiteral("false"):
+		return append(buf, "false"...), nil
+	case c == 'n' && f.d.readLiteral("null"):
+		return append(buf, "null"...), nil
+	default:
+		return nil, f.d.syntaxError("unexpected value")
+	}
+}
+
+func (f *formatter) object(buf []byte, depth int) ([]byte, error) {
+	var members []member
+	err := f.d.objectFields(func([]byte) error {
+		key := f.d.rawKey
+		value, err := f.value(nil, depth+1)
+		members = append(members, member{key, value})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return append(buf, "{}"...), nil
+	}
+
+	if f.opts.SortKeys {
+		sort.SliceStable(members, func(i, j int) bool {
+			return bytes.Compare(members[i].key, members[j].key) < 0
+		})
+	}
+
+	buf = append(buf, '{')
+	for i, m := range members {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = f.newline(buf, depth+1)
+		buf = append(buf, '"')
+		buf = append(buf, m.key...)
+		buf = append(buf, `": `...)
+		buf = append(buf, m.value...)
+	}
+	buf = f.newline(buf, depth)
+	return append(buf, '}'), nil
+}
+
+func (f *formatter) array(buf []byte, depth int) ([]byte, error) {
+	start := len(buf)
+	buf = append(buf, '[')
+	empty := true
+	err := f.d.arrayElements(func() error {
+		if !empty {
+			buf = append(buf, ',')
+		}
+		empty = false
+		buf = f.newline(buf, depth+1)
+
+		var err error
+		buf, err = f.value(buf, depth+1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if empty {
+		return append(buf[:start], "[]"...), nil
+	}
+	buf = f.newline(buf, depth)
+	return append(buf, ']'), nil
+}
+
+func (f *formatter) newline(buf []byte, depth int) []byte {
+	return appendNewline(buf, f.opts.Indent, depth)
+}
+
+// number reads a number literal, rejecting malformed ones that the
+// decoder would otherwise only notice when converting them.
+func (d *decodeState) number() ([]byte, error) {
+	start := d.off
+	num := d.readNumber()
+	if _, err := strconv.ParseFloat(string(num), 64); err != nil && !errors.Is(err, strconv.ErrRange) {
+		d.off = start
+		return nil, d.syntaxError("invalid number")
+	}
+	return num, nil
+}
+
+// end reports data left after the top-level value.
+func (d *decodeState) end() error {
+	d.skipWhitespace()
+	if d.off < len(d.data) {
+		return d.syntaxError("unexpected data after top-level value")
+	}
+	return nil
+}
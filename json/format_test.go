@@ -0,0 +1,93 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	input := `{"name":"тест" , "tags":[1,2],"empty":{},"list":[],"nested":{"b":true,"a":null}}`
+
+	want := `{
+  "name": "тест",
+  "tags": [
+    1,
+    2
+  ],
+  "empty": {},
+  "list": [],
+  "nested": {
+    "b": true,
+    "a": null
+  }
+}
+`
+	got, err := Format([]byte(input), FormatOptions{})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Повторное форматирование ничего не меняет
+	again, err := Format(got, FormatOptions{})
+	if err != nil || string(again) != string(got) {
+		t.Errorf("Format() не идемпотентен:\n%s", again)
+	}
+
+	sorted, err := Format([]byte(`{"b":1,"a":{"d":1,"c":2}}`), FormatOptions{Indent: "\t", SortKeys: true})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if want := "{\n\t\"a\": {\n\t\t\"c\": 2,\n\t\t\"d\": 1\n\t},\n\t\"b\": 1\n}\n"; string(sorted) != want {
+		t.Errorf("Format() с сортировкой =\n%s\nwant\n%s", sorted, want)
+	}
+
+	for _, invalid := range []string{`{"a":1} x`, `{"a":1-}`, `[1,]`} {
+		if _, err := Format([]byte(invalid), FormatOptions{}); err == nil {
+			t.Errorf("Format(%s) должен возвращать ошибку", invalid)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	input := `{"a":1,"list":[1,"x",null],"a":2,"f":1e400,"g":1e-400,"h":0.1000000000000000000001,"ok":[1.5,2]}`
+
+	issues, err := Lint([]byte(input), LintOptions{})
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	want := []Issue{
+		{14, `array "list" mixes number and string`},
+		{27, `duplicate key "a"`},
+		{37, "float 1e400 overflows float64"},
+		{47, "float 1e-400 underflows to zero"},
+		{58, "float 0.1000000000000000000001 has more digits than float64 can hold"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Lint() = %v, want %v", issues, want)
+	}
+
+	schema := map[string]interface{}{
+		"name":    "",
+		"servers": []interface{}{map[string]interface{}{"host": ""}},
+		"labels":  map[string]interface{}{"*": ""},
+	}
+	input = `{"name":"x","servers":[{"host":"a"},{"hostname":"b"}],"labels":{"any":"1"},"extra":{"deep":1}}`
+	issues, err = Lint([]byte(input), LintOptions{Schema: schema})
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	want = []Issue{
+		{37, `key "servers.1.hostname" is not in the schema`},
+		{75, `key "extra" is not in the schema`},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Lint() со схемой = %v, want %v", issues, want)
+	}
+
+	if _, err := Lint([]byte(`{"a":}`), LintOptions{}); err == nil {
+		t.Error("Lint() с неверным JSON должен возвращать ошибку")
+	}
+}
@@ -1,7 +1,12 @@
 package json
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -75,3 +80,1117 @@ func TestJSONNilValues(t *testing.T) {
 		t.Errorf("Unmarshal('null') error = %v", err)
 	}
 }
+
+func TestJSONBytes(t *testing.T) {
+	type Blob []byte
+	type Payload struct {
+		Data  []byte `json:"data"`
+		Named Blob   `json:"named"`
+		Empty []byte `json:"empty"`
+	}
+
+	original := Payload{Data: []byte{0xfb, 0xff, 0x01}, Named: Blob("hi")}
+
+	tests := []struct {
+		encoding BytesEncoding
+		want     string
+	}{
+		{Base64Std, `{"data":"+/8B","named":"aGk=","empty":null}`},
+		{Base64URL, `{"data":"-_8B","named":"aGk=","empty":null}`},
+		{Base64RawStd, `{"data":"+/8B","named":"aGk","empty":null}`},
+		{Base64RawURL, `{"data":"-_8B","named":"aGk","empty":null}`},
+		{Hex, `{"data":"fbff01","named":"6869","empty":null}`},
+	}
+
+	for _, tt := range tests {
+		serializer := New(WithBytesEncoding(tt.encoding))
+
+		data, err := serializer.Marshal(original)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal() = %s, want %s", data, tt.want)
+		}
+
+		var result Payload
+		if err := serializer.Unmarshal(data, &result); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if !reflect.DeepEqual(original, result) {
+			t.Errorf("Unmarshal() = %v, want %v", result, original)
+		}
+	}
+
+	var result Payload
+	if err := New().Unmarshal([]byte(`{"data":"not base64!"}`), &result); err == nil {
+		t.Error("Unmarshal() с неверным base64 должен возвращать ошибку")
+	}
+}
+
+// rawValue keeps the text UnmarshalJSON is given.
+type rawValue []byte
+
+func (r *rawValue) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func TestJSONSyntaxError(t *testing.T) {
+	tests := []struct {
+		input  string
+		offset int64
+	}{
+		{`{"a": [1, 2,, 3]}`, 12},
+		{`{"a": "unterminated}`, 6},
+		{`{"a": 1`, 7},
+		{`{"a" 1}`, 5},
+	}
+
+	for _, tt := range tests {
+		var result interface{}
+		err := New().Unmarshal([]byte(tt.input), &result)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Unmarshal(%s) error = %v, want *SyntaxError", tt.input, err)
+			continue
+		}
+		if syntaxErr.Offset != tt.offset {
+			t.Errorf("Unmarshal(%s) Offset = %d, want %d", tt.input, syntaxErr.Offset, tt.offset)
+		}
+	}
+
+	// Пропускаемые значения тоже проверяются: поле без места в структуре
+	// и текст для UnmarshalJSON.
+	skipped := []struct {
+		name string
+		v    any
+	}{
+		{"неизвестное поле", &struct {
+			A int `json:"a"`
+		}{}},
+		{"Unmarshaler", &struct {
+			A int      `json:"a"`
+			B rawValue `json:"b"`
+		}{}},
+	}
+	for _, tt := range skipped {
+		err := New().Unmarshal([]byte(`{"a":1,"b":1.2.3-e}`), tt.v)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok || syntaxErr.Offset != 11 {
+			t.Errorf("%s: Unmarshal() error = %v, want *SyntaxError at 11", tt.name, err)
+		}
+	}
+}
+
+func TestJSONIndent(t *testing.T) {
+	type Item struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	type Doc struct {
+		Title string            `json:"title"`
+		Items []Item            `json:"items"`
+		Empty []int             `json:"empty"`
+		Meta  map[string]string `json:"meta"`
+	}
+
+	doc := Doc{
+		Title: `a "b" {c}, [d]: \`,
+		Items: []Item{{Name: "x", Tags: []string{"1", "2"}}},
+		Empty: []int{},
+		Meta:  map[string]string{},
+	}
+
+	want := `{
+  "title": "a \"b\" {c}, [d]: \\",
+  "items": [
+    {
+      "name": "x",
+      "tags": [
+        "1",
+        "2"
+      ]
+    }
+  ],
+  "empty": [],
+  "meta": {}
+}`
+
+	serializer := New(WithIndent("  "))
+	data, err := serializer.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+
+	appended, err := serializer.Append([]byte("data: "), []int{1})
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if string(appended) != "data: [\n  1\n]" {
+		t.Errorf("Append() = %q", appended)
+	}
+
+	// При ошибке буфер вызывающего возвращается без изменений.
+	appended, err = serializer.Append([]byte("data: "), []any{1, make(chan int)})
+	if err == nil || string(appended) != "data: " {
+		t.Errorf("Append() = %q, %v, want the original buffer and an error", appended, err)
+	}
+}
+
+func TestJSONMapKeyOrder(t *testing.T) {
+	m := map[string]int{"c": 3, "a": 1, "b": 2, "d": 4}
+
+	for i := 0; i < 20; i++ {
+		data, err := New().Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != `{"a":1,"b":2,"c":3,"d":4}` {
+			t.Fatalf("Marshal() = %s", data)
+		}
+	}
+
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+	data, err := New(WithKeyComparator(reverse)).Marshal(map[string]map[string]int{"x": m})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"x":{"d":4,"c":3,"b":2,"a":1}}` {
+		t.Errorf("Marshal() with comparator = %s", data)
+	}
+
+	data, err = New(WithUnsortedKeys()).Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var result map[string]int
+	if err := New().Unmarshal(data, &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(result, m) {
+		t.Errorf("unsorted Marshal() = %s", data)
+	}
+}
+
+type point struct{ X, Y int }
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *point) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func TestJSONMapKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"int", map[int]string{2: "b", -1: "a"}, `{"-1":"a","2":"b"}`},
+		{"uint8", map[uint8]bool{7: true}, `{"7":true}`},
+		{"text", map[point]int{{1, 2}: 3}, `{"1,2":3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := New().Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", data, tt.want)
+			}
+
+			result := reflect.New(reflect.TypeOf(tt.value))
+			if err := New().Unmarshal(data, result.Interface()); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.Elem().Interface(), tt.value) {
+				t.Errorf("Unmarshal() = %v, want %v", result.Elem(), tt.value)
+			}
+		})
+	}
+
+	var small map[int8]string
+	if err := New().Unmarshal([]byte(`{"300":"x"}`), &small); err == nil {
+		t.Error("ожидалась ошибка для ключа вне диапазона int8")
+	}
+	if _, err := New().Marshal(map[float64]int{1.5: 1}); err == nil {
+		t.Error("ожидалась ошибка для ключа типа float64")
+	}
+}
+
+func TestJSONStringEscapes(t *testing.T) {
+	decode := []struct {
+		input string
+		want  string
+	}{
+		{`"plain"`, "plain"},
+		{`"line\nbreak\ttab"`, "line\nbreak\ttab"},
+		{`"back\\slash"`, `back\slash`},
+		{`"ends with \\"`, `ends with \`},
+		{`"quote \" and \/"`, `quote " and /`},
+		{`"été"`, "été"},
+		{`"\b\f\r"`, "\b\f\r"},
+		{`"😀"`, "😀"},
+		{`"\ud83d"`, "�"},
+		{`"\ude00x"`, "�x"},
+		{`"\ud83dA"`, "�A"},
+		{"\"bad \xff byte\"", "bad � byte"},
+	}
+	for _, tt := range decode {
+		var got string
+		if err := New().Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	var m map[string]string
+	if err := New().Unmarshal([]byte(`{"a\"b":"\\"}`), &m); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if m[`a"b`] != `\` {
+		t.Errorf("Unmarshal() = %q", m)
+	}
+
+	encode := []struct {
+		input string
+		want  string
+	}{
+		{"line\nbreak", `"line\nbreak"`},
+		{`a "quoted" \ path`, `"a \"quoted\" \\ path"`},
+		{"\x00\x1f\b\f", `"\u0000\u001f\b\f"`},
+		{"été 😀", `"été 😀"`},
+		{"bad \xff", `"bad \ufffd"`},
+	}
+	for _, tt := range encode {
+		data, err := New().Marshal(tt.input)
+		if err != nil {
+			t.Errorf("Marshal(%q) error = %v", tt.input, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%q) = %s, want %s", tt.input, data, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"\"raw\nnewline\"",
+		`"\x"`,
+		`"\u12"`,
+		`"\u12G4"`,
+		`"unterminated\"`,
+	}
+	for _, input := range invalid {
+		var got string
+		err := New().Unmarshal([]byte(input), &got)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Unmarshal(%q) error = %v, want *SyntaxError", input, err)
+		}
+	}
+
+	strict := New(WithInvalidUTF8(InvalidUTF8Error))
+	var got string
+	if err := strict.Unmarshal([]byte("\"ok \xff\""), &got); err == nil {
+		t.Error("ожидалась ошибка для некорректного UTF-8 при чтении")
+	}
+	if _, err := strict.Marshal(map[string]int{"\xff": 1}); err == nil {
+		t.Error("ожидалась ошибка для некорректного UTF-8 при записи")
+	}
+	if err := strict.Unmarshal([]byte(`"é"`), &got); err != nil || got != "é" {
+		t.Errorf("Unmarshal() = %q, %v", got, err)
+	}
+}
+
+func TestJSONTypeError(t *testing.T) {
+	type Item struct {
+		Count  int8    `json:"count"`
+		Size   uint16  `json:"size"`
+		Ratio  float32 `json:"ratio"`
+		Active bool    `json:"active"`
+	}
+	type Order struct {
+		Items []Item         `json:"items"`
+		Limit map[string]int `json:"limit"`
+	}
+
+	tests := []struct {
+		input  string
+		value  string
+		field  string
+		offset int64
+	}{
+		{`{"items":[{"count":128}]}`, "number 128", "items.0.count", 19},
+		{`{"items":[{"count":-129}]}`, "number -129", "items.0.count", 19},
+		{`{"items":[{},{"size":-1}]}`, "number -1", "items.1.size", 21},
+		{`{"items":[{"size":65536}]}`, "number 65536", "items.0.size", 18},
+		{`{"items":[{"count":1.5}]}`, "number 1.5", "items.0.count", 19},
+		{`{"items":[{"ratio":1e39}]}`, "number 1e39", "items.0.ratio", 19},
+		{`{"items":[{"active":"yes"}]}`, "string", "items.0.active", 20},
+		{`{"limit":{"a\"b":9223372036854775808}}`, "number 9223372036854775808", `limit.a"b`, 17},
+		{`{"items":{}}`, "object", "items", 9},
+		{`true`, "boolean", "", 0},
+	}
+	for _, tt := range tests {
+		var order Order
+		err := New().Unmarshal([]byte(tt.input), &order)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("Unmarshal(%s) error = %v, want *TypeError", tt.input, err)
+			continue
+		}
+		if typeErr.Value != tt.value || typeErr.Field != tt.field || typeErr.Offset != tt.offset {
+			t.Errorf("Unmarshal(%s) = %+v, want value %q, field %q, offset %d", tt.input, typeErr, tt.value, tt.field, tt.offset)
+		}
+	}
+
+	var item Item
+	err := New().Unmarshal([]byte(`{"count":1-2}`), &item)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("некорректное число должно давать SyntaxError, получено %v", err)
+	}
+}
+
+func TestJSONFloats(t *testing.T) {
+	tests := []struct {
+		input any
+		want  string
+	}{
+		{float32(0.1), "0.1"},
+		{float32(3.4e38), "3.4e+38"},
+		{float64(0.1), "0.1"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{-1.5e-7, "-1.5e-7"},
+		{0.000001, "0.000001"},
+		{float64(0), "0"},
+	}
+	for _, tt := range tests {
+		got, err := New().Marshal(tt.input)
+		if err != nil {
+			t.Errorf("Marshal(%v) error = %v", tt.input, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	values := []float64{math.NaN(), math.Inf(1), math.Inf(-1)}
+	if _, err := New().Marshal(values); err == nil {
+		t.Error("ожидалась ошибка для NaN и Inf")
+	}
+
+	got, err := New(WithNonFinite(NonFiniteNull)).Marshal(values)
+	if err != nil || string(got) != "[null,null,null]" {
+		t.Errorf("Marshal() с NonFiniteNull = %s, %v", got, err)
+	}
+
+	s := New(WithNonFinite(NonFiniteString))
+	got, err = s.Marshal(values)
+	if err != nil || string(got) != `["NaN","Infinity","-Infinity"]` {
+		t.Fatalf("Marshal() с NonFiniteString = %s, %v", got, err)
+	}
+	var decoded []float32
+	if err := s.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(decoded) != 3 || !math.IsNaN(float64(decoded[0])) || !math.IsInf(float64(decoded[1]), 1) || !math.IsInf(float64(decoded[2]), -1) {
+		t.Errorf("Unmarshal() = %v", decoded)
+	}
+
+	// Поле с опцией string не оборачивается в кавычки второй раз.
+	type Reading struct {
+		Value float64  `json:"value,string"`
+		Ptr   *float64 `json:"ptr,string"`
+	}
+	inf := math.Inf(1)
+	got, err = s.Marshal(Reading{Value: math.NaN(), Ptr: &inf})
+	if err != nil || string(got) != `{"value":"NaN","ptr":"Infinity"}` {
+		t.Fatalf("Marshal() с опцией string = %s, %v", got, err)
+	}
+	var reading Reading
+	if err := s.Unmarshal(got, &reading); err != nil || !math.IsNaN(reading.Value) || reading.Ptr == nil || !math.IsInf(*reading.Ptr, 1) {
+		t.Errorf("Unmarshal() = %+v, %v", reading, err)
+	}
+	got, err = New(WithNonFinite(NonFiniteNull)).Marshal(Reading{Value: math.NaN(), Ptr: &inf})
+	if err != nil || string(got) != `{"value":null,"ptr":null}` {
+		t.Errorf("Marshal() с опцией string и NonFiniteNull = %s, %v", got, err)
+	}
+	got, err = s.Marshal(Reading{Value: 1.5})
+	if err != nil || string(got) != `{"value":"1.5","ptr":null}` {
+		t.Errorf("Marshal() = %s, %v", got, err)
+	}
+}
+
+type Base struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Audit struct {
+	Created string `json:"created"`
+	Name    string
+}
+
+type Other struct {
+	Created string `json:"created"`
+}
+
+func TestJSONEmbedded(t *testing.T) {
+	type Item struct {
+		Base
+		*Audit
+		Name  string `json:"name"`
+		Other Other  `json:"other"`
+	}
+
+	item := Item{Base: Base{ID: 1, Name: "base"}, Name: "item", Other: Other{Created: "x"}}
+	data, err := New().Marshal(item)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	// Поля Base поднимаются, Name верхнего уровня закрывает Base.Name,
+	// а nil-указатель *Audit пропускается целиком.
+	if want := `{"id":1,"name":"item","other":{"created":"x"}}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded Item
+	if err := New().Unmarshal([]byte(`{"id":2,"name":"n","created":"today","Name":"audit"}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.ID != 2 || decoded.Name != "n" || decoded.Base.Name != "" || decoded.Audit == nil || decoded.Created != "today" || decoded.Audit.Name != "audit" {
+		t.Errorf("Unmarshal() = %+v, audit %+v", decoded, decoded.Audit)
+	}
+
+	// Одинаковые имена на одной глубине без тегов конфликтуют и не пишутся.
+	type Label struct{ Name string }
+	type Conflict struct {
+		Audit
+		Label
+	}
+	data, err = New().Marshal(Conflict{Audit{Created: "a", Name: "n"}, Label{Name: "l"}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"created":"a"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	type Named struct {
+		Base  `json:"base"`
+		Extra Other `json:",inline"`
+	}
+	data, err = New().Marshal(Named{Base{ID: 3}, Other{Created: "c"}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"base":{"id":3,"name":""},"created":"c"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+type window struct{ From, To int }
+
+func (w window) IsZero() bool { return w.From == w.To }
+
+func TestJSONTagOptions(t *testing.T) {
+	type Options struct {
+		Name    string            `json:"name,omitempty"`
+		Tags    []string          `json:"tags,omitempty"`
+		Meta    map[string]string `json:",omitempty"`
+		Limit   *int              `json:"limit,omitempty"`
+		Window  window            `json:"window,omitzero"`
+		Count   int64             `json:"count,string"`
+		Enabled bool              `json:"enabled,string,omitempty"`
+	}
+
+	data, err := New().Marshal(Options{Window: window{3, 3}, Count: 42})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"count":"42"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	data, err = New().Marshal(Options{Meta: map[string]string{"a": "b"}, Window: window{1, 2}, Enabled: true})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"Meta":{"a":"b"},"window":{"From":1,"To":2},"count":"0","enabled":"true"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded Options
+	if err := New().Unmarshal([]byte(`{"count":"-7","enabled":" true "}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Count != -7 || !decoded.Enabled {
+		t.Errorf("Unmarshal() = %+v", decoded)
+	}
+	var typeErr *TypeError
+	err = New().Unmarshal([]byte(`{"count":"7 apples"}`), &decoded)
+	if !errors.As(err, &typeErr) || typeErr.Field != "count" {
+		t.Errorf("Unmarshal() error = %v, want TypeError for count", err)
+	}
+
+	type Server struct {
+		Host string `json:"host,required"`
+		Port int    `json:"port"`
+	}
+	type Config struct {
+		Servers []Server `json:"servers"`
+	}
+	var config Config
+	if err := New().Unmarshal([]byte(`{"servers":[{"host":"a"}]}`), &config); err != nil {
+		t.Errorf("Unmarshal() error = %v", err)
+	}
+	err = New().Unmarshal([]byte(`{"servers":[{"host":"a"},{"port":80}]}`), &config)
+	var missing *MissingFieldError
+	if !errors.As(err, &missing) || missing.Field != "servers.1.host" {
+		t.Errorf("Unmarshal() error = %v, want missing servers.1.host", err)
+	}
+}
+
+func TestJSONStrict(t *testing.T) {
+	type Server struct {
+		Host string `json:"host"`
+	}
+	type Config struct {
+		Name    string   `json:"name"`
+		Servers []Server `json:"servers"`
+	}
+
+	input := `{"name":"a","servers":[{"host":"h","port":80}]} `
+	var config Config
+	if err := New().Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	var unknown *UnknownFieldError
+	err := New(Strict()).Unmarshal([]byte(input), &config)
+	if !errors.As(err, &unknown) || unknown.Field != "servers.0.port" {
+		t.Errorf("Unmarshal() error = %v, want unknown servers.0.port", err)
+	}
+
+	syntax := []string{
+		`{"name":"a"} garbage`,
+		`{"name":"a"}{}`,
+		`{"name":"a","name":"b"}`,
+		`{"servers":[{"host":"a","host":"b"}]}`,
+	}
+	for _, input := range syntax {
+		var syntaxErr *SyntaxError
+		if err := New(Strict()).Unmarshal([]byte(input), &config); !errors.As(err, &syntaxErr) {
+			t.Errorf("Unmarshal(%s) error = %v, want SyntaxError", input, err)
+		}
+		if err := New().Unmarshal([]byte(input), &config); err != nil {
+			t.Errorf("Unmarshal(%s) без Strict error = %v", input, err)
+		}
+	}
+
+	var generic map[string]interface{}
+	if err := New(Strict()).Unmarshal([]byte(`{"a":{"b":1,"b":2}}`), &generic); err == nil {
+		t.Error("ожидалась ошибка для повторяющегося ключа в map")
+	}
+}
+
+func TestJSONFieldMatching(t *testing.T) {
+	type User struct {
+		UserID int    `json:"userId" alias:"user_id,uid"`
+		Name   string `json:"name"`
+	}
+
+	inputs := []string{
+		`{"userId": 5, "name": "a"}`,
+		`{"UserID": 5, "NAME": "a"}`,
+		`{"user_id": 5, "name": "a"}`,
+		`{"UID": 5, "Name": "a"}`,
+	}
+	for _, input := range inputs {
+		var u User
+		if err := New(Strict()).Unmarshal([]byte(input), &u); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", input, err)
+			continue
+		}
+		if u.UserID != 5 || u.Name != "a" {
+			t.Errorf("Unmarshal(%s) = %+v", input, u)
+		}
+	}
+
+	// Точное совпадение имени важнее совпадения без учёта регистра.
+	type Pair struct {
+		Lower string `json:"key"`
+		Upper string `json:"KEY"`
+	}
+	var p Pair
+	if err := New().Unmarshal([]byte(`{"KEY": "u", "key": "l"}`), &p); err != nil || p != (Pair{"l", "u"}) {
+		t.Errorf("Unmarshal() = %+v, %v", p, err)
+	}
+	if err := New().Unmarshal([]byte(`{"Key": "x"}`), &p); err != nil || p.Lower != "x" {
+		t.Errorf("Unmarshal() = %+v, %v", p, err)
+	}
+
+	ambiguous := []struct {
+		input string
+		field string
+	}{
+		{`{"Key": "x"}`, "Key"},
+		{`{"items": [{"userId": 1, "user_id": 2}]}`, "items.0.user_id"},
+	}
+	for _, tt := range ambiguous {
+		var v struct {
+			Pair
+			Items []User `json:"items"`
+		}
+		var ambiguousErr *AmbiguousFieldError
+		err := New(Strict()).Unmarshal([]byte(tt.input), &v)
+		if !errors.As(err, &ambiguousErr) || ambiguousErr.Field != tt.field {
+			t.Errorf("Unmarshal(%s) error = %v, want ambiguous %s", tt.input, err, tt.field)
+		}
+	}
+}
+
+func TestJSONMerge(t *testing.T) {
+	type Server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type Config struct {
+		Name    string                 `json:"name"`
+		Labels  map[string]string      `json:"labels"`
+		Servers []Server               `json:"servers"`
+		Tags    []string               `json:"tags"`
+		Limits  *Server                `json:"limits"`
+		Extra   map[string]interface{} `json:"extra"`
+	}
+	defaults := func() Config {
+		return Config{
+			Name:    "default",
+			Labels:  map[string]string{"env": "dev", "team": "core"},
+			Servers: []Server{{Host: "a", Port: 80}, {Host: "b", Port: 81}},
+			Tags:    []string{"x"},
+			Limits:  &Server{Host: "limit", Port: 1},
+			Extra:   map[string]interface{}{"db": map[string]interface{}{"user": "root", "pool": int64(4)}},
+		}
+	}
+	input := `{"labels":{"env":"prod"},"servers":[{"port":8080}],"tags":["y"],"limits":{"port":2},"extra":{"db":{"pool":8}}}`
+
+	config := defaults()
+	if err := New(WithMerge(MergeSliceElements)).Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Config{
+		Name:    "default",
+		Labels:  map[string]string{"env": "prod", "team": "core"},
+		Servers: []Server{{Host: "a", Port: 8080}, {Host: "b", Port: 81}},
+		Tags:    []string{"y"},
+		Limits:  &Server{Host: "limit", Port: 2},
+		Extra:   map[string]interface{}{"db": map[string]interface{}{"user": "root", "pool": int64(8)}},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", config, want)
+	}
+
+	config = defaults()
+	if err := New(WithMerge(AppendSlices)).Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(config.Servers) != 3 || config.Servers[2] != (Server{Port: 8080}) || !reflect.DeepEqual(config.Tags, []string{"x", "y"}) {
+		t.Errorf("Unmarshal() с AppendSlices = %+v", config)
+	}
+
+	// Без WithMerge map и срезы заменяются целиком.
+	config = defaults()
+	if err := New().Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(config.Labels) != 1 || len(config.Servers) != 1 || config.Servers[0].Host != "" {
+		t.Errorf("Unmarshal() без WithMerge = %+v", config)
+	}
+}
+
+func TestJSONArrays(t *testing.T) {
+	type Point struct {
+		Coords [3]int     `json:"coords"`
+		Grid   [2][2]int  `json:"grid"`
+		Ptr    uintptr    `json:"ptr"`
+		Names  [2]string  `json:"names"`
+		Pair   [2]float64 `json:"pair"`
+	}
+	in := Point{Coords: [3]int{1, 2, 3}, Grid: [2][2]int{{1, 2}, {3, 4}}, Ptr: 7, Names: [2]string{"a", "b"}, Pair: [2]float64{0.5, 1}}
+
+	data, err := New().Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var out Point
+	if err := New().Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out != in {
+		t.Errorf("Unmarshal() = %+v, want %+v", out, in)
+	}
+
+	for _, input := range []string{`{"coords":[1,2]}`, `{"coords":[1,2,3,4]}`} {
+		var p Point
+		err := New().Unmarshal([]byte(input), &p)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) || typeErr.Field != "coords" {
+			t.Errorf("Unmarshal(%s) error = %v, want TypeError for coords", input, err)
+		}
+	}
+
+	var p Point
+	p.Coords = [3]int{9, 9, 9}
+	if err := New(WithArrayLength(ArrayTruncate)).Unmarshal([]byte(`{"coords":[1,2],"names":["x","y","z"]}`), &p); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if p.Coords != [3]int{1, 2, 0} || p.Names != [2]string{"x", "y"} {
+		t.Errorf("Unmarshal() с ArrayTruncate = %+v", p)
+	}
+}
+
+func TestJSONComplex(t *testing.T) {
+	type Signal struct {
+		Z complex128 `json:"z"`
+		W complex64  `json:"w"`
+	}
+	in := Signal{Z: complex(1.5, -2), W: complex(0, 1)}
+
+	if _, err := New().Marshal(in); err == nil {
+		t.Error("Marshal() без WithComplexFormat должен вернуть ошибку")
+	}
+
+	tests := []struct {
+		format ComplexFormat
+		want   string
+	}{
+		{ComplexString, `{"z":"1.5-2i","w":"0+1i"}`},
+		{ComplexArray, `{"z":[1.5,-2],"w":[0,1]}`},
+	}
+	for _, tt := range tests {
+		s := New(WithComplexFormat(tt.format))
+		data, err := s.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal() = %s, want %s", data, tt.want)
+		}
+		var out Signal
+		if err := s.Unmarshal([]byte(tt.want), &out); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if out != in {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.want, out, in)
+		}
+	}
+
+	// Оба вида принимаются при любом формате.
+	var out Signal
+	if err := New(WithComplexFormat(ComplexString)).Unmarshal([]byte(`{"z":[1.5,-2],"w":"1i"}`), &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out != (Signal{Z: complex(1.5, -2), W: complex(0, 1)}) {
+		t.Errorf("Unmarshal() = %+v", out)
+	}
+
+	for _, input := range []string{`{"z":[1]}`, `{"z":"abc"}`, `{"z":[1,"a"]}`} {
+		err := New(WithComplexFormat(ComplexArray)).Unmarshal([]byte(input), &out)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("Unmarshal(%s) error = %v, want TypeError", input, err)
+		}
+	}
+}
+
+func TestJSONCycles(t *testing.T) {
+	type Node struct {
+		Name     string         `json:"name"`
+		Next     *Node          `json:"next"`
+		Children []any          `json:"children"`
+		Meta     map[string]any `json:"meta"`
+	}
+
+	self := &Node{Name: "a"}
+	self.Next = &Node{Name: "b", Next: self}
+
+	list := &Node{Name: "list"}
+	list.Children = []any{1, nil}
+	list.Children[1] = list.Children
+
+	meta := map[string]any{}
+	meta["self"] = meta
+	withMap := &Node{Name: "map", Meta: meta}
+
+	tests := []struct {
+		name  string
+		value any
+		field string
+	}{
+		{"указатель", self, "next.next"},
+		{"срез", list, "children.1"},
+		{"map", withMap, "meta.self"},
+	}
+	for _, tt := range tests {
+		_, err := New().Marshal(tt.value)
+		var cycleErr *CycleError
+		if !errors.As(err, &cycleErr) || cycleErr.Field != tt.field {
+			t.Errorf("%s: Marshal() error = %v, want cycle at %s", tt.name, err, tt.field)
+		}
+	}
+
+	// Один и тот же указатель в соседних полях — не цикл.
+	shared := &Node{Name: "shared"}
+	if _, err := New().Marshal([]*Node{shared, shared}); err != nil {
+		t.Errorf("Marshal() error = %v", err)
+	}
+
+	// Длинная цепочка: открытые значения глубже первых нескольких
+	// отслеживаются отдельно, цикл в глубине тоже должен находиться.
+	s := New()
+	head := &Node{Name: "0"}
+	tail := head
+	for i := 1; i < 100; i++ {
+		tail.Next = &Node{Name: strconv.Itoa(i)}
+		tail = tail.Next
+	}
+	tail.Next = head.Next.Next
+	var cycleErr *CycleError
+	if _, err := s.Marshal(head); !errors.As(err, &cycleErr) || strings.Count(cycleErr.Field, "next") != 100 {
+		t.Errorf("Marshal() error = %v, want cycle 100 levels deep", err)
+	}
+	tail.Next = nil
+	if _, err := s.Marshal(head); err != nil {
+		t.Errorf("Marshal() после цикла error = %v", err)
+	}
+}
+
+func TestJSONMaxDepth(t *testing.T) {
+	deep := strings.Repeat("[", 20) + strings.Repeat("]", 20)
+
+	var v any
+	if err := New().Unmarshal([]byte(deep), &v); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	var limitErr *LimitError
+	err := New(WithMaxDepth(10)).Unmarshal([]byte(deep), &v)
+	if !errors.As(err, &limitErr) || limitErr.Limit != "depth" || limitErr.Offset != 10 {
+		t.Errorf("Unmarshal() error = %v, want depth limit at offset 10", err)
+	}
+	err = New(WithMaxDepth(10)).Unmarshal([]byte(`{"a":`+deep+`}`), &struct{ A [][]any }{})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Unmarshal() в структуру error = %v, want ErrLimitExceeded", err)
+	}
+
+	// Без ограничения глубина не проверяется.
+	if err := New(WithMaxDepth(0)).Unmarshal([]byte(strings.Repeat("[", 20000)+strings.Repeat("]", 20000)), &v); err != nil {
+		t.Errorf("Unmarshal() без ограничения error = %v", err)
+	}
+	if err := New().Unmarshal([]byte(strings.Repeat("[", 20000)), &v); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Unmarshal() с глубиной по умолчанию error = %v, want ErrLimitExceeded", err)
+	}
+
+	type Tree struct {
+		Items []any `json:"items"`
+	}
+	if _, err := New(WithMaxDepth(3)).Marshal(Tree{Items: []any{[]any{1}}}); err != nil {
+		t.Errorf("Marshal() error = %v", err)
+	}
+	_, err = New(WithMaxDepth(3)).Marshal(Tree{Items: []any{[]any{[]any{1}}}})
+	var depthErr *DepthError
+	if !errors.As(err, &depthErr) || depthErr.Field != "items.0.0" || depthErr.Depth != 3 {
+		t.Errorf("Marshal() error = %v, want DepthError at items.0.0", err)
+	}
+}
+
+func TestJSONLimits(t *testing.T) {
+	limits := Limits{
+		MaxInputSize:    100,
+		MaxStringLength: 5,
+		MaxElements:     3,
+		MaxAllocations:  6,
+	}
+	tests := []struct {
+		input string
+		limit string
+	}{
+		{`"` + strings.Repeat("a", 100) + `"`, "input size"},
+		{`["abcdef"]`, "string length"},
+		{`{"abcdef": 1}`, "string length"},
+		{`"\u0041\u0041\u0041\u0041\u0041\u0041"`, "string length"},
+		{`[1, 2, 3, 4]`, "elements"},
+		{`{"a": 1, "b": 2, "c": 3, "d": 4}`, "elements"},
+		{`[[1, 2], [3, 4], [5]]`, "allocations"},
+	}
+	s := New(WithLimits(limits))
+	for _, tt := range tests {
+		var v any
+		err := s.Unmarshal([]byte(tt.input), &v)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tt.limit || !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Unmarshal(%.20s) error = %v, want %s limit", tt.input, err, tt.limit)
+		}
+	}
+
+	type Item struct {
+		Name string `json:"name"`
+	}
+	var items []Item
+	if err := s.Unmarshal([]byte(`[{"name": "abcde"}, {"name": "b"}]`), &items); err != nil {
+		t.Errorf("Unmarshal() в пределах ограничений error = %v", err)
+	}
+	err := s.Unmarshal([]byte(`[{"name": "abcdef"}]`), &items)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Offset != 11 {
+		t.Errorf("Unmarshal() error = %v, want string length limit at offset 11", err)
+	}
+
+	// Limits.MaxDepth заменяет WithMaxDepth.
+	var v any
+	if err := New(WithMaxDepth(50), WithLimits(Limits{MaxDepth: 2})).Unmarshal([]byte(`[[[1]]]`), &v); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Unmarshal() error = %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestJSONCollectErrors(t *testing.T) {
+	type Server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type Config struct {
+		Name    string         `json:"name,required"`
+		Timeout int            `json:"timeout"`
+		Servers []Server       `json:"servers"`
+		Limits  map[string]int `json:"limits"`
+		Debug   bool           `json:"debug"`
+	}
+	input := `{"timeout": "5s", "servers": [{"host": "a", "port": 1}, {"host": 2, "port": "x"}], "limits": {"cpu": 2, "mem": "1G"}, "debug": true}`
+	cfg := Config{Timeout: 30}
+	err := New(CollectErrors()).Unmarshal([]byte(input), &cfg)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("Unmarshal() error = %v, want *MultiError", err)
+	}
+	want := []struct {
+		field  string
+		offset int64
+	}{
+		{"timeout", 12},
+		{"servers.1.host", 65},
+		{"servers.1.port", 76},
+		{"limits.mem", 111},
+		{"name", 0},
+	}
+	if len(multi.Errors) != len(want) {
+		t.Fatalf("Errors = %v, want %d errors", multi.Errors, len(want))
+	}
+	for i, w := range want {
+		var field string
+		var offset int64
+		switch e := multi.Errors[i].(type) {
+		case *TypeError:
+			field, offset = e.Field, e.Offset
+		case *MissingFieldError:
+			field, offset = e.Field, e.Offset
+		}
+		if field != w.field || offset != w.offset {
+			t.Errorf("Errors[%d] = %v at %d, want %s at %d", i, multi.Errors[i], offset, w.field, w.offset)
+		}
+	}
+	if !strings.Contains(err.Error(), "; ") {
+		t.Errorf("Error() = %q", err)
+	}
+
+	// Поля с ошибками обнулены, остальные декодированы.
+	if cfg.Timeout != 0 || !cfg.Debug || cfg.Servers[0].Port != 1 || cfg.Servers[1] != (Server{}) || cfg.Limits["cpu"] != 2 {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if v, ok := cfg.Limits["mem"]; !ok || v != 0 {
+		t.Errorf("Limits[mem] = %v, %v, want 0, true", v, ok)
+	}
+
+	// В строгом режиме собираются и неизвестные поля.
+	err = New(Strict(), CollectErrors()).Unmarshal([]byte(`{"name": "x", "extra": 1, "timeout": true}`), &cfg)
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("Unmarshal() error = %v, want 2 errors", err)
+	}
+	var unknown *UnknownFieldError
+	if !errors.As(multi.Errors[0], &unknown) || unknown.Field != "extra" {
+		t.Errorf("Errors[0] = %v, want unknown field extra", multi.Errors[0])
+	}
+
+	// Синтаксические ошибки по-прежнему прерывают декодирование.
+	err = New(CollectErrors()).Unmarshal([]byte(`{"timeout": "5s", "debug": tru}`), &cfg)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Unmarshal() error = %v, want *SyntaxError", err)
+	}
+}
+
+// foldPair decodes itself as serializer-gen would for two fields whose
+// names differ only in case.
+type foldPair struct {
+	Upper, Lower int
+}
+
+var foldPairKeys = NewKeys([]string{"NAME"}, []string{"name"})
+
+func (p *foldPair) DecodeJSON(r *Reader) error {
+	return r.Object(func(key []byte) error {
+		name, err := r.Key(key, foldPairKeys)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "NAME":
+			v, err := r.Int()
+			p.Upper = int(v)
+			return err
+		case "name":
+			v, err := r.Int()
+			p.Lower = int(v)
+			return err
+		}
+		return r.UnknownField()
+	})
+}
+
+func TestJSONCollectErrorsDecoder(t *testing.T) {
+	var p foldPair
+	err := New(Strict(), CollectErrors()).Unmarshal([]byte(`{"Name": {"a": 1}, "name": "x", "NAME": 3, "other": 4}`), &p)
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 3 {
+		t.Fatalf("Unmarshal() error = %v, want 3 errors", err)
+	}
+	var ambiguous *AmbiguousFieldError
+	if !errors.As(multi.Errors[0], &ambiguous) || ambiguous.Field != "Name" {
+		t.Errorf("Errors[0] = %v, want ambiguous field Name", multi.Errors[0])
+	}
+	var typeErr *TypeError
+	if !errors.As(multi.Errors[1], &typeErr) || typeErr.Field != "name" {
+		t.Errorf("Errors[1] = %v, want type error in name", multi.Errors[1])
+	}
+	var unknown *UnknownFieldError
+	if !errors.As(multi.Errors[2], &unknown) || unknown.Field != "other" {
+		t.Errorf("Errors[2] = %v, want unknown field other", multi.Errors[2])
+	}
+	if p != (foldPair{Upper: 3}) {
+		t.Errorf("Unmarshal() = %+v", p)
+	}
+}
@@ -0,0 +1,189 @@
+package json
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Issue is a problem found by Lint. Offset is the byte offset of the key
+// or value it refers to.
+type Issue struct {
+	Offset  int64
+	Message string
+}
+
+// LintOptions configure Lint. Schema, when not nil, is a decoded document
+// listing the allowed keys: objects give the keys allowed at their level,
+// a "*" key allows any key, and the first element of an array describes
+// every element.
+type LintOptions struct {
+	Schema interface{}
+}
+
+// Lint reports duplicate keys, arrays mixing value types, float literals
+// that float64 cannot represent and, with a schema, unknown keys. Syntax
+// errors are returned as *SyntaxError.
+func Lint(data []byte, opts LintOptions) ([]Issue, error) {
+	l := &linter{d: decodeState{s: defaultSerializer, data: data}, schema: opts.Schema != nil}
+	if _, err := l.value(nil, opts.Schema); err != nil {
+		return nil, err
+	}
+	if err := l.d.end(); err != nil {
+		return nil, err
+	}
+	return l.issues, nil
+}
+
+type linter struct {
+	d      decodeState
+	schema bool
+	issues []Issue
+}
+
+func (l *linter) report(offset int, format string, args ...any) {
+	l.issues = append(l.issues, Issue{Offset: int64(offset), Message: fmt.Sprintf(format, args...)})
+}
+
+// value checks one value and returns its kind for the mixed array check.
+func (l *linter) value(path []string, schema interface{}) (string, error) {
+	c, err := l.d.peek()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case c == '{':
+		return "object", l.object(path, schema)
+	case c == '[':
+		return "array", l.array(path, schema)
+	case c == '-' || isDigit(c):
+		start := l.d.off
+		num, err := l.d.number()
+		if err != nil {
+			return "", err
+		}
+		if msg := suspiciousFloat(string(num)); msg != "" {
+			l.report(start, "%s", msg)
+		}
+		return "number", nil
+	case c == '"':
+		return "string", l.d.skip()
+	case c == 'n':
+		return "null", l.d.skip()
+	default:
+		return "boolean", l.d.skip()
+	}
+}
+
+func (l *linter) object(path []string, schema interface{}) error {
+	seen := make(map[string]bool)
+	return l.d.objectFields(func(key []byte) error {
+		offset := l.d.offsetOf(l.d.rawKey) - 1
+		name := string(key)
+		keyPath := append(path[:len(path):len(path)], name)
+
+		if seen[name] {
+			l.report(offset, "duplicate key %q", strings.Join(keyPath, "."))
+		}
+		seen[name] = true
+
+		child, ok := schemaKey(schema, name)
+		if l.schema && !ok {
+			l.report(offset, "key %q is not in the schema", strings.Join(keyPath, "."))
+			l.schema = false
+			defer func() { l.schema = true }()
+		}
+
+		_, err := l.value(keyPath, child)
+		return err
+	})
+}
+
+func (l *linter) array(path []string, schema interface{}) error {
+	start := l.d.off
+	elem := schemaElem(schema)
+	kinds := make(map[string]bool)
+	var order []string
+	i := 0
+	err := l.d.arrayElements(func() error {
+		kind, err := l.value(append(path[:len(path):len(path)], strconv.Itoa(i)), elem)
+		if kind != "null" && !kinds[kind] {
+			kinds[kind] = true
+			order = append(order, kind)
+		}
+		i++
+		return err
+	})
+	if err == nil && len(order) > 1 {
+		l.report(start, "array %s mixes %s", displayPath(path), listKinds(order))
+	}
+	return err
+}
+
+// offsetOf returns the offset of b, a slice of the input.
+func (d *decodeState) offsetOf(b []byte) int {
+	return cap(d.data) - cap(b)
+}
+
+func displayPath(path []string) string {
+	if len(path) == 0 {
+		return "at top level"
+	}
+	return strconv.Quote(strings.Join(path, "."))
+}
+
+func schemaKey(schema interface{}, key string) (interface{}, bool) {
+	table, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if child, ok := table[key]; ok {
+		return child, true
+	}
+	child, ok := table["*"]
+	return child, ok
+}
+
+func schemaElem(schema interface{}) interface{} {
+	if arr, ok := schema.([]interface{}); ok && len(arr) > 0 {
+		return arr[0]
+	}
+	return schema
+}
+
+// suspiciousFloat describes why a float literal does not survive the trip
+// through float64, or returns "" if it does.
+func suspiciousFloat(lit string) string {
+	if !strings.ContainsAny(lit, ".eE") {
+		return ""
+	}
+
+	f, err := strconv.ParseFloat(lit, 64)
+	if math.IsInf(f, 0) {
+		return fmt.Sprintf("float %s overflows float64", lit)
+	}
+	if err != nil {
+		return ""
+	}
+
+	mantissa := strings.TrimLeft(lit, "+-")
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		mantissa = mantissa[:i]
+	}
+	digits := strings.Trim(strings.Replace(mantissa, ".", "", 1), "0")
+	if f == 0 && digits != "" {
+		return fmt.Sprintf("float %s underflows to zero", lit)
+	}
+	if len(digits) > 17 {
+		return fmt.Sprintf("float %s has more digits than float64 can hold", lit)
+	}
+	return ""
+}
+
+// listKinds joins kinds as "a, b and c".
+func listKinds(kinds []string) string {
+	last := len(kinds) - 1
+	return strings.Join(kinds[:last], ", ") + " and " + kinds[last]
+}
@@ -0,0 +1,484 @@
+package json
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Marshaler is implemented by types that encode themselves as JSON.
+type Marshaler interface {
+	MarshalJSON() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves from a JSON
+// value. The data passed in is the raw text of that value.
+type Unmarshaler interface {
+	UnmarshalJSON(data []byte) error
+}
+
+// Appender is implemented by code generated by serializer-gen. It is
+// preferred over Marshaler since it writes into the encoder's buffer.
+type Appender interface {
+	AppendJSON(buf []byte) ([]byte, error)
+}
+
+// Encoder is implemented by code generated by serializer-gen. It is
+// preferred over Appender since the Writer carries the options of the
+// serializer in use and the nesting of the value being encoded.
+type Encoder interface {
+	EncodeJSON(w *Writer, buf []byte) ([]byte, error)
+}
+
+// Decoder is implemented by code generated by serializer-gen. It is
+// preferred over Unmarshaler since it reads the value in place instead of
+// scanning it twice.
+type Decoder interface {
+	DecodeJSON(r *Reader) error
+}
+
+type methodSet uint8
+
+const (
+	hasEncoder methodSet = 1 << iota
+	hasAppender
+	hasMarshaler
+	hasDecoder
+	hasUnmarshaler
+)
+
+var (
+	encoderType     = reflect.TypeOf((*Encoder)(nil)).Elem()
+	appenderType    = reflect.TypeOf((*Appender)(nil)).Elem()
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	decoderType     = reflect.TypeOf((*Decoder)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	methodCache sync.Map // map[reflect.Type]methodSet
+)
+
+// typeMethods reports which of the interfaces above t implements for
+// encoding, and *t for decoding. Only named types and pointers can have
+// methods, so everything else is answered without a cache lookup.
+func typeMethods(t reflect.Type) methodSet {
+	if t.PkgPath() == "" && t.Kind() != reflect.Ptr {
+		return 0
+	}
+	if m, ok := methodCache.Load(t); ok {
+		return m.(methodSet)
+	}
+
+	var m methodSet
+	if t.Kind() != reflect.Interface {
+		if t.Implements(encoderType) {
+			m |= hasEncoder
+		}
+		if t.Implements(appenderType) {
+			m |= hasAppender
+		}
+		if t.Implements(marshalerType) {
+			m |= hasMarshaler
+		}
+		if t.Kind() != reflect.Ptr {
+			pt := reflect.PointerTo(t)
+			if pt.Implements(decoderType) {
+				m |= hasDecoder
+			}
+			if pt.Implements(unmarshalerType) {
+				m |= hasUnmarshaler
+			}
+		}
+	}
+	methodCache.Store(t, m)
+	return m
+}
+
+// Reader gives generated decoders token-level access to a JSON document,
+// sharing the conversions of the reflective decoder. With CollectErrors
+// its methods record field errors, skip the value and return its zero
+// value, as the reflective decoder does.
+type Reader struct {
+	d *decodeState
+
+	// ambiguous is set when Key has recorded an ambiguous key, so that
+	// UnknownField only skips its value.
+	ambiguous bool
+}
+
+func NewReader(data []byte) *Reader {
+	d := &decodeState{s: defaultSerializer, data: data}
+	d.reader.d = d
+	return &d.reader
+}
+
+// Null consumes a null literal if it is the next value.
+func (r *Reader) Null() bool {
+	c, err := r.d.peek()
+	return err == nil && c == 'n' && r.d.readLiteral("null")
+}
+
+func (r *Reader) String() (string, error) {
+	start := r.d.off
+	str, err := r.expectString(stringType)
+	if err != nil {
+		return "", r.collect(err, start)
+	}
+	return string(str), nil
+}
+
+// Bytes decodes a string using the bytes encoding of the serializer. A
+// null value yields a nil slice.
+func (r *Reader) Bytes() ([]byte, error) {
+	if r.Null() {
+		return nil, nil
+	}
+	start := r.d.off
+	str, err := r.expectString(bytesType)
+	if err != nil {
+		return nil, r.collect(err, start)
+	}
+	b, err := r.d.s.bytesEncoding.decode(string(str))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %q as bytes: %v", str, err)
+	}
+	return b, nil
+}
+
+func (r *Reader) Int() (int64, error) {
+	return r.IntBits(64)
+}
+
+// IntBits reads an integer that fits in bitSize bits (8, 16, 32 or 64),
+// returning a *TypeError for values out of range.
+func (r *Reader) IntBits(bitSize int) (int64, error) {
+	start := r.d.off
+	t := intTypes[bitSize/8]
+	num, err := r.expectNumber(t)
+	if err != nil {
+		return 0, r.collect(err, start)
+	}
+	i, err := r.d.convertInt(num, t)
+	return i, r.collect(err, start)
+}
+
+func (r *Reader) Uint() (uint64, error) {
+	return r.UintBits(64)
+}
+
+// UintBits reads an unsigned integer that fits in bitSize bits (8, 16, 32
+// or 64), returning a *TypeError for negative values and values out of
+// range.
+func (r *Reader) UintBits(bitSize int) (uint64, error) {
+	start := r.d.off
+	t := uintTypes[bitSize/8]
+	num, err := r.expectNumber(t)
+	if err != nil {
+		return 0, r.collect(err, start)
+	}
+	u, err := r.d.convertUint(num, t)
+	return u, r.collect(err, start)
+}
+
+func (r *Reader) Float() (float64, error) {
+	return r.FloatBits(64)
+}
+
+// FloatBits reads a number that fits a float of bitSize bits (32 or 64).
+func (r *Reader) FloatBits(bitSize int) (float64, error) {
+	start := r.d.off
+	t := float64Type
+	if bitSize == 32 {
+		t = float32Type
+	}
+	num, err := r.expectNumber(t)
+	if err != nil {
+		return 0, r.collect(err, start)
+	}
+	f, err := r.d.convertFloat(num, t)
+	return f, r.collect(err, start)
+}
+
+func (r *Reader) Bool() (bool, error) {
+	start := r.d.off
+	c, err := r.d.peek()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case c == 't' && r.d.readLiteral("true"):
+		return true, nil
+	case c == 'f' && r.d.readLiteral("false"):
+		return false, nil
+	}
+	return false, r.collect(r.d.mismatch(c, boolType), start)
+}
+
+// Object calls fn for every member of an object, positioned at the value.
+// key is only valid until fn reads the value.
+func (r *Reader) Object(fn func(key []byte) error) error {
+	if err := r.expectDelim('{'); err != nil {
+		return err
+	}
+	return r.d.objectFields(fn)
+}
+
+// Array calls fn for every element of an array.
+func (r *Reader) Array(fn func() error) error {
+	if err := r.expectDelim('['); err != nil {
+		return err
+	}
+	return r.d.arrayElements(fn)
+}
+
+// Slice tells a generated decoder how to store an array into a slice of
+// length n, as WithMerge selects: it keeps the first keep elements and
+// stores the array from index start on, overwriting kept elements before
+// appending.
+func (r *Reader) Slice(n int) (keep, start int) {
+	switch {
+	case !r.d.s.merge || r.d.s.sliceMerge == ReplaceSlices:
+		return 0, 0
+	case r.d.s.sliceMerge == AppendSlices:
+		return n, n
+	}
+	return n, 0
+}
+
+func (r *Reader) Skip() error {
+	return r.d.skip()
+}
+
+// Keys lists the keys a generated decoder accepts for each field of a
+// struct, for Reader.Key.
+type Keys struct {
+	fields []field
+}
+
+// NewKeys returns the Keys for a struct. Each element of names holds a
+// field name followed by its aliases.
+func NewKeys(names ...[]string) *Keys {
+	k := &Keys{fields: make([]field, len(names))}
+	for i, n := range names {
+		k.fields[i] = field{name: n[0], aliases: n[1:]}
+	}
+	return k
+}
+
+// Key returns the name of the field that key stands for, matching aliases
+// and ignoring case as the reflective decoder does, or "" if there is
+// none. In strict mode a key matching several fields is an error, or with
+// CollectErrors is recorded and treated as unknown.
+func (r *Reader) Key(key []byte, keys *Keys) (string, error) {
+	i, ambiguous := lookupField(keys.fields, key)
+	switch {
+	case i < 0:
+		return "", nil
+	case ambiguous && r.d.s.strict:
+		err := &AmbiguousFieldError{Offset: int64(r.d.off)}
+		if !r.d.s.collectErrors {
+			return "", err
+		}
+		// objectFields fills in the key.
+		r.d.errs = append(r.d.errs, err)
+		r.ambiguous = true
+		return "", nil
+	}
+	return keys.fields[i].name, nil
+}
+
+// UnknownField skips the value of a key that the decoder has no field for,
+// or fails in strict mode.
+func (r *Reader) UnknownField() error {
+	if r.ambiguous {
+		r.ambiguous = false
+		return r.d.skip()
+	}
+	return r.d.unknownField()
+}
+
+// Decode reads the next value into v using the reflective decoder.
+func (r *Reader) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer")
+	}
+	start := r.d.off
+	return r.d.collectError(r.d.value(rv.Elem()), start, rv.Elem())
+}
+
+// collect passes err, from reading the value at start, through
+// collectError.
+func (r *Reader) collect(err error, start int) error {
+	return r.d.collectError(err, start, reflect.Value{})
+}
+
+func (r *Reader) expectDelim(delim byte) error {
+	c, err := r.d.peek()
+	if err != nil {
+		return err
+	}
+	if c != delim {
+		return r.d.syntaxError(fmt.Sprintf("expected %q", delim))
+	}
+	return nil
+}
+
+func (r *Reader) expectString(t reflect.Type) ([]byte, error) {
+	c, err := r.d.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c != '"' {
+		return nil, r.d.mismatch(c, t)
+	}
+	return r.d.readString()
+}
+
+func (r *Reader) expectNumber(t reflect.Type) ([]byte, error) {
+	c, err := r.d.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c != '-' && !isDigit(c) {
+		return nil, r.d.mismatch(c, t)
+	}
+	return r.d.readNumber(), nil
+}
+
+var (
+	stringType  = reflect.TypeOf("")
+	bytesType   = reflect.TypeOf([]byte(nil))
+	boolType    = reflect.TypeOf(false)
+	float32Type = reflect.TypeOf(float32(0))
+	float64Type = reflect.TypeOf(float64(0))
+
+	// Indexed by size in bytes.
+	intTypes  = [...]reflect.Type{1: reflect.TypeOf(int8(0)), 2: reflect.TypeOf(int16(0)), 4: reflect.TypeOf(int32(0)), 8: reflect.TypeOf(int64(0))}
+	uintTypes = [...]reflect.Type{1: reflect.TypeOf(uint8(0)), 2: reflect.TypeOf(uint16(0)), 4: reflect.TypeOf(uint32(0)), 8: reflect.TypeOf(uint64(0))}
+)
+
+// mismatch reports that the value starting with c is not of type t, or a
+// syntax error if no value starts with c.
+func (d *decodeState) mismatch(c byte, t reflect.Type) error {
+	var value string
+	switch {
+	case c == '"':
+		value = "string"
+	case c == '{':
+		value = "object"
+	case c == '[':
+		value = "array"
+	case c == '-' || isDigit(c):
+		value = "number"
+	case c == 't' || c == 'f':
+		value = "boolean"
+	case c == 'n':
+		value = "null"
+	default:
+		return d.syntaxError("unexpected value")
+	}
+	return &TypeError{Value: value, Type: t, Offset: int64(d.off)}
+}
+
+var defaultSerializer = New()
+
+// Writer gives generated encoders the conversions of the reflective
+// encoder, with the options of the serializer in use.
+type Writer struct {
+	e *encodeState
+}
+
+// NewWriter returns a Writer with default options.
+func NewWriter() *Writer {
+	e := &encodeState{s: defaultSerializer}
+	e.writer.e = e
+	return &e.writer
+}
+
+// Enter opens an object or array, failing past the maximum depth. After an
+// error the Writer is done with, so only successful paths call Leave.
+func (w *Writer) Enter() error {
+	return w.e.enter(reflect.Value{})
+}
+
+func (w *Writer) Leave() {
+	w.e.leave(reflect.Value{})
+}
+
+// String appends s as a quoted string, applying the InvalidUTF8 policy.
+func (w *Writer) String(buf []byte, s string) ([]byte, error) {
+	return w.e.s.appendStringValue(buf, s)
+}
+
+// Float appends f as a float of bitSize bits, applying the NonFinite
+// policy.
+func (w *Writer) Float(buf []byte, f float64, bitSize int) ([]byte, error) {
+	return w.e.s.appendFloatValue(buf, f, bitSize)
+}
+
+// Bytes appends b in the bytes encoding of the serializer, or null for a
+// nil slice.
+func (w *Writer) Bytes(buf []byte, b []byte) []byte {
+	if b == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '"')
+	buf = w.e.s.bytesEncoding.appendEncode(buf, b)
+	return append(buf, '"')
+}
+
+// Value appends v using the reflective encoder.
+func (w *Writer) Value(buf []byte, v any) ([]byte, error) {
+	return w.e.appendValue(buf, reflect.ValueOf(v))
+}
+
+// WithField adds key in front of the field path of a *TypeError,
+// *MissingFieldError, *UnknownFieldError, *AmbiguousFieldError,
+// *CycleError or *DepthError returned by generated code. Other errors are
+// returned as is.
+func WithField(err error, key string) error {
+	return withField(err, key)
+}
+
+// IsEmpty reports whether the value v points to is left out of the output
+// as a field tagged omitempty.
+func IsEmpty(v any) bool {
+	f := field{omitEmpty: true}
+	return f.omitted(reflect.ValueOf(v).Elem())
+}
+
+// IsZero reports whether the value v points to is left out of the output
+// as a field tagged omitzero.
+func IsZero(v any) bool {
+	return isZero(reflect.ValueOf(v).Elem())
+}
+
+// AppendValue appends v using the reflective encoder with default options.
+func AppendValue(buf []byte, v any) ([]byte, error) {
+	return defaultSerializer.appendValue(buf, reflect.ValueOf(v))
+}
+
+// AppendString appends s as a quoted JSON string.
+func AppendString(buf []byte, s string) []byte {
+	return appendString(buf, s)
+}
+
+// AppendFloat appends f formatted as the encoder formats floats of the
+// given bit size.
+func AppendFloat(buf []byte, f float64, bitSize int) ([]byte, error) {
+	return appendFloat(buf, f, bitSize)
+}
+
+// AppendBytes appends b as a base64 string, or null for a nil slice.
+func AppendBytes(buf []byte, b []byte) []byte {
+	if b == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '"')
+	buf = Base64Std.appendEncode(buf, b)
+	return append(buf, '"')
+}
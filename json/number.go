@@ -0,0 +1,239 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Number is a JSON number kept as the literal text it was written with.
+// Decoding into a Number, or into interface{} with UseNumber, avoids
+// rounding large or precise values through float64.
+type Number string
+
+func (n Number) String() string {
+	return string(n)
+}
+
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns n as an integer. Literals with a fraction or exponent are
+// accepted as long as they denote a whole number, e.g. "1e3".
+func (n Number) Int64() (int64, error) {
+	return intLiteral([]byte(n))
+}
+
+var (
+	numberType   = reflect.TypeOf(Number(""))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// leadingZero reports whether num starts with a zero followed by another
+// digit, which strconv accepts but the JSON grammar forbids.
+func leadingZero(num []byte) bool {
+	if len(num) > 0 && num[0] == '-' {
+		num = num[1:]
+	}
+	return len(num) > 1 && num[0] == '0' && isDigit(num[1])
+}
+
+// isValidNumber reports whether s follows the JSON number grammar.
+func isValidNumber(s []byte) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+
+	// Integer part: 0 or a digit sequence without leading zeros.
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case s[0] >= '1' && s[0] <= '9':
+		s = skipDigits(s[1:])
+	default:
+		return false
+	}
+
+	if len(s) > 0 && s[0] == '.' {
+		if len(s) < 2 || !isDigit(s[1]) {
+			return false
+		}
+		s = skipDigits(s[2:])
+	}
+
+	if len(s) > 0 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+			s = s[1:]
+		}
+		if len(s) == 0 || !isDigit(s[0]) {
+			return false
+		}
+		s = skipDigits(s)
+	}
+	return len(s) == 0
+}
+
+func skipDigits(s []byte) []byte {
+	for len(s) > 0 && isDigit(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+// maxExponent bounds the exponents splitNumber returns, far beyond any
+// value a target can hold, so that adjusting them cannot overflow.
+const maxExponent = 1 << 30
+
+// splitNumber returns the significant digits of a literal, without
+// leading or trailing zeros, and the power of ten they are scaled by,
+// clamped to maxExponent. ok is false if the literal is malformed.
+func splitNumber(num []byte) (neg bool, digits string, exp int, ok bool) {
+	s := string(num)
+	neg = strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		// Out of range, Atoi returns the largest int of the right sign.
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return neg, "", 0, false
+		}
+		exp, s = min(max(e, -maxExponent), maxExponent), s[:i]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		exp -= len(s) - i - 1
+		s = s[:i] + s[i+1:]
+	}
+
+	s = strings.TrimLeft(s, "0")
+	for len(s) > 0 && s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+		exp++
+	}
+	return neg, s, exp, true
+}
+
+// integerText rewrites a literal with a fraction or exponent as plain
+// decimal digits, failing if it is not a whole number.
+func integerText(num []byte) (string, error) {
+	neg, s, exp, ok := splitNumber(num)
+	switch {
+	case !ok:
+		return "", fmt.Errorf("invalid number %s", num)
+	case s == "":
+		return "0", nil
+	case exp < 0:
+		return "", fmt.Errorf("cannot convert %s to integer", num)
+	case exp > 20-len(s):
+		return "", fmt.Errorf("number %s overflows 64-bit integer", num)
+	}
+
+	s += strings.Repeat("0", exp)
+	if neg {
+		s = "-" + s
+	}
+	return s, nil
+}
+
+func appendNumber(buf []byte, n Number) ([]byte, error) {
+	if n == "" {
+		return append(buf, '0'), nil
+	}
+	if !isValidNumber([]byte(n)) {
+		return nil, fmt.Errorf("invalid number literal %q", n)
+	}
+	return append(buf, n...), nil
+}
+
+// appendBig writes big.Int and big.Float values as number literals with
+// all their digits.
+func appendBig(buf []byte, v reflect.Value) ([]byte, error) {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	if v.Type() == bigIntType {
+		i := v.Addr().Interface().(*big.Int)
+		return i.Append(buf, 10), nil
+	}
+
+	f := v.Addr().Interface().(*big.Float)
+	if f.IsInf() {
+		return nil, fmt.Errorf("unsupported value: %v", f)
+	}
+	return f.Append(buf, 'g', -1), nil
+}
+
+// bigValue decodes a number literal, or a string holding one, into a
+// big.Int or big.Float without going through float64.
+func (d *decodeState) bigValue(rv reflect.Value) error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	start := d.off
+	var num []byte
+	switch {
+	case c == '"':
+		if num, err = d.readString(); err != nil {
+			return err
+		}
+	case c == '-' || isDigit(c):
+		num = d.readNumber()
+	case c == 'n' && d.readLiteral("null"):
+		return nil
+	default:
+		return &TypeError{Value: "non-number value", Type: rv.Type(), Offset: int64(start)}
+	}
+	if !isValidNumber(num) {
+		d.off = start
+		return d.syntaxError("invalid number")
+	}
+
+	if rv.Type() == bigFloatType {
+		f := rv.Addr().Interface().(*big.Float)
+		prec := f.Prec()
+		if prec == 0 {
+			// Enough bits for every digit of the literal, and no less than
+			// float64 offers.
+			prec = max(uint(len(num))*4, 64)
+		}
+		parsed, _, err := big.ParseFloat(string(num), 10, prec, big.ToNearestEven)
+		if err != nil {
+			return &TypeError{Value: "number " + string(num), Type: rv.Type(), Offset: int64(start)}
+		}
+		f.Set(parsed)
+		return nil
+	}
+
+	// The exponent is expanded into digits, which Limits bounds.
+	neg, digits, exp, ok := splitNumber(num)
+	switch {
+	case digits == "":
+		rv.Addr().Interface().(*big.Int).SetInt64(0)
+		return nil
+	case !ok || exp < 0:
+		return &TypeError{Value: "number " + string(num), Type: rv.Type(), Offset: int64(start)}
+	case exp > d.s.maxIntegerDigits()-len(digits):
+		return &LimitError{Limit: "integer digits", Max: d.s.maxIntegerDigits(), Offset: int64(start)}
+	}
+	text := digits + strings.Repeat("0", exp)
+	if neg {
+		text = "-" + text
+	}
+	rv.Addr().Interface().(*big.Int).SetString(text, 10)
+	return nil
+}
@@ -0,0 +1,211 @@
+package json
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	type Item struct {
+		Price Number `json:"price"`
+	}
+
+	var item Item
+	if err := New().Unmarshal([]byte(`{"price":12.50e-1}`), &item); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if item.Price != "12.50e-1" {
+		t.Errorf("Price = %q, want literal text", item.Price)
+	}
+	if f, err := item.Price.Float64(); err != nil || f != 1.25 {
+		t.Errorf("Float64() = %v, %v", f, err)
+	}
+
+	data, err := New().Marshal(item)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"price":12.50e-1}` {
+		t.Errorf("Marshal() = %s", data)
+	}
+	if _, err := New().Marshal(Number("12,5")); err == nil {
+		t.Error("ожидалась ошибка для некорректного Number")
+	}
+
+	var generic interface{}
+	if err := New(UseNumber()).Unmarshal([]byte(`[18446744073709551617, 0.1]`), &generic); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []interface{}{Number("18446744073709551617"), Number("0.1")}
+	if !reflect.DeepEqual(generic, want) {
+		t.Errorf("UseNumber: got %#v, want %#v", generic, want)
+	}
+}
+
+func TestNumberExponents(t *testing.T) {
+	var ints struct {
+		A int64  `json:"a"`
+		B int    `json:"b"`
+		C uint64 `json:"c"`
+		D int    `json:"d"`
+	}
+	input := `{"a":1e3,"b":-2.5E2,"c":18446744073709551615,"d":0.0e10}`
+	if err := New().Unmarshal([]byte(input), &ints); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if ints.A != 1000 || ints.B != -250 || ints.C != math.MaxUint64 || ints.D != 0 {
+		t.Errorf("Unmarshal() = %+v", ints)
+	}
+
+	var n int
+	for _, input := range []string{"1.5", "1e-1", "1e30"} {
+		if err := New().Unmarshal([]byte(input), &n); err == nil {
+			t.Errorf("Unmarshal(%s) в int: ожидалась ошибка", input)
+		}
+	}
+
+	var generic []interface{}
+	if err := New().Unmarshal([]byte(`[1, 1e2, 9223372036854775808]`), &generic); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []interface{}{int64(1), float64(100), uint64(9223372036854775808)}
+	if !reflect.DeepEqual(generic, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", generic, want)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	type Balance struct {
+		Total  big.Int    `json:"total"`
+		Rate   *big.Float `json:"rate"`
+		Shares *big.Int   `json:"shares"`
+	}
+
+	input := `{"total":123456789012345678901234567890,"rate":0.1000000000000000000000001,"shares":12e20}`
+	var b Balance
+	if err := New().Unmarshal([]byte(input), &b); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if b.Total.String() != "123456789012345678901234567890" {
+		t.Errorf("Total = %s", b.Total.String())
+	}
+	if b.Shares.String() != "1200000000000000000000" {
+		t.Errorf("Shares = %s", b.Shares)
+	}
+
+	data, err := New().Marshal(b)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"total":123456789012345678901234567890,"rate":0.1000000000000000000000001,"shares":1200000000000000000000}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	if err := New().Unmarshal([]byte(`{"total":1.5}`), &b); err == nil {
+		t.Error("ожидалась ошибка для дробного big.Int")
+	}
+}
+
+func TestInvalidNumbers(t *testing.T) {
+	var generic interface{}
+	var total big.Int
+	targets := map[string]any{"interface{}": &generic, "big.Int": &total}
+
+	for _, input := range []string{"-", "1.2.3", "-.5", "1e", "2-3", "01", "-01"} {
+		for name, v := range targets {
+			err := New().Unmarshal([]byte(input), v)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("Unmarshal(%s) в %s: ожидалась SyntaxError, получено %v", input, name, err)
+			}
+		}
+	}
+
+	var syntaxErr *SyntaxError
+	err := New().Unmarshal([]byte(`{"total": "1.2.3"}`), &struct {
+		Total big.Int `json:"total"`
+	}{})
+	if !errors.As(err, &syntaxErr) || syntaxErr.Offset != 10 {
+		t.Errorf("Unmarshal() error = %v, want SyntaxError at offset 10", err)
+	}
+
+	// strconv принимает ведущие нули, грамматика JSON — нет.
+	var (
+		i int
+		u uint
+		f float64
+	)
+	for _, v := range []any{&i, &u, &f} {
+		if err := New().Unmarshal([]byte(`00`), v); !errors.As(err, &syntaxErr) {
+			t.Errorf("Unmarshal(00) в %T: ожидалась SyntaxError, получено %v", v, err)
+		}
+	}
+
+	var typeErr *TypeError
+	if err := New().Unmarshal([]byte(`[1e999]`), &generic); !errors.As(err, &typeErr) || typeErr.Offset != 1 {
+		t.Errorf("Unmarshal(1e999) error = %v, want TypeError at offset 1", err)
+	}
+}
+
+func TestHugeExponents(t *testing.T) {
+	var (
+		i int
+		u uint
+	)
+	for _, input := range []string{"1e9223372036854775807", "-1e9223372036854775807", "1e99999999999999999999", "1.5e9223372036854775807"} {
+		for _, v := range []any{&i, &u} {
+			var typeErr *TypeError
+			if err := New().Unmarshal([]byte(input), v); !errors.As(err, &typeErr) {
+				t.Errorf("Unmarshal(%s) в %T error = %v, want TypeError", input, v, err)
+			}
+		}
+		if _, err := Number(input).Int64(); err == nil {
+			t.Errorf("Number(%s).Int64(): ожидалась ошибка", input)
+		}
+	}
+	if err := New().Unmarshal([]byte("1e-9223372036854775808"), &i); err == nil {
+		t.Error("Unmarshal(1e-9223372036854775808) в int: ожидалась ошибка")
+	}
+	if err := New().Unmarshal([]byte("0e9223372036854775807"), &i); err != nil || i != 0 {
+		t.Errorf("Unmarshal(0e9223372036854775807) = %d, %v", i, err)
+	}
+}
+
+func TestBigIntDigitsLimit(t *testing.T) {
+	s := New(WithLimits(Limits{MaxIntegerDigits: 30}))
+
+	var n big.Int
+	if err := s.Unmarshal([]byte(`12e28`), &n); err != nil || n.String() != "12"+strings.Repeat("0", 28) {
+		t.Errorf("Unmarshal(12e28) = %s, %v", n.String(), err)
+	}
+	for _, input := range []string{"1e999999", "1e30", strings.Repeat("9", 31)} {
+		var limitErr *LimitError
+		if err := s.Unmarshal([]byte(input), &n); !errors.As(err, &limitErr) || limitErr.Limit != "integer digits" {
+			t.Errorf("Unmarshal(%.20s) error = %v, want integer digits LimitError", input, err)
+		}
+	}
+
+	// Порядок около MaxInt64 не переполняет проверку длины.
+	for _, limits := range []Limits{{MaxIntegerDigits: 100}, {}} {
+		for _, input := range []string{"1e9223372036854775807", "10e9223372036854775807", "1e99999999999999999999", "1e2000000000"} {
+			var limitErr *LimitError
+			if err := New(WithLimits(limits)).Unmarshal([]byte(input), &n); !errors.As(err, &limitErr) {
+				t.Errorf("Unmarshal(%s) с %+v error = %v, want LimitError", input, limits, err)
+			}
+		}
+	}
+
+	// Отрицательный порядок не раскрывается в цифры.
+	var typeErr *TypeError
+	if err := s.Unmarshal([]byte(`1e-999999`), &n); !errors.As(err, &typeErr) {
+		t.Errorf("Unmarshal(1e-999999) error = %v, want TypeError", err)
+	}
+	if err := s.Unmarshal([]byte(`-1.20e2`), &n); err != nil || n.String() != "-120" {
+		t.Errorf("Unmarshal(-1.20e2) = %s, %v", n.String(), err)
+	}
+}
@@ -0,0 +1,265 @@
+package json
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+type Option func(*JSONSerializer)
+
+// BytesEncoding selects how []byte values are represented as JSON strings.
+type BytesEncoding int
+
+const (
+	Base64Std BytesEncoding = iota
+	Base64URL
+	Base64RawStd
+	Base64RawURL
+	Hex
+)
+
+func WithBytesEncoding(e BytesEncoding) Option {
+	return func(s *JSONSerializer) {
+		s.bytesEncoding = e
+	}
+}
+
+// WithIndent makes Marshal and Append put every object member and array
+// element on its own line, indented by indent per level. Empty objects and
+// arrays stay on one line.
+func WithIndent(indent string) Option {
+	return func(s *JSONSerializer) {
+		s.indent = indent
+	}
+}
+
+// WithUnsortedKeys writes map keys in map iteration order, which saves
+// sorting them but makes the output differ between runs.
+func WithUnsortedKeys() Option {
+	return func(s *JSONSerializer) {
+		s.unsortedKeys = true
+	}
+}
+
+// WithKeyComparator sorts map keys with cmp instead of byte order. cmp
+// returns a negative number when a goes before b, as strings.Compare does.
+func WithKeyComparator(cmp func(a, b string) int) Option {
+	return func(s *JSONSerializer) {
+		s.compareKeys = cmp
+	}
+}
+
+// UseNumber makes numbers decoded into interface{} values Number instead
+// of int64, uint64 or float64.
+func UseNumber() Option {
+	return func(s *JSONSerializer) {
+		s.useNumber = true
+	}
+}
+
+// Strict makes Unmarshal reject object keys that match no struct field,
+// keys repeated within an object, and anything but whitespace after the
+// top-level value.
+func Strict() Option {
+	return func(s *JSONSerializer) {
+		s.strict = true
+	}
+}
+
+// CollectErrors makes Unmarshal go on past values it cannot store, such
+// as a string for an int field, leaving them zero, and return a
+// *MultiError with every field error at the end. Syntax errors and limits
+// still stop decoding.
+func CollectErrors() Option {
+	return func(s *JSONSerializer) {
+		s.collectErrors = true
+	}
+}
+
+// SliceMerge selects how WithMerge decodes an array into a slice that
+// already has elements.
+type SliceMerge int
+
+const (
+	// ReplaceSlices replaces the slice with the decoded elements.
+	ReplaceSlices SliceMerge = iota
+	// AppendSlices appends the decoded elements to the slice.
+	AppendSlices
+	// MergeSliceElements decodes each element into the existing element at
+	// the same index, appending those beyond the slice's length.
+	MergeSliceElements
+)
+
+// WithMerge makes Unmarshal overlay the input on the value it decodes
+// into, so that a struct filled with defaults can be updated from a
+// partial document. Maps, including map[string]interface{} values held in
+// interfaces, keep their entries and have decoded keys merged into the
+// existing values; slices are handled as selected by slices.
+func WithMerge(slices SliceMerge) Option {
+	return func(s *JSONSerializer) {
+		s.merge = true
+		s.sliceMerge = slices
+	}
+}
+
+// defaultMaxDepth is deep enough for any reasonable document while keeping
+// the recursion well inside the goroutine stack limit.
+const defaultMaxDepth = 10000
+
+// WithMaxDepth limits how deeply objects and arrays may nest: Unmarshal
+// returns a *LimitError for deeper input and Marshal a *DepthError for
+// deeper values. A depth of 0 or less removes the limit.
+func WithMaxDepth(depth int) Option {
+	return func(s *JSONSerializer) {
+		s.maxDepth = depth
+	}
+}
+
+// Limits bounds the work Unmarshal does for untrusted input. Input beyond
+// a limit fails with a *LimitError matching ErrLimitExceeded. Zero fields
+// are not checked.
+type Limits struct {
+	// MaxInputSize is the largest document accepted, in bytes.
+	MaxInputSize int
+	// MaxDepth, when set, replaces the depth given to WithMaxDepth.
+	MaxDepth int
+	// MaxStringLength is the longest string or object key, in bytes after
+	// unescaping.
+	MaxStringLength int
+	// MaxElements is the most elements a single array, or members a single
+	// object, may have.
+	MaxElements int
+	// MaxAllocations is the most array elements and object members the
+	// whole document may have, which bounds the slices, maps and strings
+	// allocated for it.
+	MaxAllocations int
+	// MaxIntegerDigits is the most digits a number decoded into a big.Int
+	// may have once its exponent is written out, so that 1e999999 does not
+	// allocate a million digits. Zero or less means 10000.
+	MaxIntegerDigits int
+}
+
+const defaultMaxIntegerDigits = 10000
+
+func (s *JSONSerializer) maxIntegerDigits() int {
+	if s.limits.MaxIntegerDigits > 0 {
+		return s.limits.MaxIntegerDigits
+	}
+	return defaultMaxIntegerDigits
+}
+
+func WithLimits(limits Limits) Option {
+	return func(s *JSONSerializer) {
+		s.limits = limits
+		if limits.MaxDepth > 0 {
+			s.maxDepth = limits.MaxDepth
+		}
+	}
+}
+
+// ArrayLength selects what happens when a JSON array has a different
+// number of elements than the Go array it is decoded into.
+type ArrayLength int
+
+const (
+	// ArrayExact makes Unmarshal fail with a *TypeError.
+	ArrayExact ArrayLength = iota
+	// ArrayTruncate drops extra elements and zeroes missing ones, as
+	// encoding/json does.
+	ArrayTruncate
+)
+
+func WithArrayLength(policy ArrayLength) Option {
+	return func(s *JSONSerializer) {
+		s.arrayLength = policy
+	}
+}
+
+// ComplexFormat selects how complex64 and complex128 values are written.
+// Without WithComplexFormat they are unsupported, as in encoding/json.
+type ComplexFormat int
+
+const (
+	ComplexUnsupported ComplexFormat = iota
+	// ComplexString writes strings such as "1+2i".
+	ComplexString
+	// ComplexArray writes the real and imaginary parts as [1,2].
+	ComplexArray
+)
+
+// WithComplexFormat enables complex numbers. Unmarshal accepts both forms
+// whichever format is chosen.
+func WithComplexFormat(format ComplexFormat) Option {
+	return func(s *JSONSerializer) {
+		s.complexFormat = format
+	}
+}
+
+// InvalidUTF8 selects what happens to strings that are not valid UTF-8.
+type InvalidUTF8 int
+
+const (
+	// InvalidUTF8Replace replaces each invalid byte with U+FFFD, as
+	// encoding/json does.
+	InvalidUTF8Replace InvalidUTF8 = iota
+	// InvalidUTF8Error makes Marshal and Unmarshal fail.
+	InvalidUTF8Error
+)
+
+func WithInvalidUTF8(policy InvalidUTF8) Option {
+	return func(s *JSONSerializer) {
+		s.invalidUTF8 = policy
+	}
+}
+
+// NonFinite selects how NaN and infinite floats, which JSON numbers cannot
+// represent, are written.
+type NonFinite int
+
+const (
+	// NonFiniteError makes Marshal fail, as encoding/json does.
+	NonFiniteError NonFinite = iota
+	// NonFiniteNull writes null.
+	NonFiniteNull
+	// NonFiniteString writes the strings "NaN", "Infinity" and "-Infinity",
+	// and accepts them back when decoding into a float.
+	NonFiniteString
+)
+
+// WithNonFinite sets the policy for NaN and infinite floats. AppendJSON
+// and MarshalJSON methods called directly use NonFiniteError.
+func WithNonFinite(policy NonFinite) Option {
+	return func(s *JSONSerializer) {
+		s.nonFinite = policy
+	}
+}
+
+func (e BytesEncoding) appendEncode(dst, b []byte) []byte {
+	switch e {
+	case Base64URL:
+		return base64.URLEncoding.AppendEncode(dst, b)
+	case Base64RawStd:
+		return base64.RawStdEncoding.AppendEncode(dst, b)
+	case Base64RawURL:
+		return base64.RawURLEncoding.AppendEncode(dst, b)
+	case Hex:
+		return hex.AppendEncode(dst, b)
+	default:
+		return base64.StdEncoding.AppendEncode(dst, b)
+	}
+}
+
+func (e BytesEncoding) decode(s string) ([]byte, error) {
+	switch e {
+	case Base64URL:
+		return base64.URLEncoding.DecodeString(s)
+	case Base64RawStd:
+		return base64.RawStdEncoding.DecodeString(s)
+	case Base64RawURL:
+		return base64.RawURLEncoding.DecodeString(s)
+	case Hex:
+		return hex.DecodeString(s)
+	default:
+		return base64.StdEncoding.DecodeString(s)
+	}
+}
@@ -16,6 +16,19 @@ func New(format string) (Serializer, error) {
 	}
 }
 
+// NewStrict is like New, but the serializer's Unmarshal rejects unknown
+// fields, duplicate keys and trailing data.
+func NewStrict(format string) (Serializer, error) {
+	switch format {
+	case "json", "JSON":
+		return json.New(json.Strict()), nil
+	case "toml", "TOML":
+		return toml.New(toml.Strict()), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
 type GinSerializer struct {
 	serializer Serializer
 }
@@ -0,0 +1,395 @@
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+type itemKind int
+
+const (
+	itemTrivia itemKind = iota // blank lines and comments
+	itemTable                  // [table] and [[array]] headers
+	itemKeyValue
+)
+
+// docItem is one line of a document, or several for multi-line values.
+// raw holds the exact source text including the trailing newline. For
+// headers valueStart and valueEnd both point right after the header.
+type docItem struct {
+	kind       itemKind
+	raw        string
+	path       []string
+	isArray    bool
+	valueStart int
+	valueEnd   int
+}
+
+func (it *docItem) setValue(literal string) {
+	it.raw = it.raw[:it.valueStart] + literal + it.raw[it.valueEnd:]
+	it.valueEnd = it.valueStart + len(literal)
+}
+
+func (it *docItem) isComment() bool {
+	return it.kind == itemTrivia && strings.TrimSpace(it.raw) != ""
+}
+
+// Document is a TOML file parsed for editing. Comments, key order and
+// layout are kept, and regions that were not edited are written back
+// byte for byte.
+type Document struct {
+	items []*docItem
+}
+
+func ParseDocument(data []byte) (*Document, error) {
+	input := string(data)
+	if _, err := newParser(input).parse(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	p := newParser(input)
+
+	var table []string
+	inArray := false
+	for start := 0; start < len(input); {
+		item := &docItem{}
+
+		switch p.token.typ {
+		case tokenNewline, tokenEOF:
+			item.kind = itemTrivia
+
+		case tokenLeftBracket:
+			item.kind = itemTable
+			p.next()
+			item.isArray = p.token.typ == tokenLeftBracket
+			if item.isArray {
+				p.next()
+			}
+			item.path, _ = p.parseKey()
+			p.next() // skip ]
+			if item.isArray {
+				p.next()
+			}
+			item.valueStart = p.prevEnd - start
+			item.valueEnd = item.valueStart
+			table, inArray = item.path, item.isArray
+
+		default:
+			item.kind = itemKeyValue
+			key, _ := p.parseKey()
+			p.next() // skip =
+			item.valueStart = p.token.pos - start
+			if _, err := p.parseValue(); err != nil {
+				return nil, err
+			}
+			item.valueEnd = p.prevEnd - start
+			item.path = append(table[:len(table):len(table)], key...)
+			item.isArray = inArray
+		}
+
+		end := len(input)
+		if p.token.typ == tokenNewline {
+			end = p.token.pos + 1
+			p.next()
+		}
+		item.raw = input[start:end]
+		doc.items = append(doc.items, item)
+		start = end
+	}
+
+	return doc, nil
+}
+
+func (d *Document) Bytes() []byte {
+	return []byte(d.String())
+}
+
+func (d *Document) String() string {
+	var b strings.Builder
+	for _, item := range d.items {
+		b.WriteString(item.raw)
+	}
+	return b.String()
+}
+
+// Decode unmarshals the current contents of the document into v.
+func (d *Document) Decode(v any) error {
+	return New().Unmarshal(d.Bytes(), v)
+}
+
+// Get returns the value at a dotted key. Elements of arrays, including
+// arrays of tables, are addressed by their index, e.g. "servers.0.host".
+func (d *Document) Get(key string) (interface{}, bool) {
+	path, err := parseDottedKey(key)
+	if err != nil {
+		return nil, false
+	}
+
+	tree, err := newParser(d.String()).parse()
+	if err != nil {
+		return nil, false
+	}
+	return lookupPath(tree, path)
+}
+
+// Set replaces the value at a dotted key, keeping the key's surrounding
+// whitespace and comment, or adds the key to the table that owns it.
+func (d *Document) Set(key string, value any) error {
+	path, err := parseDottedKey(key)
+	if err != nil {
+		return err
+	}
+
+	literal, err := New().appendValue(nil, indirect(reflect.ValueOf(value)))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range d.items {
+		if item.kind == itemKeyValue && !item.isArray && equalPath(item.path, path) {
+			item.setValue(string(literal))
+			return nil
+		}
+	}
+
+	if item := d.inlineOwner(path); item != nil {
+		return d.editInline(item, path[len(item.path):], func(table map[string]interface{}, key string) error {
+			table[key] = value
+			return nil
+		})
+	}
+
+	section, err := d.section(path)
+	if err != nil {
+		return err
+	}
+
+	rest := path
+	if section >= 0 {
+		rest = path[len(d.items[section].path):]
+	}
+	d.insert(d.insertIndex(section), &docItem{
+		kind: itemKeyValue,
+		raw:  joinKey(rest) + " = " + string(literal) + "\n",
+		path: path,
+	})
+	return nil
+}
+
+// Delete removes a key, or a whole [table] section with its contents,
+// including the sections of its subtables and arrays of tables.
+func (d *Document) Delete(key string) error {
+	path, err := parseDottedKey(key)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range d.items {
+		if item.kind == itemKeyValue && !item.isArray && equalPath(item.path, path) {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return nil
+		}
+	}
+
+	// Dotted keys outside any section under the table define it too.
+	drop := make([]bool, len(d.items))
+	found := false
+	for i, item := range d.items {
+		switch {
+		case item.kind == itemTable && hasPrefix(item.path, path):
+			start, end := d.sectionRange(i)
+			for j := start; j < end; j++ {
+				drop[j] = true
+			}
+			found = true
+		case item.kind == itemKeyValue && !item.isArray && hasPrefix(item.path, path):
+			drop[i] = true
+			found = true
+		}
+	}
+	if found {
+		items := d.items[:0]
+		for i, item := range d.items {
+			if !drop[i] {
+				items = append(items, item)
+			}
+		}
+		d.items = items
+		return nil
+	}
+
+	if item := d.inlineOwner(path); item != nil {
+		return d.editInline(item, path[len(item.path):], func(table map[string]interface{}, key string) error {
+			if _, ok := table[key]; !ok {
+				return fmt.Errorf("%w: %s", ErrKeyNotFound, joinKey(path))
+			}
+			delete(table, key)
+			return nil
+		})
+	}
+
+	return fmt.Errorf("%w: %s", ErrKeyNotFound, joinKey(path))
+}
+
+// sectionRange returns the items of the header at i: its body up to the next
+// header, and the comments right above it. The comments right above the
+// next header belong to that one.
+func (d *Document) sectionRange(i int) (start, end int) {
+	end = i + 1
+	for end < len(d.items) && d.items[end].kind != itemTable {
+		end++
+	}
+	start = i
+	for start > 0 && d.items[start-1].isComment() {
+		start--
+	}
+	for end < len(d.items) && end > i+1 && d.items[end-1].isComment() {
+		end--
+	}
+	return start, end
+}
+
+// inlineOwner finds the key/value item whose value is an inline table
+// containing path.
+func (d *Document) inlineOwner(path []string) *docItem {
+	for _, item := range d.items {
+		if item.kind == itemKeyValue && !item.isArray && len(item.path) < len(path) && equalPath(item.path, path[:len(item.path)]) {
+			return item
+		}
+	}
+	return nil
+}
+
+func (d *Document) editInline(item *docItem, rest []string, edit func(map[string]interface{}, string) error) error {
+	value, err := newParser(item.raw[item.valueStart:item.valueEnd]).parseValue()
+	if err != nil {
+		return err
+	}
+
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot use %s as table, it's already defined as a value", joinKey(item.path))
+	}
+	parent, err := descend(table, rest[:len(rest)-1])
+	if err != nil {
+		return err
+	}
+	if err := edit(parent, rest[len(rest)-1]); err != nil {
+		return err
+	}
+
+	literal, err := New().appendValue(nil, reflect.ValueOf(table))
+	if err != nil {
+		return err
+	}
+	item.setValue(string(literal))
+	return nil
+}
+
+// section returns the index of the [table] header that owns path, or -1
+// for the root table.
+func (d *Document) section(path []string) (int, error) {
+	section := -1
+	for i, item := range d.items {
+		if item.kind != itemTable || len(item.path) > len(path) || !equalPath(item.path, path[:len(item.path)]) {
+			continue
+		}
+		if item.isArray {
+			return 0, fmt.Errorf("cannot edit %s inside array of tables %s", joinKey(path), joinKey(item.path))
+		}
+		if len(item.path) == len(path) {
+			return 0, fmt.Errorf("cannot set %s, it's already defined as a table", joinKey(path))
+		}
+		if section < 0 || len(item.path) > len(d.items[section].path) {
+			section = i
+		}
+	}
+	return section, nil
+}
+
+// insertIndex returns where a new key of a section goes: after its last
+// key, or right after the header when it has none.
+func (d *Document) insertIndex(section int) int {
+	index := section + 1
+	i := section + 1
+	for ; i < len(d.items) && d.items[i].kind != itemTable; i++ {
+		if d.items[i].kind == itemKeyValue {
+			index = i + 1
+		}
+	}
+
+	// A root table without keys: place them before the first header,
+	// keeping the blank lines that separate it.
+	if section < 0 && index == 0 {
+		index = i
+		for index > 0 && strings.TrimSpace(d.items[index-1].raw) == "" {
+			index--
+		}
+	}
+	return index
+}
+
+func (d *Document) insert(index int, item *docItem) {
+	if index > 0 && !strings.HasSuffix(d.items[index-1].raw, "\n") {
+		d.items[index-1].raw += "\n"
+	}
+	d.items = append(d.items, nil)
+	copy(d.items[index+1:], d.items[index:])
+	d.items[index] = item
+}
+
+func parseDottedKey(key string) ([]string, error) {
+	p := newParser(key)
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.typ != tokenEOF {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	return path, nil
+}
+
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(path) >= len(prefix) && equalPath(path[:len(prefix)], prefix)
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
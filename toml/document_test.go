@@ -0,0 +1,242 @@
+package toml
+
+import (
+	"errors"
+	"testing"
+)
+
+const documentInput = `# Конфигурация сервиса
+title = "demo"   # название
+
+[server]
+host = "localhost" # адрес
+ports = [
+  8000,
+  8001,
+]
+tls = { enabled = false }
+
+# база данных
+[database]
+user = 'admin'
+
+[[replicas]]
+host = "r1"
+`
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, input := range []string{documentInput, "a = 1", "", "# only comment", "\n\n[t]\n"} {
+		doc, err := ParseDocument([]byte(input))
+		if err != nil {
+			t.Fatalf("ParseDocument(%q) error = %v", input, err)
+		}
+		if got := doc.String(); got != input {
+			t.Errorf("String() = %q, want %q", got, input)
+		}
+	}
+
+	if _, err := ParseDocument([]byte("key = ")); err == nil {
+		t.Error("ParseDocument() с неверным TOML должен возвращать ошибку")
+	}
+}
+
+func TestDocumentGet(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentInput))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"title", "demo"},
+		{"server.host", "localhost"},
+		{"server.ports.1", int64(8001)},
+		{"server.tls.enabled", false},
+		{"replicas.0.host", "r1"},
+	}
+	for _, tt := range tests {
+		got, ok := doc.Get(tt.key)
+		if !ok || got != tt.want {
+			t.Errorf("Get(%q) = %v, %v, want %v", tt.key, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := doc.Get("server.missing"); ok {
+		t.Error("Get() для отсутствующего ключа должен возвращать false")
+	}
+}
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentInput))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+
+	edits := []error{
+		doc.Set("server.host", "example.com"),
+		doc.Set("server.timeout", 30),
+		doc.Set("server.tls.enabled", true),
+		doc.Set("database.password", "secret"),
+		doc.Set("debug", true),
+		doc.Delete("database.user"),
+	}
+	for i, err := range edits {
+		if err != nil {
+			t.Fatalf("edit %d error = %v", i, err)
+		}
+	}
+
+	want := `# Конфигурация сервиса
+title = "demo"   # название
+debug = true
+
+[server]
+host = "example.com" # адрес
+ports = [
+  8000,
+  8001,
+]
+tls = { enabled = true }
+timeout = 30
+
+# база данных
+[database]
+password = "secret"
+
+[[replicas]]
+host = "r1"
+`
+	if got := doc.String(); got != want {
+		t.Errorf("String() = %s\nwant %s", got, want)
+	}
+
+	if err := doc.Delete("database"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := doc.Get("database.password"); ok {
+		t.Error("Delete() таблицы должен удалять её ключи")
+	}
+
+	if err := doc.Delete("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Delete() error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := doc.Set("replicas.port", 1); err == nil {
+		t.Error("Set() внутри массива таблиц должен возвращать ошибку")
+	}
+	if err := doc.Set("server", 1); err == nil {
+		t.Error("Set() поверх таблицы должен возвращать ошибку")
+	}
+}
+
+func TestDocumentDecode(t *testing.T) {
+	doc, err := ParseDocument([]byte("[server]\nhost = \"a\"\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if err := doc.Set("server.port", 8080); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	var config struct {
+		Server struct {
+			Host string `toml:"host"`
+			Port int    `toml:"port"`
+		} `toml:"server"`
+	}
+	if err := doc.Decode(&config); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if config.Server.Host != "a" || config.Server.Port != 8080 {
+		t.Errorf("Decode() = %+v", config)
+	}
+}
+
+func TestDocumentDeleteKeepsNextTableComments(t *testing.T) {
+	doc, err := ParseDocument([]byte(`top = 1
+
+# comment for a
+[a]
+x = 1
+# a trailing note
+
+# comment for b
+[b]
+y = 2
+`))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if err := doc.Delete("a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want := `top = 1
+
+# comment for b
+[b]
+y = 2
+`
+	if got := doc.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDocumentDeleteNestedTables(t *testing.T) {
+	doc, err := ParseDocument([]byte(`top = 1
+
+# comment for a
+[a]
+x = 1
+
+[a.b]
+z = 2
+
+# items
+[[a.list]]
+n = 1
+
+[ab]
+k = 1
+
+# comment for c
+[c]
+y = 2
+`))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if err := doc.Delete("a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want := `top = 1
+
+[ab]
+k = 1
+
+# comment for c
+[c]
+y = 2
+`
+	if got := doc.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if v, ok := doc.Get("a"); ok {
+		t.Errorf("Get(a) = %v после Delete()", v)
+	}
+
+	// Таблица, заданная только подтаблицами и ключами с точкой.
+	doc, err = ParseDocument([]byte("a.c = 1\n\n[a.b]\nz = 2\n\n[d]\ne = 3\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if err := doc.Delete("a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if v, ok := doc.Get("a"); ok {
+		t.Errorf("Get(a) = %v после Delete()", v)
+	}
+	if v, ok := doc.Get("d.e"); !ok || v != int64(3) {
+		t.Errorf("Get(d.e) = %v, %v", v, ok)
+	}
+}
@@ -0,0 +1,218 @@
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// TypeError reports a value that cannot be stored in the target type,
+// such as a negative number for an unsigned field or a string for a bool.
+// Field is the dotted path of the value in the document, e.g.
+// "servers.0.port", and is empty for the top-level value. Line and Column
+// locate the value's key, and are only set with CollectErrors.
+type TypeError struct {
+	Value  string
+	Type   reflect.Type
+	Field  string
+	Line   int
+	Column int
+}
+
+func (e *TypeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("cannot unmarshal %s into value of type %v", e.Value, e.Type)
+	}
+	return fmt.Sprintf("cannot unmarshal %s into field %s of type %v", e.Value, e.Field, e.Type)
+}
+
+// MissingFieldError reports a struct field tagged required whose key is
+// absent from a table. Field is the dotted path of the field. Line and
+// Column locate the table's header or key, and are only set with
+// CollectErrors.
+type MissingFieldError struct {
+	Field  string
+	Line   int
+	Column int
+}
+
+func (e *MissingFieldError) Error() string {
+	return "missing required field " + e.Field
+}
+
+// UnknownFieldError reports, in strict mode, a key that matches no field of
+// the target struct. Field is the dotted path of the key. Line and Column
+// are only set with CollectErrors.
+type UnknownFieldError struct {
+	Field  string
+	Line   int
+	Column int
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "unknown field " + e.Field
+}
+
+// AmbiguousFieldError reports, in strict mode, a key that matches several
+// struct fields ignoring case, or a key for a field that another key of
+// the same table, differing in case or using an alias, also sets. Field
+// is the dotted path of the key. Line and Column are only set with
+// CollectErrors.
+type AmbiguousFieldError struct {
+	Field  string
+	Line   int
+	Column int
+}
+
+func (e *AmbiguousFieldError) Error() string {
+	return "ambiguous field " + e.Field
+}
+
+// MultiError lists the field errors found by Unmarshal with CollectErrors,
+// in the order they were found: *TypeError, *MissingFieldError,
+// *UnknownFieldError and *AmbiguousFieldError values, each with its path
+// and position.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// ErrLimitExceeded is matched by errors.Is for every *LimitError, so that
+// handlers can tell input rejected by Limits from malformed input.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports input that exceeds one of the Limits or the maximum
+// depth. Limit is "input size", "depth", "string length", "elements" or
+// "allocations", Max the value it exceeded, and Line and Column where the
+// parser stopped.
+type LimitError struct {
+	Limit  string
+	Max    int
+	Line   int
+	Column int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s limit of %d exceeded", e.Line, e.Column, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// CycleError reports a value that Marshal reaches again while encoding
+// it, through a pointer, map or slice that refers back to it. Field is the
+// dotted path at which the value repeats.
+type CycleError struct {
+	Type  reflect.Type
+	Field string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("encountered a cycle via %v at field %s", e.Type, e.Field)
+}
+
+// DepthError reports a value that Marshal finds nested more deeply than
+// the maximum depth. Field is the dotted path of the value.
+type DepthError struct {
+	Depth int
+	Field string
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("exceeded maximum depth of %d at field %s", e.Depth, e.Field)
+}
+
+func newTypeError(value interface{}, t reflect.Type) *TypeError {
+	switch value.(type) {
+	case int64, float64:
+		return &TypeError{Value: fmt.Sprintf("%s %v", kindOf(value), value), Type: t}
+	}
+	return &TypeError{Value: kindOf(value), Type: t}
+}
+
+var (
+	stringType  = reflect.TypeOf("")
+	boolType    = reflect.TypeOf(false)
+	float32Type = reflect.TypeOf(float32(0))
+	float64Type = reflect.TypeOf(float64(0))
+	tableType   = reflect.TypeOf(map[string]interface{}(nil))
+	arrayType   = reflect.TypeOf([]interface{}(nil))
+
+	// Indexed by size in bytes.
+	intTypes  = [...]reflect.Type{1: reflect.TypeOf(int8(0)), 2: reflect.TypeOf(int16(0)), 4: reflect.TypeOf(int32(0)), 8: reflect.TypeOf(int64(0))}
+	uintTypes = [...]reflect.Type{1: reflect.TypeOf(uint8(0)), 2: reflect.TypeOf(uint16(0)), 4: reflect.TypeOf(uint32(0)), 8: reflect.TypeOf(uint64(0))}
+)
+
+// decodeInt, decodeUint and decodeFloat convert a parsed number for a
+// target of type t, rejecting fractions for integers and values that t
+// cannot hold.
+func decodeInt(value interface{}, t reflect.Type) (int64, error) {
+	var i int64
+	switch v := value.(type) {
+	case int64:
+		i = v
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, newTypeError(value, t)
+		}
+		i = int64(v)
+	default:
+		return 0, newTypeError(value, t)
+	}
+	if bits := t.Bits(); bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		return 0, newTypeError(value, t)
+	}
+	return i, nil
+}
+
+func decodeUint(value interface{}, t reflect.Type) (uint64, error) {
+	var u uint64
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return 0, newTypeError(value, t)
+		}
+		u = uint64(v)
+	case float64:
+		if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+			return 0, newTypeError(value, t)
+		}
+		u = uint64(v)
+	default:
+		return 0, newTypeError(value, t)
+	}
+	if bits := t.Bits(); bits < 64 && u >= 1<<bits {
+		return 0, newTypeError(value, t)
+	}
+	return u, nil
+}
+
+func decodeFloat(value interface{}, t reflect.Type) (float64, error) {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case int64:
+		f = float64(v)
+	default:
+		return 0, newTypeError(value, t)
+	}
+	if t.Bits() == 32 && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+		return 0, newTypeError(value, t)
+	}
+	return f, nil
+}
@@ -0,0 +1,355 @@
+package toml
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field as a table key. index is the path to the
+// field through embedded structs, as for FieldByIndex.
+type field struct {
+	name   string
+	index  []int
+	tagged bool
+
+	// aliases are other keys accepted for the field on decode, from the
+	// alias tag.
+	aliases []string
+
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool // ",string": a number or bool written as a string
+	required  bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields lists the fields of t in declaration order, promoting the
+// fields of embedded structs and of fields tagged inline by the same rules
+// as the json package: the shallowest field wins a name, then a tagged
+// one, and names that are still ambiguous are dropped.
+func typeFields(t reflect.Type) []field {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []field
+	var current []embedded
+	next := []embedded{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	// Walk breadth first, one level of embedding at a time.
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// Unexported embedded structs still promote their exported
+				// fields; other unexported fields are skipped.
+				if !sf.IsExported() && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+					continue
+				}
+
+				tag := sf.Tag.Get("toml")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				index := append(slices.Clip(e.index), i)
+
+				if ft.Kind() == reflect.Struct && ft != timeType && (sf.Anonymous && name == "" || opts.Contains("inline")) {
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, embedded{typ: ft, index: index})
+					}
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				tagged := name != ""
+				if name == "" {
+					name = sf.Name
+				}
+				fields = append(fields, newField(name, index, tagged, sf, opts))
+				if count[e.typ] > 1 {
+					// The same struct is embedded twice at this depth, so
+					// its fields conflict with each other. A duplicate entry
+					// makes the check below drop them.
+					fields = append(fields, fields[len(fields)-1])
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b field) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.index), len(b.index)); c != 0 {
+			return c
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if f, ok := dominantField(fields[i:j]); ok {
+			out = append(out, f)
+		}
+		i = j
+	}
+
+	slices.SortFunc(out, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return out
+}
+
+// dominantField picks the field that owns a name from fields sorted by
+// depth and tagging, reporting false if two of them tie.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func newField(name string, index []int, tagged bool, sf reflect.StructField, opts tagOptions) field {
+	f := field{
+		name:      name,
+		index:     index,
+		tagged:    tagged,
+		omitEmpty: opts.Contains("omitempty"),
+		omitZero:  opts.Contains("omitzero"),
+		required:  opts.Contains("required"),
+	}
+	if alias := sf.Tag.Get("alias"); alias != "" {
+		f.aliases = strings.Split(alias, ",")
+	}
+	if opts.Contains("string") {
+		switch sf.Type.Kind() {
+		case reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			f.quoted = true
+		}
+	}
+	return f
+}
+
+// lookupField returns the index of the field for a table key, or -1.
+// Names and aliases are matched exactly first, then ignoring case, as
+// encoding/json does. ambiguous reports that several fields match key
+// ignoring case; the first of them is returned.
+func lookupField(fields []field, key string) (match int, ambiguous bool) {
+	for i := range fields {
+		if fields[i].name == key {
+			return i, false
+		}
+	}
+	for i := range fields {
+		for _, alias := range fields[i].aliases {
+			if alias == key {
+				return i, false
+			}
+		}
+	}
+
+	match = -1
+	for i := range fields {
+		if !fields[i].matchFold(key) {
+			continue
+		}
+		if match >= 0 {
+			return match, true
+		}
+		match = i
+	}
+	return match, false
+}
+
+func (f *field) matchFold(key string) bool {
+	if strings.EqualFold(f.name, key) {
+		return true
+	}
+	for _, alias := range f.aliases {
+		if strings.EqualFold(alias, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchKeys returns obj with the keys that match a field only by alias or
+// ignoring case renamed to the field's name. When several keys match one
+// field, the exact name wins, then the first key in sorted order. In
+// strict mode that, or a key matching several fields, is an
+// *AmbiguousFieldError.
+func matchKeys(obj map[string]interface{}, fields []field, strict bool) (map[string]interface{}, error) {
+	exact := true
+	for key := range obj {
+		if i, ambiguous := lookupField(fields, key); ambiguous || i >= 0 && fields[i].name != key {
+			exact = false
+			break
+		}
+	}
+	if exact {
+		return obj, nil
+	}
+
+	matched := make(map[string]interface{}, len(obj))
+	from := make(map[string]string) // field name -> key whose value it holds
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		i, ambiguous := lookupField(fields, key)
+		if i < 0 {
+			matched[key] = obj[key]
+			continue
+		}
+		name := fields[i].name
+		_, taken := from[name]
+		if strict && (ambiguous || taken) {
+			return nil, &AmbiguousFieldError{Field: key}
+		}
+		if taken && key != name {
+			continue
+		}
+		matched[name] = obj[key]
+		from[name] = key
+	}
+	return matched, nil
+}
+
+// tagOptions is the part of a struct tag after the name.
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, tagOptions(opts)
+}
+
+func (o tagOptions) Contains(option string) bool {
+	for s := string(o); s != ""; {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldByIndex returns the field at index, or false if the path goes
+// through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns the field at index for decoding into,
+// allocating nil embedded pointers on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// omitted reports whether a field tagged omitempty or omitzero is left out
+// of the output for value v. Empty means false, 0, a nil pointer or
+// interface, and an empty string, slice, array or map. Zero uses the
+// value's IsZero method when it has one.
+func (f *field) omitted(v reflect.Value) bool {
+	if f.omitEmpty {
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			if v.Len() == 0 {
+				return true
+			}
+		case reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64,
+			reflect.Ptr, reflect.Interface:
+			if v.IsZero() {
+				return true
+			}
+		}
+	}
+	return f.omitZero && isZero(v)
+}
+
+type zeroer interface {
+	IsZero() bool
+}
+
+var zeroerType = reflect.TypeOf((*zeroer)(nil)).Elem()
+
+func isZero(v reflect.Value) bool {
+	switch {
+	case (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil():
+		return true
+	case v.Type().Implements(zeroerType):
+		return v.Interface().(zeroer).IsZero()
+	case v.CanAddr() && reflect.PointerTo(v.Type()).Implements(zeroerType):
+		return v.Addr().Interface().(zeroer).IsZero()
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,205 @@
+package toml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FormatOptions control Format. The zero value keeps keys in their
+// original order.
+type FormatOptions struct {
+	SortKeys bool
+}
+
+// Format rewrites a document in canonical style: no indentation, single
+// spaces around "=", basic strings, bare keys where possible, one blank
+// line before each table and none repeated. Comments are kept, and with
+// SortKeys they move together with the key below them. Multi-line values
+// are left as written.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	var table []string
+	var body []*docItem
+	for _, item := range doc.items {
+		if item.kind != itemTable {
+			body = append(body, item)
+			continue
+		}
+
+		lines = appendBody(lines, body, table, opts)
+		body = nil
+		table = item.path
+
+		// Separate the header, with the comments right above it, from
+		// whatever precedes it.
+		i := len(lines)
+		for i > 0 && strings.HasPrefix(lines[i-1], "#") {
+			i--
+		}
+		if i > 0 && lines[i-1] != "" {
+			lines = append(lines[:i], append([]string{""}, lines[i:]...)...)
+		}
+
+		header := "[" + joinKey(item.path) + "]"
+		if item.isArray {
+			header = "[" + header + "]"
+		}
+		lines = append(lines, header+trailingComment(item))
+	}
+	lines = appendBody(lines, body, table, opts)
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// entry is a key/value line together with the comment lines above it.
+type entry struct {
+	comments []string
+	key      string
+	line     string
+}
+
+// appendBody formats the keys of one table.
+func appendBody(lines []string, body []*docItem, table []string, opts FormatOptions) []string {
+	var entries []entry
+	var pending []string
+	for _, item := range body {
+		switch item.kind {
+		case itemTrivia:
+			text := strings.TrimSpace(item.raw)
+			if text == "" && opts.SortKeys {
+				continue
+			}
+			pending = append(pending, text)
+		case itemKeyValue:
+			key := joinKey(item.path[len(table):])
+			value := normalizeValue(item.raw[item.valueStart:item.valueEnd])
+			entries = append(entries, entry{
+				comments: pending,
+				key:      key,
+				line:     key + " = " + value + trailingComment(item),
+			})
+			pending = nil
+		}
+	}
+
+	if opts.SortKeys {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	}
+
+	for _, e := range append(entries, entry{comments: pending}) {
+		for _, comment := range e.comments {
+			// Collapse runs of blank lines, and drop them at the start of
+			// the document and right after a header.
+			if comment == "" && (len(lines) == 0 || lines[len(lines)-1] == "" || strings.HasPrefix(lines[len(lines)-1], "[")) {
+				continue
+			}
+			lines = append(lines, comment)
+		}
+		if e.line != "" {
+			lines = append(lines, e.line)
+		}
+	}
+	return lines
+}
+
+func trailingComment(item *docItem) string {
+	rest := strings.TrimSpace(item.raw[item.valueEnd:])
+	if strings.HasPrefix(rest, "#") {
+		return " " + rest
+	}
+	return ""
+}
+
+// normalizeValue rewrites a single-line value with canonical quoting and
+// spacing. Multi-line values are kept as written, as they may contain
+// comments that the lexer skips.
+func normalizeValue(text string) string {
+	if strings.Contains(text, "\n") {
+		return text
+	}
+	p := newParser(text)
+	buf, err := p.appendCanonical(nil)
+	if err != nil || p.token.typ != tokenEOF {
+		return text
+	}
+	return string(buf)
+}
+
+func (p *parser) appendCanonical(buf []byte) ([]byte, error) {
+	var err error
+	switch p.token.typ {
+	case tokenString:
+		buf = appendString(buf, p.token.value)
+	case tokenNumber, tokenDate, tokenTrue, tokenFalse:
+		// Numbers are copied from the input, keeping underscores.
+		buf = append(buf, p.lexer.input[p.token.pos:p.lexer.pos]...)
+	case tokenLeftBracket:
+		p.next()
+		buf = append(buf, '[')
+		for i := 0; p.token.typ != tokenRightBracket; i++ {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			if buf, err = p.appendCanonical(buf); err != nil {
+				return nil, err
+			}
+			if p.token.typ == tokenComma {
+				p.next()
+			} else if p.token.typ != tokenRightBracket {
+				return nil, fmt.Errorf("expected comma or ], got %v", p.token)
+			}
+		}
+		buf = append(buf, ']')
+	case tokenLeftBrace:
+		p.next()
+		if p.token.typ == tokenRightBrace {
+			buf = append(buf, "{}"...)
+			break
+		}
+		buf = append(buf, "{ "...)
+		for i := 0; ; i++ {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			path, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if p.token.typ != tokenEquals {
+				return nil, fmt.Errorf("expected =, got %v", p.token)
+			}
+			p.next()
+			buf = append(buf, joinKey(path)...)
+			buf = append(buf, " = "...)
+			if buf, err = p.appendCanonical(buf); err != nil {
+				return nil, err
+			}
+			if p.token.typ == tokenRightBrace {
+				break
+			}
+			if p.token.typ != tokenComma {
+				return nil, fmt.Errorf("expected comma or }, got %v", p.token)
+			}
+			p.next()
+		}
+		buf = append(buf, " }"...)
+	default:
+		return nil, fmt.Errorf("unexpected token: %v", p.token)
+	}
+	p.next()
+	return buf, nil
+}
@@ -0,0 +1,131 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const unformatted = `  # настройки
+title   =   'hello'   # заголовок
+zeta=1_000
+alpha = [ 1,2 , "x" ]
+
+
+
+[server]
+
+  port= 8080
+  inline = {a=1,  "b c" = 'd'}
+# пользователи
+[[users]]
+name='a'
+ports = [
+  1, # первый
+  2,
+]
+`
+
+func TestFormat(t *testing.T) {
+	want := `# настройки
+title = "hello" # заголовок
+zeta = 1_000
+alpha = [1, 2, "x"]
+
+[server]
+port = 8080
+inline = { a = 1, "b c" = "d" }
+
+# пользователи
+[[users]]
+name = "a"
+ports = [
+  1, # первый
+  2,
+]
+`
+	got, err := Format([]byte(unformatted), FormatOptions{})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+
+	again, err := Format(got, FormatOptions{})
+	if err != nil || string(again) != string(got) {
+		t.Errorf("Format() не идемпотентен:\n%s", again)
+	}
+
+	sorted, err := Format([]byte("b = 1\n# про a\na = 2\n\n[t]\nz = 1\ny = 2\n"), FormatOptions{SortKeys: true})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if want := "# про a\na = 2\nb = 1\n\n[t]\ny = 2\nz = 1\n"; string(sorted) != want {
+		t.Errorf("Format() с сортировкой =\n%s\nwant\n%s", sorted, want)
+	}
+
+	if _, err := Format([]byte("a = \n"), FormatOptions{}); err == nil {
+		t.Error("Format() с неверным TOML должен возвращать ошибку")
+	}
+}
+
+func TestLint(t *testing.T) {
+	input := `a = 1
+list = [1, "x"]
+a = 2
+f = 1.5e-400
+g = 0.1000000000000000000001
+
+[t]
+[t]
+
+[[arr]]
+k = 1
+[[arr]]
+k = 2
+`
+	issues, err := Lint([]byte(input), LintOptions{})
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	want := []Issue{
+		{2, 8, `array "list" mixes integer and string`},
+		{3, 1, `duplicate key "a"`},
+		{4, 5, "float 1.5e-400 underflows to zero"},
+		{5, 5, "float 0.1000000000000000000001 has more digits than float64 can hold"},
+		{8, 1, `duplicate table "t"`},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Lint() = %v, want %v", issues, want)
+	}
+
+	schema := map[string]interface{}{
+		"name":    "",
+		"servers": []interface{}{map[string]interface{}{"host": ""}},
+		"labels":  map[string]interface{}{"*": ""},
+		"point":   map[string]interface{}{"x": 0},
+	}
+	input = `name = "x"
+labels = { any = "1" }
+point = { x = 1, y = 2 }
+
+[[servers]]
+host = "a"
+hostname = "b"
+
+[extra]
+deep = 1
+`
+	issues, err = Lint([]byte(input), LintOptions{Schema: schema})
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	want = []Issue{
+		{3, 9, `key "point.y" is not in the schema`},
+		{7, 1, `key "servers.hostname" is not in the schema`},
+		{9, 1, `key "extra" is not in the schema`},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Lint() со схемой = %v, want %v", issues, want)
+	}
+}
@@ -0,0 +1,232 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Issue is a problem found by Lint. Line and Column are 1-based and point
+// at the key or value it refers to.
+type Issue struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// LintOptions configure Lint. Schema, when not nil, is a decoded document
+// listing the allowed keys: tables give the keys allowed at their level,
+// a "*" key allows any key, and the first element of an array describes
+// every element.
+type LintOptions struct {
+	Schema interface{}
+}
+
+// Lint reports duplicate keys and tables, arrays mixing value types,
+// float literals that float64 cannot represent and, with a schema,
+// unknown keys. Syntax errors are returned as *SyntaxError.
+func Lint(data []byte, opts LintOptions) ([]Issue, error) {
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{input: string(data), schema: opts.Schema, unknown: make(map[string]bool)}
+	seen := make(map[string]bool)
+	offset := 0
+	for _, item := range doc.items {
+		keyOffset := offset + len(item.raw) - len(strings.TrimLeft(item.raw, " \t"))
+		key := strings.Join(item.path, "\x00")
+
+		switch item.kind {
+		case itemTable:
+			if item.isArray {
+				// Every [[header]] starts a new element with its own keys.
+				for k := range seen {
+					if strings.HasPrefix(k, key+"\x00") {
+						delete(seen, k)
+					}
+				}
+			} else if seen[key] {
+				l.report(keyOffset, "duplicate table %q", strings.Join(item.path, "."))
+			}
+			seen[key] = true
+			l.checkSchema(keyOffset, item.path)
+
+		case itemKeyValue:
+			if seen[key] {
+				l.report(keyOffset, "duplicate key %q", strings.Join(item.path, "."))
+			}
+			seen[key] = true
+			schema, known := l.checkSchema(keyOffset, item.path)
+			l.checkValue(offset+item.valueStart, item.raw[item.valueStart:item.valueEnd], item.path, schema, known)
+		}
+
+		offset += len(item.raw)
+	}
+	return l.issues, nil
+}
+
+type linter struct {
+	input   string
+	schema  interface{}
+	unknown map[string]bool // paths already reported as not in the schema
+	issues  []Issue
+}
+
+func (l *linter) report(offset int, format string, args ...any) {
+	line, col := position(l.input, offset)
+	l.issues = append(l.issues, Issue{Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkSchema reports path if the schema does not allow it, once for the
+// outermost unknown key, and returns the schema describing its value.
+func (l *linter) checkSchema(offset int, path []string) (interface{}, bool) {
+	if l.schema == nil {
+		return nil, false
+	}
+
+	node := l.schema
+	for i, key := range path {
+		child, ok := schemaKey(schemaElem(node), key)
+		if !ok {
+			name := strings.Join(path[:i+1], ".")
+			if !l.unknown[name] {
+				l.unknown[name] = true
+				l.report(offset, "key %q is not in the schema", name)
+			}
+			return nil, false
+		}
+		node = child
+	}
+	return node, true
+}
+
+func (l *linter) checkValue(offset int, text string, path []string, schema interface{}, known bool) {
+	value, err := newParser(text).parseValue()
+	if err != nil {
+		return
+	}
+	l.checkTree(offset, value, path, schema, known)
+
+	lexer := newLexer(text)
+	for tok := lexer.next(); tok.typ != tokenEOF && tok.typ != tokenError; tok = lexer.next() {
+		if tok.typ == tokenNumber {
+			if msg := suspiciousFloat(strings.ReplaceAll(tok.value, "_", "")); msg != "" {
+				l.report(offset+tok.pos, "%s", msg)
+			}
+		}
+	}
+}
+
+// checkTree checks arrays and inline tables inside a parsed value.
+// Issues are reported at the start of the value.
+func (l *linter) checkTree(offset int, value interface{}, path []string, schema interface{}, known bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := v[key]
+			keyPath := append(path[:len(path):len(path)], key)
+			childSchema, ok := schemaKey(schema, key)
+			if known && !ok {
+				l.report(offset, "key %q is not in the schema", strings.Join(keyPath, "."))
+			}
+			l.checkTree(offset, child, keyPath, childSchema, known && ok)
+		}
+	case []interface{}:
+		kinds := make(map[string]bool)
+		var order []string
+		for i, elem := range v {
+			kind := kindOf(elem)
+			if !kinds[kind] {
+				kinds[kind] = true
+				order = append(order, kind)
+			}
+			l.checkTree(offset, elem, append(path[:len(path):len(path)], strconv.Itoa(i)), schemaElem(schema), known)
+		}
+		if len(order) > 1 {
+			l.report(offset, "array %q mixes %s", strings.Join(path, "."), listKinds(order))
+		}
+	}
+}
+
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "datetime"
+	case []interface{}:
+		return "array"
+	default:
+		return "table"
+	}
+}
+
+func schemaKey(schema interface{}, key string) (interface{}, bool) {
+	table, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if child, ok := table[key]; ok {
+		return child, true
+	}
+	child, ok := table["*"]
+	return child, ok
+}
+
+func schemaElem(schema interface{}) interface{} {
+	if arr, ok := schema.([]interface{}); ok && len(arr) > 0 {
+		return arr[0]
+	}
+	return schema
+}
+
+// suspiciousFloat describes why a float literal does not survive the trip
+// through float64, or returns "" if it does.
+func suspiciousFloat(lit string) string {
+	if !strings.ContainsAny(lit, ".eE") {
+		return ""
+	}
+
+	f, err := strconv.ParseFloat(lit, 64)
+	if math.IsInf(f, 0) {
+		return fmt.Sprintf("float %s overflows float64", lit)
+	}
+	if err != nil {
+		return ""
+	}
+
+	mantissa := strings.TrimLeft(lit, "+-")
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		mantissa = mantissa[:i]
+	}
+	digits := strings.Trim(strings.Replace(mantissa, ".", "", 1), "0")
+	if f == 0 && digits != "" {
+		return fmt.Sprintf("float %s underflows to zero", lit)
+	}
+	if len(digits) > 17 {
+		return fmt.Sprintf("float %s has more digits than float64 can hold", lit)
+	}
+	return ""
+}
+
+// listKinds joins kinds as "a, b and c".
+func listKinds(kinds []string) string {
+	last := len(kinds) - 1
+	return strings.Join(kinds[:last], ", ") + " and " + kinds[last]
+}
@@ -0,0 +1,422 @@
+package toml
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Marshaler is implemented by types that encode themselves as a TOML
+// document. When such a value is nested, its document becomes the body
+// of the table it is stored under.
+type Marshaler interface {
+	MarshalTOML() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves from a
+// parsed TOML value: a map[string]interface{} for tables, []interface{}
+// for arrays, or a string, int64, float64, bool or time.Time.
+type Unmarshaler interface {
+	UnmarshalTOML(value interface{}) error
+}
+
+// Appender is implemented by code generated by serializer-gen. It writes
+// the body of the table at path and is preferred over Marshaler.
+type Appender interface {
+	AppendTOML(buf []byte, path []string) ([]byte, error)
+}
+
+// Encoder is implemented by code generated by serializer-gen. It is
+// preferred over Appender since the Writer carries the options of the
+// serializer in use and the nesting of the value being encoded.
+type Encoder interface {
+	EncodeTOML(w *Writer, buf []byte, path []string) ([]byte, error)
+}
+
+// Decoder is implemented by code generated by serializer-gen. It is
+// preferred over Unmarshaler since the Reader carries the options of the
+// serializer in use and the errors collected so far.
+type Decoder interface {
+	DecodeTOML(r *Reader, value interface{}) error
+}
+
+var (
+	encoderType   = reflect.TypeOf((*Encoder)(nil)).Elem()
+	appenderType  = reflect.TypeOf((*Appender)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	marshalerCache sync.Map // map[reflect.Type]bool
+)
+
+// tableMarshaler returns v as an Encoder, Appender or Marshaler, or nil if
+// it implements none of them.
+func tableMarshaler(v reflect.Value) any {
+	if !v.IsValid() || v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr && v.IsNil() || !v.CanInterface() {
+		return nil
+	}
+
+	t := v.Type()
+	if t.PkgPath() == "" && t.Kind() != reflect.Ptr {
+		return nil
+	}
+	implements, ok := marshalerCache.Load(t)
+	if !ok {
+		implements = t.Implements(encoderType) || t.Implements(appenderType) || t.Implements(marshalerType)
+		marshalerCache.Store(t, implements)
+	}
+	if !implements.(bool) {
+		return nil
+	}
+	return v.Interface()
+}
+
+func marshalTree(m Marshaler) (map[string]interface{}, error) {
+	data, err := m.MarshalTOML()
+	if err != nil {
+		return nil, err
+	}
+	return newParser(string(data)).parse()
+}
+
+// The functions below are used by code generated by serializer-gen and
+// keep it consistent with the reflective encoder and decoder.
+
+// AppendString appends s as a quoted TOML string.
+func AppendString(buf []byte, s string) []byte {
+	return appendString(buf, s)
+}
+
+// AppendKey appends key, quoting it if it is not a valid bare key.
+func AppendKey(buf []byte, key string) []byte {
+	return appendKey(buf, key)
+}
+
+// AppendFloat appends f formatted as the encoder formats floats of the
+// given bit size.
+func AppendFloat(buf []byte, f float64, bitSize int) ([]byte, error) {
+	return appendFloat(buf, f, bitSize)
+}
+
+// AppendHeader appends a [table] header for path, preceded by a blank
+// line unless buf is empty.
+func AppendHeader(buf []byte, path []string) []byte {
+	return appendHeader(buf, path, false)
+}
+
+// AppendKeyValue appends a "key = value" line for an inline value using
+// the reflective encoder. Nil values are skipped.
+func AppendKeyValue(buf []byte, key string, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if isNil(rv) {
+		return buf, nil
+	}
+	buf = appendKey(buf, key)
+	buf = append(buf, " = "...)
+	buf, err := New().appendValue(buf, indirect(rv))
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+// AppendTable appends v, a table or an array of tables, under path using
+// the reflective encoder.
+func AppendTable(buf []byte, path []string, v any) ([]byte, error) {
+	s := New()
+	rv := indirect(reflect.ValueOf(v))
+	if isArrayOfTables(rv) {
+		var err error
+		for i := 0; i < rv.Len(); i++ {
+			buf = appendHeader(buf, path, true)
+			if buf, err = s.appendTable(buf, indirect(rv.Index(i)), path); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	buf = appendHeader(buf, path, false)
+	return s.appendTable(buf, rv, path)
+}
+
+// Writer gives generated encoders the conversions of the reflective
+// encoder, with the options of the serializer in use.
+type Writer struct {
+	e *encodeState
+}
+
+// NewWriter returns a Writer with default options.
+func NewWriter() *Writer {
+	e := &encodeState{s: New()}
+	e.writer.e = e
+	return &e.writer
+}
+
+// Enter opens a table or array, failing past the maximum depth. After an
+// error the Writer is done with, so only successful paths call Leave.
+func (w *Writer) Enter() error {
+	return w.e.enter(reflect.Value{})
+}
+
+func (w *Writer) Leave() {
+	w.e.leave(reflect.Value{})
+}
+
+// KeyValue is AppendKeyValue with the options of the serializer.
+func (w *Writer) KeyValue(buf []byte, key string, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if isNil(rv) {
+		return buf, nil
+	}
+	buf = appendKey(buf, key)
+	buf = append(buf, " = "...)
+	buf, err := w.e.appendValue(buf, indirect(rv))
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+// Table is AppendTable with the options of the serializer.
+func (w *Writer) Table(buf []byte, path []string, v any) ([]byte, error) {
+	rv := indirect(reflect.ValueOf(v))
+	if isArrayOfTables(rv) {
+		var err error
+		for i := 0; i < rv.Len(); i++ {
+			buf = appendHeader(buf, path, true)
+			if buf, err = w.e.appendTable(buf, indirect(rv.Index(i)), path); err != nil {
+				return nil, WithField(err, strconv.Itoa(i))
+			}
+		}
+		return buf, nil
+	}
+	buf = appendHeader(buf, path, false)
+	return w.e.appendTable(buf, rv, path)
+}
+
+// IsTable reports whether v is encoded as a [table] section. Nil values
+// are not encoded at all.
+func IsTable(v any) bool {
+	rv := indirect(reflect.ValueOf(v))
+	return !isNil(rv) && isTable(rv)
+}
+
+// IsArrayOfTables reports whether v is encoded as [[array]] sections.
+func IsArrayOfTables(v any) bool {
+	return isArrayOfTables(indirect(reflect.ValueOf(v)))
+}
+
+func DecodeString(value interface{}) (string, error) {
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return "", newTypeError(value, stringType)
+}
+
+// DecodeInt accepts integers and floats that are whole numbers, such as
+// 1e3.
+func DecodeInt(value interface{}) (int64, error) {
+	return decodeInt(value, intTypes[8])
+}
+
+// DecodeIntBits is DecodeInt for integers of bitSize bits (8, 16, 32 or
+// 64), returning a *TypeError for values out of range.
+func DecodeIntBits(value interface{}, bitSize int) (int64, error) {
+	return decodeInt(value, intTypes[bitSize/8])
+}
+
+func DecodeUint(value interface{}) (uint64, error) {
+	return decodeUint(value, uintTypes[8])
+}
+
+// DecodeUintBits is DecodeUint for integers of bitSize bits (8, 16, 32 or
+// 64), returning a *TypeError for negative values and values out of range.
+func DecodeUintBits(value interface{}, bitSize int) (uint64, error) {
+	return decodeUint(value, uintTypes[bitSize/8])
+}
+
+func DecodeFloat(value interface{}) (float64, error) {
+	return decodeFloat(value, float64Type)
+}
+
+// DecodeFloatBits is DecodeFloat for floats of bitSize bits (32 or 64).
+func DecodeFloatBits(value interface{}, bitSize int) (float64, error) {
+	if bitSize == 32 {
+		return decodeFloat(value, float32Type)
+	}
+	return decodeFloat(value, float64Type)
+}
+
+func DecodeBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return false, newTypeError(value, boolType)
+}
+
+func DecodeTime(value interface{}) (time.Time, error) {
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, newTypeError(value, timeType)
+}
+
+func DecodeTable(value interface{}) (map[string]interface{}, error) {
+	if table, ok := value.(map[string]interface{}); ok {
+		return table, nil
+	}
+	return nil, newTypeError(value, tableType)
+}
+
+func DecodeArray(value interface{}) ([]interface{}, error) {
+	if arr, ok := value.([]interface{}); ok {
+		return arr, nil
+	}
+	return nil, newTypeError(value, arrayType)
+}
+
+// WithField adds key in front of the field path of a *TypeError,
+// *MissingFieldError, *UnknownFieldError, *AmbiguousFieldError,
+// *CycleError or *DepthError, as the error travels up from a nested value.
+// Other errors are returned as is.
+func WithField(err error, key string) error {
+	switch e := err.(type) {
+	case *TypeError:
+		e.Field = joinField(key, e.Field)
+	case *MissingFieldError:
+		e.Field = joinField(key, e.Field)
+	case *UnknownFieldError:
+		e.Field = joinField(key, e.Field)
+	case *AmbiguousFieldError:
+		e.Field = joinField(key, e.Field)
+	case *CycleError:
+		e.Field = joinField(key, e.Field)
+	case *DepthError:
+		e.Field = joinField(key, e.Field)
+	}
+	return err
+}
+
+// IsEmpty reports whether the value v points to is left out of the output
+// as a field tagged omitempty.
+func IsEmpty(v any) bool {
+	f := field{omitEmpty: true}
+	return f.omitted(reflect.ValueOf(v).Elem())
+}
+
+// IsZero reports whether the value v points to is left out of the output
+// as a field tagged omitzero.
+func IsZero(v any) bool {
+	return isZero(reflect.ValueOf(v).Elem())
+}
+
+// Keys lists the keys a generated decoder accepts for each field of a
+// struct, for Reader.Table.
+type Keys struct {
+	fields []field
+}
+
+// NewKeys returns the Keys for a struct. Each element of names holds a
+// field name followed by its aliases.
+func NewKeys(names ...[]string) *Keys {
+	k := &Keys{fields: make([]field, len(names))}
+	for i, n := range names {
+		k.fields[i] = field{name: n[0], aliases: n[1:]}
+	}
+	return k
+}
+
+func joinField(key, path string) string {
+	if path == "" {
+		return key
+	}
+	return key + "." + path
+}
+
+// Reader gives generated decoders the conversions of the reflective
+// decoder, with the options of the serializer in use. With CollectErrors
+// Field and Element record field errors and leave the value zero, as the
+// reflective decoder does.
+type Reader struct {
+	d *decodeState
+
+	// doc and fields are the table of the struct being decoded, before its
+	// keys were matched, and the fields of that struct, for locating the
+	// errors of its fields.
+	doc    map[string]interface{}
+	fields []field
+}
+
+// NewReader returns a Reader with default options.
+func NewReader() *Reader {
+	d := &decodeState{s: New()}
+	d.reader.d = d
+	return &d.reader
+}
+
+// Table returns value, which must be a table, with keys that stand for a
+// field by alias or by case renamed to the field name. In strict mode keys
+// matching no field or several are errors, or with CollectErrors are
+// recorded.
+func (r *Reader) Table(value interface{}, keys *Keys) (map[string]interface{}, error) {
+	doc, err := DecodeTable(value)
+	if err != nil {
+		return nil, err
+	}
+	table, err := r.d.matchTable(doc, keys.fields)
+	if err != nil {
+		return nil, err
+	}
+	r.doc, r.fields = doc, keys.fields
+	return table, nil
+}
+
+// Field runs decode, which stores the value of the field name into the
+// value v points to, adding name to the path of its errors.
+func (r *Reader) Field(name string, v any, decode func() error) error {
+	doc, fields := r.doc, r.fields
+	key := name
+	if r.d.positions != nil {
+		key = docKey(doc, fields, name)
+	}
+	mark := r.d.enter(key)
+	err := decode()
+	r.doc, r.fields = doc, fields
+	return r.d.leave(mark, name, err, reflect.ValueOf(v).Elem())
+}
+
+// Element is Field for the element at index i of an array.
+func (r *Reader) Element(i int, v any, decode func() error) error {
+	index := strconv.Itoa(i)
+	mark := r.d.enter(index)
+	return r.d.leave(mark, index, decode(), reflect.ValueOf(v).Elem())
+}
+
+// Slice tells a generated decoder how to store an array into a slice of
+// length n, as WithMerge selects: it keeps the first keep elements and
+// stores the array from index start on, overwriting kept elements before
+// appending.
+func (r *Reader) Slice(n int) (keep, start int) {
+	switch {
+	case !r.d.s.merge || r.d.s.sliceMerge == ReplaceSlices:
+		return 0, 0
+	case r.d.s.sliceMerge == AppendSlices:
+		return n, n
+	}
+	return n, 0
+}
+
+// Decode stores a parsed value into the value pointed to by v using the
+// reflective decoder.
+func (r *Reader) Decode(value interface{}, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer")
+	}
+	return r.d.setValue(rv.Elem(), value)
+}
@@ -0,0 +1,163 @@
+package toml
+
+type Option func(*TOMLSerializer)
+
+const defaultLineWidth = 80
+
+// defaultMaxDepth is deep enough for any reasonable document while keeping
+// the recursion well inside the goroutine stack limit.
+const defaultMaxDepth = 10000
+
+// WithIndent turns on pretty output: the "=" signs of each table are
+// aligned, and arrays that would make a line longer than the line width
+// are written one element per line, indented by indent.
+func WithIndent(indent string) Option {
+	return func(s *TOMLSerializer) {
+		s.indent = indent
+	}
+}
+
+// WithLineWidth sets the line width used by WithIndent. It defaults to 80
+// characters.
+func WithLineWidth(width int) Option {
+	return func(s *TOMLSerializer) {
+		s.lineWidth = width
+	}
+}
+
+// WithUnsortedKeys writes map keys in map iteration order, which saves
+// sorting them but makes the output differ between runs.
+func WithUnsortedKeys() Option {
+	return func(s *TOMLSerializer) {
+		s.unsortedKeys = true
+	}
+}
+
+// WithKeyComparator sorts map keys with cmp instead of byte order. cmp
+// returns a negative number when a goes before b, as strings.Compare does.
+func WithKeyComparator(cmp func(a, b string) int) Option {
+	return func(s *TOMLSerializer) {
+		s.compareKeys = cmp
+	}
+}
+
+// Strict makes Unmarshal reject keys that match no struct field, and keys
+// or tables defined twice. TOML forbids the latter, but by default the last
+// definition wins.
+func Strict() Option {
+	return func(s *TOMLSerializer) {
+		s.strict = true
+	}
+}
+
+// CollectErrors makes Unmarshal go on past values it cannot store, such
+// as a string for an int field, leaving them zero, and return a
+// *MultiError with every field error at the end. Syntax errors and limits
+// still stop decoding.
+func CollectErrors() Option {
+	return func(s *TOMLSerializer) {
+		s.collectErrors = true
+	}
+}
+
+// SliceMerge selects how WithMerge decodes an array into a slice that
+// already has elements.
+type SliceMerge int
+
+const (
+	// ReplaceSlices replaces the slice with the decoded elements.
+	ReplaceSlices SliceMerge = iota
+	// AppendSlices appends the decoded elements to the slice.
+	AppendSlices
+	// MergeSliceElements decodes each element into the existing element at
+	// the same index, appending those beyond the slice's length.
+	MergeSliceElements
+)
+
+// WithMerge makes Unmarshal overlay the document on the value it decodes
+// into, so that a struct filled with defaults can be updated from a
+// partial file. Maps, including map[string]interface{} values held in
+// interfaces, keep their entries and have decoded keys merged into the
+// existing values; slices are handled as selected by slices.
+func WithMerge(slices SliceMerge) Option {
+	return func(s *TOMLSerializer) {
+		s.merge = true
+		s.sliceMerge = slices
+	}
+}
+
+// ArrayLength selects what happens when a TOML array has a different
+// number of elements than the Go array it is decoded into.
+type ArrayLength int
+
+const (
+	// ArrayExact makes Unmarshal fail with a *TypeError.
+	ArrayExact ArrayLength = iota
+	// ArrayTruncate drops extra elements and zeroes missing ones.
+	ArrayTruncate
+)
+
+func WithArrayLength(policy ArrayLength) Option {
+	return func(s *TOMLSerializer) {
+		s.arrayLength = policy
+	}
+}
+
+// ComplexFormat selects how complex64 and complex128 values are written.
+// Without WithComplexFormat they are unsupported.
+type ComplexFormat int
+
+const (
+	ComplexUnsupported ComplexFormat = iota
+	// ComplexString writes strings such as "1+2i".
+	ComplexString
+	// ComplexArray writes the real and imaginary parts as [1.0, 2.0].
+	ComplexArray
+)
+
+// WithComplexFormat enables complex numbers. Unmarshal accepts both forms
+// whichever format is chosen.
+func WithComplexFormat(format ComplexFormat) Option {
+	return func(s *TOMLSerializer) {
+		s.complexFormat = format
+	}
+}
+
+// WithMaxDepth limits how deeply tables and arrays may nest, counting each
+// part of a dotted key or table header: Unmarshal returns a *LimitError
+// for deeper input and Marshal a *DepthError for deeper values. A depth of
+// 0 or less removes the limit.
+func WithMaxDepth(depth int) Option {
+	return func(s *TOMLSerializer) {
+		s.maxDepth = depth
+	}
+}
+
+// Limits bounds the work Unmarshal does for untrusted input. Input beyond
+// a limit fails with a *LimitError matching ErrLimitExceeded. Zero fields
+// are not checked.
+type Limits struct {
+	// MaxInputSize is the largest document accepted, in bytes.
+	MaxInputSize int
+	// MaxDepth, when set, replaces the depth given to WithMaxDepth.
+	MaxDepth int
+	// MaxStringLength is the longest string or key, in bytes after
+	// unescaping.
+	MaxStringLength int
+	// MaxElements is the most elements a single array, or keys a single
+	// table, may have.
+	MaxElements int
+	// MaxAllocations is the most keys, table headers and array elements
+	// the whole document may have, which bounds the maps, slices and
+	// strings allocated for it.
+	MaxAllocations int
+}
+
+func WithLimits(limits Limits) Option {
+	return func(s *TOMLSerializer) {
+		s.limits = limits
+		if limits.MaxDepth > 0 {
+			s.maxDepth = limits.MaxDepth
+		}
+	}
+}
@@ -1,31 +1,60 @@
 package toml
 
 import (
+	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
+	"unicode/utf8"
 )
 
-type TOMLSerializer struct{}
+type TOMLSerializer struct {
+	indent        string
+	lineWidth     int
+	unsortedKeys  bool
+	compareKeys   func(a, b string) int
+	strict        bool
+	merge         bool
+	sliceMerge    SliceMerge
+	arrayLength   ArrayLength
+	complexFormat ComplexFormat
+	maxDepth      int
+	limits        Limits
+	collectErrors bool
+}
 
-func New() *TOMLSerializer {
-	return &TOMLSerializer{}
+func New(opts ...Option) *TOMLSerializer {
+	s := &TOMLSerializer{lineWidth: defaultLineWidth, maxDepth: defaultMaxDepth}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
+var timeType = reflect.TypeOf(time.Time{})
+
 type tokenType int
 
 const (
 	tokenEOF tokenType = iota
+	tokenError
 	tokenString
+	tokenBareKey
 	tokenNumber
 	tokenTrue
 	tokenFalse
 	tokenDate
 	tokenLeftBracket
 	tokenRightBracket
+	tokenLeftBrace
+	tokenRightBrace
 	tokenDot
 	tokenEquals
 	tokenComma
@@ -35,6 +64,31 @@ const (
 type token struct {
 	typ   tokenType
 	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of input"
+	case tokenNewline:
+		return "newline"
+	case tokenString:
+		return strconv.Quote(t.value)
+	}
+	return t.value
+}
+
+// SyntaxError reports where a document could not be parsed. Line and
+// Column are 1-based; Column counts characters, not bytes.
+type SyntaxError struct {
+	Msg    string
+	Line   int
+	Column int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
 }
 
 type lexer struct {
@@ -51,30 +105,38 @@ func newLexer(input string) *lexer {
 func (l *lexer) next() token {
 	l.skipWhitespace()
 
+	start := l.pos
+	tok := l.scan()
+	tok.pos = start
+	return tok
+}
+
+func (l *lexer) scan() token {
 	if l.pos >= len(l.input) {
 		return token{typ: tokenEOF}
 	}
 
 	switch c := l.input[l.pos]; c {
 	case '[':
-		l.pos++
-		l.col++
+		l.advance()
 		return token{typ: tokenLeftBracket, value: "["}
 	case ']':
-		l.pos++
-		l.col++
+		l.advance()
 		return token{typ: tokenRightBracket, value: "]"}
+	case '{':
+		l.advance()
+		return token{typ: tokenLeftBrace, value: "{"}
+	case '}':
+		l.advance()
+		return token{typ: tokenRightBrace, value: "}"}
 	case '.':
-		l.pos++
-		l.col++
+		l.advance()
 		return token{typ: tokenDot, value: "."}
 	case '=':
-		l.pos++
-		l.col++
+		l.advance()
 		return token{typ: tokenEquals, value: "="}
 	case ',':
-		l.pos++
-		l.col++
+		l.advance()
 		return token{typ: tokenComma, value: ","}
 	case '\n':
 		l.pos++
@@ -83,132 +145,466 @@ func (l *lexer) next() token {
 		return token{typ: tokenNewline, value: "\n"}
 	case '"':
 		return l.readString()
-	case 't':
-		if l.pos+3 < len(l.input) && l.input[l.pos:l.pos+4] == "true" {
-			l.pos += 4
-			l.col += 4
-			return token{typ: tokenTrue, value: "true"}
-		}
-	case 'f':
-		if l.pos+4 < len(l.input) && l.input[l.pos:l.pos+5] == "false" {
-			l.pos += 5
-			l.col += 5
-			return token{typ: tokenFalse, value: "false"}
-		}
+	case '\'':
+		return l.readLiteralString()
 	}
 
-	if c := l.input[l.pos]; c == '-' || unicode.IsDigit(rune(c)) {
+	if c := l.input[l.pos]; c == '-' || c == '+' || isDigit(c) {
 		return l.readNumberOrDate()
 	}
 
-	return token{typ: tokenEOF}
+	if isBareKeyChar(l.input[l.pos]) {
+		return l.readBareKey()
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return token{typ: tokenError, value: string(r)}
+}
+
+func (l *lexer) advance() {
+	l.pos++
+	l.col++
 }
 
 func (l *lexer) skipWhitespace() {
 	for l.pos < len(l.input) {
-		c := l.input[l.pos]
-		if c == '\n' {
-			l.line++
-			l.col = 1
-		} else if !unicode.IsSpace(rune(c)) {
-			break
+		switch l.input[l.pos] {
+		case ' ', '\t', '\r':
+			l.advance()
+		case '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.advance()
+			}
+		default:
+			return
 		}
-		l.pos++
-		l.col++
 	}
 }
 
+func (l *lexer) newline() {
+	l.pos++
+	l.line++
+	l.col = 1
+}
+
 func (l *lexer) readString() token {
-	start := l.pos
-	l.pos++ // skip opening quote
-	l.col++
+	if strings.HasPrefix(l.input[l.pos:], `"""`) {
+		return l.readMultilineString()
+	}
+	l.advance() // skip opening quote
 
+	var sb strings.Builder
 	for l.pos < len(l.input) {
 		c := l.input[l.pos]
-		if c == '"' && l.input[l.pos-1] != '\\' {
-			l.pos++ // skip closing quote
-			l.col++
-			return token{typ: tokenString, value: l.input[start+1 : l.pos-1]}
+		switch c {
+		case '"':
+			l.advance() // skip closing quote
+			return token{typ: tokenString, value: sb.String()}
+		case '\n':
+			return token{typ: tokenError, value: "newline in string"}
+		case '\\':
+			if msg := l.readEscape(&sb); msg != "" {
+				return token{typ: tokenError, value: msg}
+			}
+		default:
+			sb.WriteByte(c)
+			l.advance()
 		}
-		if c == '\n' {
-			l.line++
-			l.col = 1
-		} else {
-			l.col++
+	}
+
+	return token{typ: tokenError, value: "unterminated string"}
+}
+
+// readMultilineString reads a """ string. A newline right after the
+// opening quotes is dropped, and a backslash at the end of a line drops
+// the line break and the whitespace after it.
+func (l *lexer) readMultilineString() token {
+	l.skipOpening()
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		switch c := l.input[l.pos]; c {
+		case '"':
+			quotes, end := l.quotes('"')
+			if end {
+				return token{typ: tokenString, value: sb.String() + quotes}
+			}
+			sb.WriteString(quotes)
+		case '\n':
+			sb.WriteByte(c)
+			l.newline()
+		case '\\':
+			if rest := strings.TrimLeft(l.input[l.pos+1:], " \t\r"); strings.HasPrefix(rest, "\n") {
+				l.advance()
+				for l.pos < len(l.input) && strings.IndexByte(" \t\r\n", l.input[l.pos]) >= 0 {
+					if l.input[l.pos] == '\n' {
+						l.newline()
+					} else {
+						l.advance()
+					}
+				}
+				continue
+			}
+			if msg := l.readEscape(&sb); msg != "" {
+				return token{typ: tokenError, value: msg}
+			}
+		default:
+			sb.WriteByte(c)
+			l.advance()
 		}
-		l.pos++
 	}
 
-	return token{typ: tokenEOF}
+	return token{typ: tokenError, value: "unterminated string"}
 }
 
-func (l *lexer) readNumberOrDate() token {
+// readEscape reads the escape sequence at the backslash under l.pos into
+// sb, returning an error message if it is invalid.
+func (l *lexer) readEscape(sb *strings.Builder) string {
+	if l.pos+1 >= len(l.input) {
+		return "unterminated string"
+	}
+	l.advance()
+	switch e := l.input[l.pos]; e {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"', '\\':
+		sb.WriteByte(e)
+	case 'u', 'U':
+		size := 4
+		if e == 'U' {
+			size = 8
+		}
+		if l.pos+size >= len(l.input) {
+			return "invalid unicode escape"
+		}
+		code, err := strconv.ParseUint(l.input[l.pos+1:l.pos+1+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "invalid unicode escape"
+		}
+		sb.WriteRune(rune(code))
+		l.pos += size
+		l.col += size
+	default:
+		return "invalid escape \\" + string(e)
+	}
+	l.advance()
+	return ""
+}
+
+func (l *lexer) readLiteralString() token {
+	if strings.HasPrefix(l.input[l.pos:], "'''") {
+		return l.readMultilineLiteralString()
+	}
+	l.advance() // skip opening quote
 	start := l.pos
-	isDate := false
 
 	for l.pos < len(l.input) {
-		c := l.input[l.pos]
-		if c == 'T' || c == 'Z' || c == '-' || c == ':' {
-			isDate = true
-		} else if !unicode.IsDigit(rune(c)) && c != '.' && c != '+' && c != 'e' && c != 'E' {
-			break
+		switch l.input[l.pos] {
+		case '\'':
+			value := l.input[start:l.pos]
+			l.advance() // skip closing quote
+			return token{typ: tokenString, value: value}
+		case '\n':
+			return token{typ: tokenError, value: "newline in string"}
 		}
-		l.pos++
-		l.col++
+		l.advance()
+	}
+
+	return token{typ: tokenError, value: "unterminated string"}
+}
+
+// readMultilineLiteralString reads a multi-line literal string, taken as
+// written except for a newline right after the opening quotes.
+func (l *lexer) readMultilineLiteralString() token {
+	l.skipOpening()
+	start := l.pos
+
+	for l.pos < len(l.input) {
+		switch l.input[l.pos] {
+		case '\'':
+			end := l.pos
+			if quotes, ok := l.quotes('\''); ok {
+				return token{typ: tokenString, value: l.input[start:end] + quotes}
+			}
+		case '\n':
+			l.newline()
+		default:
+			l.advance()
+		}
+	}
+
+	return token{typ: tokenError, value: "unterminated string"}
+}
+
+// skipOpening skips the three quotes opening a multi-line string and a
+// newline right after them.
+func (l *lexer) skipOpening() {
+	l.pos += 3
+	l.col += 3
+	if strings.HasPrefix(l.input[l.pos:], "\r\n") {
+		l.advance()
+	}
+	if strings.HasPrefix(l.input[l.pos:], "\n") {
+		l.newline()
+	}
+}
+
+// quotes consumes a run of quote characters q inside a multi-line string.
+// A run of three to five closes the string, and end reports it; content
+// holds the quotes that belong to the string.
+func (l *lexer) quotes(q byte) (content string, end bool) {
+	n := 0
+	for l.pos+n < len(l.input) && l.input[l.pos+n] == q {
+		n++
+	}
+	content = l.input[l.pos : l.pos+n]
+	l.pos += n
+	l.col += n
+	if n < 3 {
+		return content, false
+	}
+	// More than five quotes leave a third one inside the string, which
+	// the lexer reports on the next token.
+	extra := min(n-3, 2)
+	l.pos -= n - 3 - extra
+	l.col -= n - 3 - extra
+	return content[:extra], true
+}
+
+func (l *lexer) readBareKey() token {
+	start := l.pos
+	for l.pos < len(l.input) && isBareKeyChar(l.input[l.pos]) {
+		l.advance()
+	}
+
+	switch value := l.input[start:l.pos]; value {
+	case "true":
+		return token{typ: tokenTrue, value: value}
+	case "false":
+		return token{typ: tokenFalse, value: value}
+	case "inf", "nan":
+		return token{typ: tokenNumber, value: value}
+	default:
+		return token{typ: tokenBareKey, value: value}
+	}
+}
+
+func (l *lexer) readNumberOrDate() token {
+	start := l.pos
+
+	if rest := l.input[l.pos+1:]; !isDigit(l.input[l.pos]) && (strings.HasPrefix(rest, "inf") || strings.HasPrefix(rest, "nan")) {
+		l.pos += 4
+		l.col += 4
+		return token{typ: tokenNumber, value: l.input[start:l.pos]}
+	}
+
+	if rest := l.input[l.pos:]; len(rest) > 1 && rest[0] == '0' && strings.IndexByte("xob", rest[1]) >= 0 {
+		l.pos += 2
+		l.col += 2
+		for l.pos < len(l.input) && isBareKeyChar(l.input[l.pos]) && l.input[l.pos] != '-' {
+			l.advance()
+		}
+		return token{typ: tokenNumber, value: l.input[start:l.pos]}
 	}
 
+	l.skipNumberChars()
 	value := l.input[start:l.pos]
-	if isDate {
+	// A date and a time may also be separated by a space.
+	if rest := l.input[l.pos:]; len(value) == 10 && isDate(value) &&
+		len(rest) > 3 && rest[0] == ' ' && isDigit(rest[1]) && isDigit(rest[2]) && rest[3] == ':' {
+		l.advance()
+		l.skipNumberChars()
+		value = l.input[start:l.pos]
+	}
+	if isDate(value) {
 		return token{typ: tokenDate, value: value}
 	}
 	return token{typ: tokenNumber, value: value}
 }
 
+func (l *lexer) skipNumberChars() {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if !isDigit(c) && !strings.ContainsRune(".+-_eE:TZ", rune(c)) {
+			break
+		}
+		l.advance()
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '-'
+}
+
+func isDate(value string) bool {
+	return len(value) >= 10 && value[4] == '-' && value[7] == '-' ||
+		len(value) >= 8 && value[2] == ':' && value[5] == ':'
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func parseDate(value string) (time.Time, error) {
+	if len(value) > 10 && value[10] == ' ' {
+		value = value[:10] + "T" + value[11:]
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
 type parser struct {
-	lexer *lexer
-	token token
+	lexer   *lexer
+	token   token
+	prevEnd int
+
+	// strict rejects keys and table headers that are defined twice. headers
+	// holds the headers seen so far, joined with NUL bytes.
+	strict  bool
+	headers map[string]bool
+
+	// depth counts the tables and arrays enclosing the current value, up
+	// to maxDepth. values counts the keys and array elements read, for
+	// limits.MaxAllocations.
+	depth    int
+	maxDepth int
+	values   int
+	limits   Limits
+
+	// positions, when not nil, maps the path of every key, array element
+	// and table header, joined with NUL bytes, to its offset, for
+	// CollectErrors. path is the path of the current table or value.
+	positions map[string]int
+	path      []string
 }
 
 func newParser(input string) *parser {
 	lexer := newLexer(input)
 	return &parser{
-		lexer: lexer,
-		token: lexer.next(),
+		lexer:    lexer,
+		token:    lexer.next(),
+		maxDepth: defaultMaxDepth,
+	}
+}
+
+// enter moves n levels deeper into the document, failing past the maximum
+// depth. Callers restore depth when the nested value ends.
+func (p *parser) enter(n int) error {
+	p.depth += n
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return &LimitError{Limit: "depth", Max: p.maxDepth}
+	}
+	return nil
+}
+
+// element counts the nth element of an array or key of a table against
+// limits.MaxElements and limits.MaxAllocations.
+func (p *parser) element(n int) error {
+	p.values++
+	switch {
+	case p.limits.MaxElements > 0 && n > p.limits.MaxElements:
+		return &LimitError{Limit: "elements", Max: p.limits.MaxElements}
+	case p.limits.MaxAllocations > 0 && p.values > p.limits.MaxAllocations:
+		return &LimitError{Limit: "allocations", Max: p.limits.MaxAllocations}
+	}
+	return nil
+}
+
+// checkString enforces limits.MaxStringLength on the current string or
+// key token.
+func (p *parser) checkString() error {
+	if max := p.limits.MaxStringLength; max > 0 && len(p.token.value) > max {
+		return &LimitError{Limit: "string length", Max: max}
 	}
+	return nil
 }
 
 func (p *parser) next() {
+	p.prevEnd = p.lexer.pos
 	p.token = p.lexer.next()
 }
 
+// parse parses a whole document, reporting errors at the token where
+// parsing stopped.
+func (p *parser) parse() (map[string]interface{}, error) {
+	table, err := p.parseTable()
+	if err == nil {
+		return table, nil
+	}
+
+	line, col := position(p.lexer.input, p.token.pos)
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		limitErr.Line, limitErr.Column = line, col
+		return nil, limitErr
+	}
+	var typeErr *TypeError
+	if errors.As(err, &typeErr) {
+		typeErr.Line, typeErr.Column = line, col
+		return nil, typeErr
+	}
+
+	msg := err.Error()
+	if p.token.typ == tokenError {
+		msg = p.token.value
+	}
+	return nil, &SyntaxError{Msg: msg, Line: line, Column: col}
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(input string, offset int) (line, col int) {
+	input = input[:offset]
+	lineStart := strings.LastIndexByte(input, '\n') + 1
+	return strings.Count(input, "\n") + 1, utf8.RuneCountInString(input[lineStart:]) + 1
+}
+
+func (p *parser) skipNewlines() {
+	for p.token.typ == tokenNewline {
+		p.next()
+	}
+}
+
 func (p *parser) parseValue() (interface{}, error) {
 	switch p.token.typ {
 	case tokenString:
+		if err := p.checkString(); err != nil {
+			return nil, err
+		}
 		val := p.token.value
 		p.next()
 		return val, nil
 	case tokenNumber:
-		val := p.token.value
-		p.next()
-		if strings.Contains(val, ".") {
-			f, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return nil, err
-			}
-			return f, nil
-		}
-		i, err := strconv.ParseInt(val, 10, 64)
+		v, err := parseNumber(p.token.value)
 		if err != nil {
 			return nil, err
 		}
-		return i, nil
-	case tokenDate:
-		val := p.token.value
 		p.next()
-		t, err := time.Parse(time.RFC3339, val)
+		return v, nil
+	case tokenDate:
+		t, err := parseDate(p.token.value)
 		if err != nil {
 			return nil, err
 		}
+		p.next()
 		return t, nil
 	case tokenTrue:
 		p.next()
@@ -218,29 +614,47 @@ func (p *parser) parseValue() (interface{}, error) {
 		return false, nil
 	case tokenLeftBracket:
 		return p.parseArray()
+	case tokenLeftBrace:
+		return p.parseInlineTable()
 	default:
 		return nil, fmt.Errorf("unexpected token: %v", p.token)
 	}
 }
 
 func (p *parser) parseArray() ([]interface{}, error) {
+	if err := p.enter(1); err != nil {
+		return nil, err
+	}
 	arr := make([]interface{}, 0)
 	p.next() // skip [
 
-	if p.token.typ == tokenRightBracket {
-		p.next()
-		return arr, nil
-	}
-
 	for {
+		p.skipNewlines()
+		if p.token.typ == tokenRightBracket {
+			p.next()
+			p.depth--
+			return arr, nil
+		}
+
+		if err := p.element(len(arr) + 1); err != nil {
+			return nil, err
+		}
+		base := p.path
+		if p.positions != nil {
+			p.path = append(slices.Clip(base), strconv.Itoa(len(arr)))
+			p.positions[strings.Join(p.path, "\x00")] = p.token.pos
+		}
 		value, err := p.parseValue()
 		if err != nil {
-			return nil, err
+			return nil, WithField(err, strconv.Itoa(len(arr)))
 		}
+		p.path = base
 		arr = append(arr, value)
 
+		p.skipNewlines()
 		if p.token.typ == tokenRightBracket {
 			p.next()
+			p.depth--
 			return arr, nil
 		}
 
@@ -251,54 +665,116 @@ func (p *parser) parseArray() ([]interface{}, error) {
 	}
 }
 
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	if err := p.enter(1); err != nil {
+		return nil, err
+	}
+	table := make(map[string]interface{})
+	p.next() // skip {
+
+	if p.token.typ == tokenRightBrace {
+		p.next()
+		p.depth--
+		return table, nil
+	}
+
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		if p.token.typ == tokenRightBrace {
+			p.next()
+			p.depth--
+			return table, nil
+		}
+
+		if p.token.typ != tokenComma {
+			return nil, fmt.Errorf("expected comma or }, got %v", p.token)
+		}
+		p.next()
+	}
+}
+
 func (p *parser) parseTable() (map[string]interface{}, error) {
 	table := make(map[string]interface{})
 	current := table
-	path := make([]string, 0)
+	var header []string
+	root := p.depth
+	if err := p.enter(1); err != nil {
+		return nil, err
+	}
 
 	for p.token.typ != tokenEOF {
 		switch p.token.typ {
+		case tokenNewline:
+			p.next()
+
 		case tokenLeftBracket:
+			pos := p.token.pos
 			p.next()
-			path = p.parseTablePath()
-			if p.token.typ != tokenRightBracket {
-				return nil, fmt.Errorf("expected ], got %v", p.token)
+			isArray := p.token.typ == tokenLeftBracket
+			if isArray {
+				p.next()
 			}
-			p.next()
 
-			// Navigate to the correct nested map
-			current = table
-			for i, key := range path[:len(path)-1] {
-				if _, exists := current[key]; !exists {
-					current[key] = make(map[string]interface{})
-				}
-				if next, ok := current[key].(map[string]interface{}); ok {
-					current = next
-				} else {
-					return nil, fmt.Errorf("cannot use %s as table, it's already defined as a value", strings.Join(path[:i+1], "."))
+			path, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			p.depth = root
+			if err := p.enter(1 + len(path)); err != nil {
+				return nil, err
+			}
+			for closing := 0; closing < 1 || isArray && closing < 2; closing++ {
+				if p.token.typ != tokenRightBracket {
+					return nil, fmt.Errorf("expected ], got %v", p.token)
 				}
+				p.next()
 			}
-			current = current[path[len(path)-1]].(map[string]interface{})
-
-		case tokenString:
-			key := p.token.value
-			p.next()
 
-			if p.token.typ != tokenEquals {
-				return nil, fmt.Errorf("expected =, got %v", p.token)
+			if p.strict && !isArray {
+				name := strings.Join(path, "\x00")
+				if p.headers[name] {
+					return nil, fmt.Errorf("duplicate table [%s]", joinKey(path))
+				}
+				if p.headers == nil {
+					p.headers = make(map[string]bool)
+				}
+				p.headers[name] = true
 			}
-			p.next()
 
-			value, err := p.parseValue()
+			current, err = openTable(table, path, isArray)
 			if err != nil {
 				return nil, err
 			}
-			current[key] = value
+			header = path
+			// A table header counts once, as the nth element of its
+			// array for arrays of tables.
+			n := 1
+			if isArray {
+				parent, _ := descend(table, path[:len(path)-1])
+				n = len(parent[path[len(path)-1]].([]interface{}))
+			}
+			if err := p.element(n); err != nil {
+				return nil, err
+			}
+			if p.positions != nil {
+				p.path = arrayPath(table, path)
+				p.positions[strings.Join(p.path, "\x00")] = pos
+			}
+
+			if err := p.expectLineEnd(); err != nil {
+				return nil, err
+			}
 
-			if p.token.typ != tokenNewline && p.token.typ != tokenEOF {
-				return nil, fmt.Errorf("expected newline or EOF, got %v", p.token)
+		case tokenString, tokenBareKey, tokenNumber, tokenTrue, tokenFalse:
+			if err := p.parseKeyValue(current); err != nil {
+				return nil, withPath(err, arrayPath(table, header))
+			}
+			if err := p.expectLineEnd(); err != nil {
+				return nil, err
 			}
-			p.next()
 
 		default:
 			return nil, fmt.Errorf("unexpected token: %v", p.token)
@@ -308,75 +784,360 @@ func (p *parser) parseTable() (map[string]interface{}, error) {
 	return table, nil
 }
 
-func (p *parser) parseTablePath() []string {
-	var path []string
-	for {
-		if p.token.typ != tokenString {
-			return path
-		}
-		path = append(path, p.token.value)
-		p.next()
-
-		if p.token.typ != tokenDot {
-			return path
-		}
-		p.next()
+func (p *parser) expectLineEnd() error {
+	if p.token.typ != tokenNewline && p.token.typ != tokenEOF {
+		return fmt.Errorf("expected newline or EOF, got %v", p.token)
 	}
+	p.next()
+	return nil
 }
 
-func (s *TOMLSerializer) Unmarshal(data []byte, v any) error {
-	parser := newParser(string(data))
-	value, err := parser.parseTable()
+func (p *parser) parseKeyValue(table map[string]interface{}) error {
+	pos := p.token.pos
+	path, err := p.parseKey()
 	if err != nil {
 		return err
 	}
+	base := p.path
+	if p.positions != nil {
+		p.path = append(slices.Clip(base), path...)
+		p.positions[strings.Join(p.path, "\x00")] = pos
+	}
 
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr {
-		return fmt.Errorf("v must be a pointer")
+	if p.token.typ != tokenEquals {
+		return fmt.Errorf("expected =, got %v", p.token)
+	}
+	p.next()
+
+	// Dotted keys open a table for every part but the last.
+	depth := p.depth
+	if err := p.enter(len(path) - 1); err != nil {
+		return err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return withPath(err, path)
 	}
+	p.depth = depth
+	p.path = base
 
-	return s.setValue(rv.Elem(), value)
+	parent, err := descend(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	if _, ok := parent[path[len(path)-1]]; ok && p.strict {
+		return fmt.Errorf("duplicate key %s", joinKey(path))
+	}
+	parent[path[len(path)-1]] = value
+	return p.element(len(parent))
 }
 
-func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
-	switch rv.Kind() {
-	case reflect.String:
-		if str, ok := value.(string); ok {
-			rv.SetString(str)
-		} else {
-			return fmt.Errorf("cannot convert %v to string", value)
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		switch v := value.(type) {
-		case float64:
-			rv.SetInt(int64(v))
-		case int64:
-			rv.SetInt(v)
-		default:
-			return fmt.Errorf("cannot convert %v to int", value)
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		switch v := value.(type) {
-		case float64:
-			rv.SetUint(uint64(v))
-		case int64:
-			rv.SetUint(uint64(v))
+func (p *parser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		switch p.token.typ {
+		case tokenString, tokenBareKey, tokenTrue, tokenFalse:
+			if err := p.checkString(); err != nil {
+				return nil, err
+			}
+			path = append(path, p.token.value)
+		case tokenNumber:
+			// Bare keys may consist of digits only, e.g. "1 = ..." or
+			// "servers.0.host", where the lexer reads "0." as a number.
+			value := p.token.value
+			trailingDot := strings.HasSuffix(value, ".")
+			path = append(path, strings.Split(strings.TrimSuffix(value, "."), ".")...)
+			if trailingDot {
+				p.next()
+				continue
+			}
 		default:
-			return fmt.Errorf("cannot convert %v to uint", value)
+			return nil, fmt.Errorf("expected key, got %v", p.token)
 		}
-	case reflect.Float32, reflect.Float64:
-		if f, ok := value.(float64); ok {
-			rv.SetFloat(f)
-		} else {
-			return fmt.Errorf("cannot convert %v to float", value)
+		p.next()
+
+		if p.token.typ != tokenDot {
+			return path, nil
+		}
+		p.next()
+	}
+}
+
+// descend walks path from table, creating intermediate tables as needed.
+// For arrays of tables the last element is used, as TOML requires.
+func descend(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	current := table
+	for i, key := range path {
+		switch next := current[key].(type) {
+		case nil:
+			created := make(map[string]interface{})
+			current[key] = created
+			current = created
+		case map[string]interface{}:
+			current = next
+		case []interface{}:
+			last, ok := lastTable(next)
+			if !ok {
+				return nil, fmt.Errorf("cannot use %s as table, it's already defined as a value", strings.Join(path[:i+1], "."))
+			}
+			current = last
+		default:
+			return nil, fmt.Errorf("cannot use %s as table, it's already defined as a value", strings.Join(path[:i+1], "."))
+		}
+	}
+	return current, nil
+}
+
+// arrayPath returns path, the header of a table already opened in root,
+// with the index of the last element added after each array of tables.
+func arrayPath(root map[string]interface{}, path []string) []string {
+	full := make([]string, 0, len(path))
+	current := root
+	for _, key := range path {
+		full = append(full, key)
+		switch next := current[key].(type) {
+		case map[string]interface{}:
+			current = next
+		case []interface{}:
+			full = append(full, strconv.Itoa(len(next)-1))
+			current, _ = lastTable(next)
+		}
+	}
+	return full
+}
+
+func openTable(root map[string]interface{}, path []string, isArray bool) (map[string]interface{}, error) {
+	if !isArray {
+		return descend(root, path)
+	}
+
+	parent, err := descend(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	key := path[len(path)-1]
+	table := make(map[string]interface{})
+	switch existing := parent[key].(type) {
+	case nil:
+		parent[key] = []interface{}{table}
+	case []interface{}:
+		if _, ok := lastTable(existing); !ok {
+			return nil, fmt.Errorf("cannot use %s as array of tables, it's already defined as a value", strings.Join(path, "."))
+		}
+		parent[key] = append(existing, table)
+	default:
+		return nil, fmt.Errorf("cannot use %s as array of tables, it's already defined as a value", strings.Join(path, "."))
+	}
+	return table, nil
+}
+
+func lastTable(arr []interface{}) (map[string]interface{}, bool) {
+	if len(arr) == 0 {
+		return nil, false
+	}
+	last, ok := arr[len(arr)-1].(map[string]interface{})
+	return last, ok
+}
+
+func (s *TOMLSerializer) Unmarshal(data []byte, v any) error {
+	if max := s.limits.MaxInputSize; max > 0 && len(data) > max {
+		line, col := position(string(data), max)
+		return &LimitError{Limit: "input size", Max: max, Line: line, Column: col}
+	}
+	p := newParser(string(data))
+	p.strict = s.strict
+	p.maxDepth = s.maxDepth
+	p.limits = s.limits
+	if s.collectErrors {
+		p.positions = make(map[string]int)
+	}
+	value, err := p.parse()
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer")
+	}
+
+	d := &decodeState{s: s, input: p.lexer.input, positions: p.positions}
+	if err := d.collect(d.setValue(rv.Elem(), value), rv.Elem()); err != nil {
+		return err
+	}
+	if len(d.errs) > 0 {
+		return &MultiError{Errors: d.errs}
+	}
+	return nil
+}
+
+// decodeState stores a parsed document into Go values.
+type decodeState struct {
+	s *TOMLSerializer
+
+	// With CollectErrors, errs holds the field errors recorded so far,
+	// their paths completed as each table or array ends, and path is the
+	// path in the document of the current value, for looking up positions
+	// recorded by the parser.
+	input     string
+	positions map[string]int
+	path      []string
+	errs      []error
+
+	reader Reader
+}
+
+// enter moves into the member key of the current table or array, and
+// returns the mark to pass to leave.
+func (d *decodeState) enter(key string) int {
+	d.path = append(d.path, key)
+	return len(d.errs)
+}
+
+// leave ends the member entered at mark, stored into rv under name: err,
+// the result of decoding it, is collected if possible, and name is added
+// in front of the paths of the errors recorded since mark.
+func (d *decodeState) leave(mark int, name string, err error, rv reflect.Value) error {
+	err = d.collect(err, rv)
+	d.path = d.path[:len(d.path)-1]
+	for _, e := range d.errs[mark:] {
+		WithField(e, name)
+	}
+	return WithField(err, name)
+}
+
+// collect records err when it is a field error and the serializer collects
+// errors, leaving rv zero. Other errors are returned as is.
+func (d *decodeState) collect(err error, rv reflect.Value) error {
+	if err == nil || !d.s.collectErrors {
+		return err
+	}
+	switch err.(type) {
+	case *TypeError, *MissingFieldError, *UnknownFieldError, *AmbiguousFieldError:
+	default:
+		return err
+	}
+	rv.SetZero()
+	d.record(err)
+	return nil
+}
+
+// record adds err, located at the current value or at its member key if
+// given, to the collected errors.
+func (d *decodeState) record(err error, key ...string) {
+	line, col := 0, 0
+	if offset, ok := d.positions[strings.Join(append(slices.Clip(d.path), key...), "\x00")]; ok {
+		line, col = position(d.input, offset)
+	}
+	switch e := err.(type) {
+	case *TypeError:
+		e.Line, e.Column = line, col
+	case *MissingFieldError:
+		e.Line, e.Column = line, col
+	case *UnknownFieldError:
+		e.Line, e.Column = line, col
+	case *AmbiguousFieldError:
+		e.Line, e.Column = line, col
+	}
+	d.errs = append(d.errs, err)
+}
+
+// docKey returns the key of obj that matchKeys took the value of the field
+// called name from.
+func docKey(obj map[string]interface{}, fields []field, name string) string {
+	if _, ok := obj[name]; ok {
+		return name
+	}
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		if i, _ := lookupField(fields, key); i >= 0 && fields[i].name == name {
+			return key
+		}
+	}
+	return name
+}
+
+// matchTable returns doc with its keys matched to fields. In strict mode
+// keys matching several fields or none are errors, or with CollectErrors
+// are recorded.
+func (d *decodeState) matchTable(doc map[string]interface{}, fields []field) (map[string]interface{}, error) {
+	s := d.s
+	obj, err := matchKeys(doc, fields, s.strict)
+	if err != nil {
+		e, ok := err.(*AmbiguousFieldError)
+		if !ok || !s.collectErrors {
+			return nil, err
+		}
+		d.record(err, e.Field)
+		obj, _ = matchKeys(doc, fields, false)
+	}
+	if s.strict {
+		for _, key := range slices.Sorted(maps.Keys(obj)) {
+			if !slices.ContainsFunc(fields, func(f field) bool { return f.name == key }) {
+				if !s.collectErrors {
+					return nil, &UnknownFieldError{Field: key}
+				}
+				d.record(&UnknownFieldError{Field: key}, key)
+			}
+		}
+	}
+	return obj, nil
+}
+
+func (d *decodeState) setValue(rv reflect.Value, value interface{}) error {
+	s := d.s
+	if rv.CanAddr() && rv.Kind() != reflect.Ptr {
+		switch u := rv.Addr().Interface().(type) {
+		case Decoder:
+			if d.reader.d == nil {
+				d.reader.d = d
+			}
+			return u.DecodeTOML(&d.reader, value)
+		case Unmarshaler:
+			return u.UnmarshalTOML(value)
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		str, ok := value.(string)
+		if !ok {
+			return newTypeError(value, rv.Type())
+		}
+		rv.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := decodeInt(value, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := decodeUint(value, rv.Type())
+		if err != nil {
+			return err
 		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := decodeFloat(value, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		if s.complexFormat == ComplexUnsupported {
+			return fmt.Errorf("unsupported type: %v", rv.Kind())
+		}
+		c, err := s.decodeComplex(value, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.SetComplex(c)
 	case reflect.Bool:
-		if b, ok := value.(bool); ok {
-			rv.SetBool(b)
-		} else {
-			return fmt.Errorf("cannot convert %v to bool", value)
+		b, ok := value.(bool)
+		if !ok {
+			return newTypeError(value, rv.Type())
 		}
+		rv.SetBool(b)
 	case reflect.Slice:
 		if value == nil {
 			rv.Set(reflect.Zero(rv.Type()))
@@ -384,11 +1145,47 @@ func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
 		}
 		arr, ok := value.([]interface{})
 		if !ok {
-			return fmt.Errorf("cannot convert %v to slice", value)
+			return newTypeError(value, rv.Type())
+		}
+		// Elements already in the slice are decoded into in place when
+		// merging.
+		start := 0
+		switch {
+		case !s.merge || s.sliceMerge == ReplaceSlices:
+			rv.Set(reflect.MakeSlice(rv.Type(), len(arr), len(arr)))
+		case s.sliceMerge == AppendSlices:
+			start = rv.Len()
+			rv.Set(reflect.AppendSlice(rv, reflect.MakeSlice(rv.Type(), len(arr), len(arr))))
+		case len(arr) > rv.Len():
+			rv.Set(reflect.AppendSlice(rv, reflect.MakeSlice(rv.Type(), len(arr)-rv.Len(), len(arr)-rv.Len())))
 		}
-		rv.Set(reflect.MakeSlice(rv.Type(), len(arr), len(arr)))
 		for i, v := range arr {
-			if err := s.setValue(rv.Index(i), v); err != nil {
+			index := strconv.Itoa(i)
+			mark := d.enter(index)
+			if err := d.leave(mark, index, d.setValue(rv.Index(start+i), v), rv.Index(start+i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		if value == nil {
+			rv.SetZero()
+			return nil
+		}
+		arr, ok := value.([]interface{})
+		if !ok {
+			return newTypeError(value, rv.Type())
+		}
+		if len(arr) != rv.Len() && s.arrayLength == ArrayExact {
+			return &TypeError{Value: fmt.Sprintf("array of %d elements", len(arr)), Type: rv.Type()}
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(arr) {
+				rv.Index(i).SetZero()
+				continue
+			}
+			index := strconv.Itoa(i)
+			mark := d.enter(index)
+			if err := d.leave(mark, index, d.setValue(rv.Index(i), arr[i]), rv.Index(i)); err != nil {
 				return err
 			}
 		}
@@ -399,13 +1196,28 @@ func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
 		}
 		obj, ok := value.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("cannot convert %v to map", value)
+			return newTypeError(value, rv.Type())
+		}
+		t := rv.Type()
+		if !isValidMapKey(t.Key()) && !reflect.PointerTo(t.Key()).Implements(textUnmarshalerType) {
+			return fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		if !s.merge || rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
 		}
-		rv.Set(reflect.MakeMap(rv.Type()))
 		for k, v := range obj {
-			key := reflect.ValueOf(k)
+			key, err := parseMapKey(t.Key(), k)
+			if err != nil {
+				return err
+			}
 			elem := reflect.New(rv.Type().Elem()).Elem()
-			if err := s.setValue(elem, v); err != nil {
+			if s.merge {
+				if existing := rv.MapIndex(key); existing.IsValid() {
+					elem.Set(existing)
+				}
+			}
+			mark := d.enter(k)
+			if err := d.leave(mark, k, d.setValue(elem, v), elem); err != nil {
 				return err
 			}
 			rv.SetMapIndex(key, elem)
@@ -415,29 +1227,55 @@ func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
 			rv.Set(reflect.Zero(rv.Type()))
 			return nil
 		}
+		if rv.Type() == timeType {
+			t, ok := value.(time.Time)
+			if !ok {
+				return newTypeError(value, rv.Type())
+			}
+			rv.Set(reflect.ValueOf(t))
+			return nil
+		}
 		obj, ok := value.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("cannot convert %v to struct", value)
+			return newTypeError(value, rv.Type())
 		}
-		t := rv.Type()
-		for i := 0; i < rv.NumField(); i++ {
-			field := t.Field(i)
-			if !field.IsExported() {
+		fields := cachedFields(rv.Type())
+		doc := obj
+		obj, err := d.matchTable(doc, fields)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			v, ok := obj[f.name]
+			if !ok {
+				if f.required {
+					if !s.collectErrors {
+						return &MissingFieldError{Field: f.name}
+					}
+					d.record(&MissingFieldError{Field: f.name})
+				}
 				continue
 			}
-			tomlTag := field.Tag.Get("toml")
-			if tomlTag == "-" {
-				continue
+			fv, err := fieldByIndexAlloc(rv, f.index)
+			if err != nil {
+				return err
 			}
-			name := field.Name
-			if tomlTag != "" {
-				name = strings.Split(tomlTag, ",")[0]
+			key := f.name
+			if d.positions != nil {
+				key = docKey(doc, fields, f.name)
 			}
-			if v, ok := obj[name]; ok {
-				if err := s.setValue(rv.Field(i), v); err != nil {
-					return err
+			mark := d.enter(key)
+			if str, ok := v.(string); ok && f.quoted {
+				if v, err = parseQuoted(str); err != nil {
+					err = &TypeError{Value: "string " + strconv.Quote(str), Type: fv.Type()}
 				}
 			}
+			if err == nil {
+				err = d.setValue(fv, v)
+			}
+			if err := d.leave(mark, f.name, err, fv); err != nil {
+				return err
+			}
 		}
 	case reflect.Ptr:
 		if value == nil {
@@ -447,12 +1285,17 @@ func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
 		if rv.IsNil() {
 			rv.Set(reflect.New(rv.Type().Elem()))
 		}
-		return s.setValue(rv.Elem(), value)
+		return d.setValue(rv.Elem(), value)
 	case reflect.Interface:
 		if value == nil {
 			rv.Set(reflect.Zero(rv.Type()))
 			return nil
 		}
+		if m, ok := rv.Interface().(map[string]interface{}); ok && m != nil && s.merge {
+			if _, ok := value.(map[string]interface{}); ok {
+				return d.setValue(reflect.ValueOf(m), value)
+			}
+		}
 		rv.Set(reflect.ValueOf(value))
 	default:
 		return fmt.Errorf("unsupported type: %v", rv.Kind())
@@ -461,137 +1304,797 @@ func (s *TOMLSerializer) setValue(rv reflect.Value, value interface{}) error {
 }
 
 func (s *TOMLSerializer) Marshal(v any) ([]byte, error) {
-	return s.marshalValue(reflect.ValueOf(v), "")
+	// Generated code goes through appendTable, which knows about pretty
+	// output.
+	switch v.(type) {
+	case Encoder, Appender:
+	default:
+		if m, ok := v.(Marshaler); ok {
+			return m.MarshalTOML()
+		}
+	}
+
+	rv := indirect(reflect.ValueOf(v))
+	if !isTable(rv) {
+		return s.appendValue(nil, rv)
+	}
+	return s.appendTable(nil, rv, nil)
+}
+
+type tableEntry struct {
+	key   string
+	value reflect.Value
+}
+
+// encodeState tracks the nesting of the value being encoded: its depth,
+// and the structs, maps and slices currently open, so that a value which
+// contains itself is reported instead of overflowing the stack.
+type encodeState struct {
+	s      *TOMLSerializer
+	depth  int
+	visits []visit
+	deep   map[visit]struct{}
+	writer Writer
+}
+
+// visit identifies a struct by address, or a map or slice by pointer.
+// Slices sharing a pointer differ by length, a struct and its first field
+// by type.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func (s *TOMLSerializer) appendTable(buf []byte, v reflect.Value, path []string) ([]byte, error) {
+	e := &encodeState{s: s}
+	e.writer.e = e
+	return e.appendTable(buf, v, path)
+}
+
+func (s *TOMLSerializer) appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	e := &encodeState{s: s}
+	e.writer.e = e
+	return e.appendValue(buf, v)
+}
+
+// enter opens a table or array, failing if it is nested too deeply or
+// already open further up. After an error the state is discarded, so only
+// successful paths call leave.
+func (e *encodeState) enter(v reflect.Value) error {
+	e.depth++
+	if e.s.maxDepth > 0 && e.depth > e.s.maxDepth {
+		return &DepthError{Depth: e.s.maxDepth}
+	}
+	if key, ok := visitOf(v); ok {
+		return e.visit(key)
+	}
+	return nil
+}
+
+func (e *encodeState) leave(v reflect.Value) {
+	e.depth--
+	if key, ok := visitOf(v); ok {
+		e.unvisit(key)
+	}
+}
+
+// shallowVisits is how many open values are kept in a slice and searched
+// linearly. Values nested deeper go into a map, so long chains of pointers
+// are checked in constant time.
+const shallowVisits = 32
+
+func (e *encodeState) visit(key visit) error {
+	if slices.Contains(e.visits, key) {
+		return &CycleError{Type: key.typ}
+	}
+	if len(e.visits) < shallowVisits {
+		e.visits = append(e.visits, key)
+		return nil
+	}
+	if _, ok := e.deep[key]; ok {
+		return &CycleError{Type: key.typ}
+	}
+	if e.deep == nil {
+		e.deep = make(map[visit]struct{})
+	}
+	e.deep[key] = struct{}{}
+	return nil
+}
+
+// unvisit closes key, the value opened last.
+func (e *encodeState) unvisit(key visit) {
+	if len(e.deep) > 0 {
+		delete(e.deep, key)
+		return
+	}
+	e.visits = e.visits[:len(e.visits)-1]
+}
+
+func visitOf(v reflect.Value) (visit, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		return visit{ptr: v.Pointer(), typ: v.Type()}, true
+	case reflect.Slice:
+		return visit{ptr: v.Pointer(), typ: v.Type(), len: v.Len()}, true
+	case reflect.Struct:
+		if v.CanAddr() {
+			return visit{ptr: v.UnsafeAddr(), typ: v.Type()}, true
+		}
+	}
+	return visit{}, false
+}
+
+// appendTable writes plain key/value pairs of v first, followed by its
+// sub-tables and arrays of tables, since TOML assigns every key after a
+// table header to that table.
+func (e *encodeState) appendTable(buf []byte, v reflect.Value, path []string) ([]byte, error) {
+	switch m := tableMarshaler(v).(type) {
+	case Encoder:
+		// Generated code writes compact output; pretty output takes the
+		// reflective path, which yields the same keys.
+		if e.s.indent == "" || v.Kind() != reflect.Struct {
+			return e.encodeTable(m, buf, v, path)
+		}
+	case Appender:
+		if e.s.indent == "" || v.Kind() != reflect.Struct {
+			return m.AppendTOML(buf, path)
+		}
+	case Marshaler:
+		tree, err := marshalTree(m)
+		if err != nil {
+			return nil, err
+		}
+		v = reflect.ValueOf(tree)
+	}
+	if err := e.enter(v); err != nil {
+		return nil, err
+	}
+
+	entries, err := e.tableEntries(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var inline, tables, arrays []tableEntry
+	for _, entry := range entries {
+		value := indirect(entry.value)
+		switch {
+		case isTable(value):
+			tables = append(tables, tableEntry{entry.key, value})
+		case isArrayOfTables(value):
+			arrays = append(arrays, tableEntry{entry.key, value})
+		default:
+			inline = append(inline, tableEntry{entry.key, value})
+		}
+	}
+
+	// Pretty output aligns the "=" of all keys in the table.
+	width := 0
+	if e.s.indent != "" {
+		for _, entry := range inline {
+			width = max(width, utf8.RuneCount(appendKey(nil, entry.key)))
+		}
+	}
+
+	for _, entry := range inline {
+		lineStart := len(buf)
+		buf = appendKey(buf, entry.key)
+		for n := utf8.RuneCount(buf[lineStart:]); n < width; n++ {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, " = "...)
+
+		valueStart := len(buf)
+		if buf, err = e.appendValue(buf, entry.value); err != nil {
+			return nil, WithField(err, entry.key)
+		}
+		if e.s.indent != "" && isArray(entry.value) && utf8.RuneCount(buf[lineStart:]) > e.s.lineWidth {
+			if buf, err = e.appendMultilineArray(buf[:valueStart], entry.value); err != nil {
+				return nil, WithField(err, entry.key)
+			}
+		}
+		buf = append(buf, '\n')
+	}
+
+	for _, table := range tables {
+		subPath := append(path[:len(path):len(path)], table.key)
+		buf = appendHeader(buf, subPath, false)
+		if buf, err = e.appendTable(buf, table.value, subPath); err != nil {
+			return nil, WithField(err, table.key)
+		}
+	}
+
+	for _, array := range arrays {
+		subPath := append(path[:len(path):len(path)], array.key)
+		for i := 0; i < array.value.Len(); i++ {
+			buf = appendHeader(buf, subPath, true)
+			if buf, err = e.appendTable(buf, indirect(array.value.Index(i)), subPath); err != nil {
+				return nil, WithField(WithField(err, strconv.Itoa(i)), array.key)
+			}
+		}
+	}
+
+	e.leave(v)
+	return buf, nil
+}
+
+// encodeTable runs generated code for the table v. The code counts the
+// depth itself, so only the address of v is tracked here, which catches
+// cycles through pointers.
+func (e *encodeState) encodeTable(m Encoder, buf []byte, v reflect.Value, path []string) ([]byte, error) {
+	// Depth is checked before cycles, as enter does.
+	if e.s.maxDepth > 0 && e.depth >= e.s.maxDepth {
+		return nil, &DepthError{Depth: e.s.maxDepth}
+	}
+	key, ok := visitOf(v)
+	if !ok {
+		return m.EncodeTOML(&e.writer, buf, path)
+	}
+	if err := e.visit(key); err != nil {
+		return nil, err
+	}
+	buf, err := m.EncodeTOML(&e.writer, buf, path)
+	if err == nil {
+		e.unvisit(key)
+	}
+	return buf, err
+}
+
+func appendHeader(buf []byte, path []string, isArray bool) []byte {
+	if len(buf) > 0 {
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, '[')
+	if isArray {
+		buf = append(buf, '[')
+	}
+	for i, key := range path {
+		if i > 0 {
+			buf = append(buf, '.')
+		}
+		buf = appendKey(buf, key)
+	}
+	buf = append(buf, ']')
+	if isArray {
+		buf = append(buf, ']')
+	}
+	return append(buf, '\n')
+}
+
+// isValidMapKey reports whether maps keyed by t can be encoded: strings,
+// integers and encoding.TextMarshaler implementations.
+func isValidMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshalerType)
+}
+
+// mapKeyName returns the TOML key for a map key. String kinds are used as
+// is, even if they implement encoding.TextMarshaler.
+func mapKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %v", k.Type())
+}
+
+// parseMapKey converts a TOML key back to a map key of type t, the inverse
+// of mapKeyName.
+func parseMapKey(t reflect.Type, key string) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		kv := reflect.New(t)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %v", key, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %v", key, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported map key type %v", t)
 }
 
-func (s *TOMLSerializer) marshalValue(v reflect.Value, prefix string) ([]byte, error) {
+// tableEntries lists the keys of a struct or map. Nil values are left out,
+// as TOML has no null.
+func (e *encodeState) tableEntries(v reflect.Value) ([]tableEntry, error) {
+	var entries []tableEntry
+
+	if v.Kind() == reflect.Map {
+		if !isValidMapKey(v.Type().Key()) {
+			return nil, fmt.Errorf("unsupported map key type %v", v.Type().Key())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if isNil(iter.Value()) {
+				continue
+			}
+			key, err := mapKeyName(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, tableEntry{key, iter.Value()})
+		}
+		if !e.s.unsortedKeys {
+			cmp := e.s.compareKeys
+			if cmp == nil {
+				cmp = strings.Compare
+			}
+			slices.SortFunc(entries, func(a, b tableEntry) int {
+				return cmp(a.key, b.key)
+			})
+		}
+		return entries, nil
+	}
+
+	fields := cachedFields(v.Type())
+	for i := range fields {
+		f := &fields[i]
+		value, ok := fieldByIndex(v, f.index)
+		if !ok || isNil(value) || f.omitted(value) {
+			continue
+		}
+		if f.quoted {
+			text, err := e.appendValue(nil, value)
+			if err != nil {
+				return nil, err
+			}
+			value = reflect.ValueOf(string(text))
+		}
+		entries = append(entries, tableEntry{f.name, value})
+	}
+	return entries, nil
+}
+
+func (e *encodeState) appendValue(buf []byte, v reflect.Value) ([]byte, error) {
 	switch v.Kind() {
 	case reflect.String:
-		return []byte(`"` + escapeString(v.String()) + `"`), nil
+		return appendString(buf, v.String()), nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return []byte(strconv.FormatInt(v.Int(), 10)), nil
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return []byte(strconv.FormatUint(v.Uint(), 10)), nil
+		return strconv.AppendInt(buf, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(buf, v.Uint(), 10), nil
 	case reflect.Float32, reflect.Float64:
-		return []byte(strconv.FormatFloat(v.Float(), 'f', -1, 64)), nil
+		return appendFloat(buf, v.Float(), v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return e.s.appendComplex(buf, v.Complex(), v.Type().Bits())
 	case reflect.Bool:
-		return []byte(strconv.FormatBool(v.Bool())), nil
+		return strconv.AppendBool(buf, v.Bool()), nil
 	case reflect.Slice, reflect.Array:
-		return s.marshalArray(v)
-	case reflect.Map:
-		return s.marshalMap(v, prefix)
-	case reflect.Struct:
-		return s.marshalStruct(v, prefix)
-	case reflect.Ptr:
-		if v.IsNil() {
-			return []byte("null"), nil
+		return e.appendArray(buf, v)
+	case reflect.Map, reflect.Struct:
+		if v.Type() == timeType {
+			t := v.Interface().(time.Time)
+			return t.AppendFormat(buf, time.RFC3339Nano), nil
 		}
-		return s.marshalValue(v.Elem(), prefix)
-	case reflect.Interface:
+		return e.appendInlineTable(buf, v)
+	case reflect.Ptr, reflect.Interface:
 		if v.IsNil() {
-			return []byte("null"), nil
+			return append(buf, "null"...), nil
 		}
-		return s.marshalValue(v.Elem(), prefix)
+		return e.appendValue(buf, v.Elem())
 	case reflect.Invalid:
-		return []byte("null"), nil
+		return append(buf, "null"...), nil
 	default:
 		return nil, fmt.Errorf("unsupported type: %v", v.Kind())
 	}
 }
 
-func (s *TOMLSerializer) marshalArray(v reflect.Value) ([]byte, error) {
-	if v.IsNil() {
-		return []byte("[]"), nil
+func (e *encodeState) appendArray(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return append(buf, "[]"...), nil
+	}
+
+	err := e.enter(v)
+	if err != nil {
+		return nil, err
 	}
+	buf = append(buf, '[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+		if buf, err = e.appendValue(buf, v.Index(i)); err != nil {
+			return nil, WithField(err, strconv.Itoa(i))
+		}
+	}
+	e.leave(v)
+	return append(buf, ']'), nil
+}
 
-	var elements []string
+// appendMultilineArray writes one element per line, each followed by a
+// comma.
+func (e *encodeState) appendMultilineArray(buf []byte, v reflect.Value) ([]byte, error) {
+	err := e.enter(v)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, "[\n"...)
 	for i := 0; i < v.Len(); i++ {
-		element, err := s.marshalValue(v.Index(i), "")
+		buf = append(buf, e.s.indent...)
+		if buf, err = e.appendValue(buf, v.Index(i)); err != nil {
+			return nil, WithField(err, strconv.Itoa(i))
+		}
+		buf = append(buf, ",\n"...)
+	}
+	e.leave(v)
+	return append(buf, ']'), nil
+}
+
+func (e *encodeState) appendInlineTable(buf []byte, v reflect.Value) ([]byte, error) {
+	if m, ok := tableMarshaler(v).(Marshaler); ok {
+		tree, err := marshalTree(m)
 		if err != nil {
 			return nil, err
 		}
-		elements = append(elements, string(element))
+		v = reflect.ValueOf(tree)
+	}
+	if err := e.enter(v); err != nil {
+		return nil, err
 	}
-	return []byte("[" + strings.Join(elements, ", ") + "]"), nil
+
+	entries, err := e.tableEntries(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		e.leave(v)
+		return append(buf, "{}"...), nil
+	}
+
+	buf = append(buf, "{ "...)
+	for i, entry := range entries {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = appendKey(buf, entry.key)
+		buf = append(buf, " = "...)
+		if buf, err = e.appendValue(buf, entry.value); err != nil {
+			return nil, WithField(err, entry.key)
+		}
+	}
+	e.leave(v)
+	return append(buf, " }"...), nil
 }
 
-func (s *TOMLSerializer) marshalMap(v reflect.Value, prefix string) ([]byte, error) {
-	if v.IsNil() {
-		return []byte("{}"), nil
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
 	}
+	return v
+}
 
-	var pairs []string
-	iter := v.MapRange()
-	for iter.Next() {
-		key := iter.Key()
-		value := iter.Value()
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	case reflect.Invalid:
+		return true
+	}
+	return false
+}
 
-		keyStr := key.String()
-		if prefix != "" {
-			keyStr = prefix + "." + keyStr
+func isTable(v reflect.Value) bool {
+	return v.Kind() == reflect.Map || v.Kind() == reflect.Struct && v.Type() != timeType || tableMarshaler(v) != nil
+}
+
+func isArray(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
+
+func isArrayOfTables(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || v.Len() == 0 {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !isTable(indirect(v.Index(i))) {
+			return false
 		}
+	}
+	return true
+}
 
-		valueBytes, err := s.marshalValue(value, keyStr)
-		if err != nil {
-			return nil, err
+func quoteKey(key string) string {
+	return string(appendKey(nil, key))
+}
+
+func appendKey(buf []byte, key string) []byte {
+	if key == "" {
+		return append(buf, `""`...)
+	}
+	for i := 0; i < len(key); i++ {
+		if !isBareKeyChar(key[i]) {
+			return appendString(buf, key)
 		}
+	}
+	return append(buf, key...)
+}
 
-		pairs = append(pairs, keyStr+" = "+string(valueBytes))
+func joinKey(path []string) string {
+	quoted := make([]string, len(path))
+	for i, key := range path {
+		quoted[i] = quoteKey(key)
 	}
-	return []byte(strings.Join(pairs, "\n")), nil
+	return strings.Join(quoted, ".")
 }
 
-func (s *TOMLSerializer) marshalStruct(v reflect.Value, prefix string) ([]byte, error) {
-	var pairs []string
-	t := v.Type()
+// appendFloat writes the shortest text that reads back as the same value
+// of the given bit size. Whole numbers get a ".0" so that they stay floats,
+// very large and very small ones use exponent notation, and NaN and
+// infinities are written as TOML's nan and inf.
+func appendFloat(buf []byte, f float64, bitSize int) ([]byte, error) {
+	switch {
+	case math.IsNaN(f):
+		return append(buf, "nan"...), nil
+	case math.IsInf(f, 1):
+		return append(buf, "inf"...), nil
+	case math.IsInf(f, -1):
+		return append(buf, "-inf"...), nil
+	}
 
-	// Handle time.Time specially
-	if t == reflect.TypeOf(time.Time{}) {
-		t := v.Interface().(time.Time)
-		return []byte(t.Format(time.RFC3339)), nil
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bitSize == 32 {
+			abs = float64(float32(abs))
+		}
+		if abs < 1e-6 || abs >= 1e21 {
+			format = 'e'
+		}
 	}
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, format, -1, bitSize)
+	if format == 'f' && !bytes.ContainsRune(buf[start:], '.') {
+		buf = append(buf, ".0"...)
+	}
+	return buf, nil
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		value := v.Field(i)
+// appendComplex writes a complex number in the serializer's complex format.
+func (s *TOMLSerializer) appendComplex(buf []byte, c complex128, bitSize int) ([]byte, error) {
+	switch s.complexFormat {
+	case ComplexString:
+		text := strconv.FormatComplex(c, 'g', -1, bitSize)
+		return appendString(buf, text[1:len(text)-1]), nil
+	case ComplexArray:
+		var err error
+		buf = append(buf, '[')
+		if buf, err = appendFloat(buf, real(c), bitSize/2); err != nil {
+			return nil, err
+		}
+		buf = append(buf, ", "...)
+		if buf, err = appendFloat(buf, imag(c), bitSize/2); err != nil {
+			return nil, err
+		}
+		return append(buf, ']'), nil
+	default:
+		return nil, fmt.Errorf("unsupported type: complex%d", bitSize)
+	}
+}
 
-		// Skip unexported fields
-		if !field.IsExported() {
-			continue
+// decodeComplex accepts a complex number written either as a string or as
+// an array of its real and imaginary parts.
+func (s *TOMLSerializer) decodeComplex(value interface{}, t reflect.Type) (complex128, error) {
+	switch v := value.(type) {
+	case string:
+		c, err := strconv.ParseComplex(v, t.Bits())
+		if err != nil {
+			return 0, &TypeError{Value: "string " + strconv.Quote(v), Type: t}
+		}
+		return c, nil
+	case []interface{}:
+		if len(v) != 2 {
+			return 0, &TypeError{Value: fmt.Sprintf("array of %d elements", len(v)), Type: t}
 		}
+		part := float64Type
+		if t.Kind() == reflect.Complex64 {
+			part = float32Type
+		}
+		var parts [2]float64
+		for i := range parts {
+			f, err := decodeFloat(v[i], part)
+			if err != nil {
+				return 0, WithField(err, strconv.Itoa(i))
+			}
+			parts[i] = f
+		}
+		return complex(parts[0], parts[1]), nil
+	}
+	return 0, newTypeError(value, t)
+}
 
-		// Get TOML tag or use field name
-		tomlTag := field.Tag.Get("toml")
-		if tomlTag == "-" {
-			continue
+// parseQuoted parses the number or bool inside the string value of a
+// field tagged ",string".
+func parseQuoted(str string) (interface{}, error) {
+	p := newParser(str)
+	switch p.token.typ {
+	case tokenNumber, tokenTrue, tokenFalse:
+	default:
+		return nil, fmt.Errorf("not a number or bool: %q", str)
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %v after value", p.token)
+	}
+	return v, nil
+}
+
+// specialFloat parses the inf and nan literals, which may carry a sign.
+func specialFloat(lit string) (float64, bool) {
+	switch strings.TrimLeft(lit, "+-") {
+	case "inf":
+		if strings.HasPrefix(lit, "-") {
+			return math.Inf(-1), true
 		}
+		return math.Inf(1), true
+	case "nan":
+		return math.NaN(), true
+	}
+	return 0, false
+}
 
-		name := field.Name
-		if tomlTag != "" {
-			name = strings.Split(tomlTag, ",")[0]
+// parseNumber parses an integer or float literal into an int64 or a
+// float64. Underscores may only stand between two digits.
+func parseNumber(lit string) (interface{}, error) {
+	if f, ok := specialFloat(lit); ok {
+		return f, nil
+	}
+	if len(lit) > 1 && lit[0] == '0' && strings.IndexByte("xob", lit[1]) >= 0 {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[lit[1]]
+		if !digitRun(lit[2:], base) {
+			return nil, fmt.Errorf("invalid integer %s", lit)
 		}
+		i, err := strconv.ParseInt(strings.ReplaceAll(lit[2:], "_", ""), base, 64)
+		if err != nil {
+			return nil, numberError("integer", lit, intTypes[8], err)
+		}
+		return i, nil
+	}
+	if !validDecimal(lit) {
+		return nil, fmt.Errorf("invalid number %s", lit)
+	}
+	val := strings.ReplaceAll(lit, "_", "")
+	if leadingZero(val) {
+		return nil, fmt.Errorf("leading zeros are not allowed in %s", lit)
+	}
+	if strings.ContainsAny(val, ".eE") {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, numberError("float", lit, float64Type, err)
+		}
+		return f, nil
+	}
+	i, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return nil, numberError("integer", lit, intTypes[8], err)
+	}
+	return i, nil
+}
 
-		if prefix != "" {
-			name = prefix + "." + name
+// validDecimal reports whether lit is a sign, an integer part, an optional
+// fraction and an optional exponent, each made of digit runs.
+func validDecimal(lit string) bool {
+	if lit != "" && (lit[0] == '+' || lit[0] == '-') {
+		lit = lit[1:]
+	}
+	mantissa, exp, hasExp := strings.Cut(strings.ToLower(lit), "e")
+	whole, frac, hasFrac := strings.Cut(mantissa, ".")
+	if !digitRun(whole, 10) || hasFrac && !digitRun(frac, 10) {
+		return false
+	}
+	if hasExp {
+		if exp != "" && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
 		}
+		return digitRun(exp, 10)
+	}
+	return true
+}
 
-		valueBytes, err := s.marshalValue(value, name)
-		if err != nil {
-			return nil, err
+// digitRun reports whether s is a non-empty run of digits in base, with
+// single underscores allowed between them.
+func digitRun(s string, base int) bool {
+	if s == "" || s[0] == '_' || s[len(s)-1] == '_' || strings.Contains(s, "__") {
+		return false
+	}
+	for _, c := range s {
+		if c == '_' {
+			continue
+		}
+		if d, err := strconv.ParseUint(string(c), 36, 8); err != nil || int(d) >= base {
+			return false
 		}
+	}
+	return true
+}
 
-		pairs = append(pairs, name+" = "+string(valueBytes))
+// leadingZero reports a zero followed by another digit, which TOML forbids.
+func leadingZero(lit string) bool {
+	if lit != "" && (lit[0] == '+' || lit[0] == '-') {
+		lit = lit[1:]
 	}
-	return []byte(strings.Join(pairs, "\n")), nil
+	return len(lit) > 1 && lit[0] == '0' && isDigit(lit[1])
 }
 
-func escapeString(s string) string {
-	s = strings.ReplaceAll(s, `\`, `\\`)
-	s = strings.ReplaceAll(s, `"`, `\"`)
-	s = strings.ReplaceAll(s, "\n", `\n`)
-	s = strings.ReplaceAll(s, "\r", `\r`)
-	s = strings.ReplaceAll(s, "\t", `\t`)
-	return s
+// numberError reports a literal strconv rejected as out of range or malformed.
+func numberError(kind, lit string, t reflect.Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return &TypeError{Value: kind + " " + lit, Type: t}
+	}
+	return fmt.Errorf("invalid %s %s", kind, lit)
+}
+
+// withPath prefixes the field of err with the keys of path.
+func withPath(err error, path []string) error {
+	for i := len(path) - 1; i >= 0; i-- {
+		err = WithField(err, path[i])
+	}
+	return err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '\\':
+			esc = `\\`
+		case '"':
+			esc = `\"`
+		case '\n':
+			esc = `\n`
+		case '\r':
+			esc = `\r`
+		case '\t':
+			esc = `\t`
+		default:
+			continue
+		}
+		buf = append(buf, s[start:i]...)
+		buf = append(buf, esc...)
+		start = i + 1
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// Limits returns the limits set with WithLimits.
+func (s *TOMLSerializer) Limits() Limits {
+	return s.limits
 }
 
 func (s *TOMLSerializer) Format() string {
@@ -1,8 +1,14 @@
 package toml
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestTOMLSerializer(t *testing.T) {
@@ -115,3 +121,1004 @@ func TestTOMLComplexStructures(t *testing.T) {
 		t.Errorf("Unmarshal() = %v, want %v", result, original)
 	}
 }
+
+func TestTOMLDocumentSyntax(t *testing.T) {
+	type Server struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+	}
+	type Config struct {
+		Title   string            `toml:"title"`
+		Owner   map[string]string `toml:"owner"`
+		Servers []Server          `toml:"servers"`
+		Ports   []int             `toml:"ports"`
+		Point   struct {
+			X int `toml:"x"`
+			Y int `toml:"y"`
+		} `toml:"point"`
+	}
+
+	input := `# комментарий
+title = 'C:\path' # literal string
+
+owner.name = "Иван\tПетров"
+
+ports = [
+  8000,
+  8001, # second
+]
+point = { x = 1, y = -2 }
+
+[[servers]]
+name = "alpha"
+port = 1_000
+
+[[servers]]
+name = "beta"
+`
+
+	serializer := New()
+
+	var result Config
+	if err := serializer.Unmarshal([]byte(input), &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := Config{
+		Title:   `C:\path`,
+		Owner:   map[string]string{"name": "Иван\tПетров"},
+		Servers: []Server{{Name: "alpha", Port: 1000}, {Name: "beta"}},
+		Ports:   []int{8000, 8001},
+	}
+	want.Point.X = 1
+	want.Point.Y = -2
+
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Unmarshal() = %v, want %v", result, want)
+	}
+
+	data, err := serializer.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var roundTrip Config
+	if err := serializer.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatalf("Unmarshal() error = %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(roundTrip, want) {
+		t.Errorf("Unmarshal(Marshal()) = %v, want %v", roundTrip, want)
+	}
+}
+
+func TestTOMLSyntaxError(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"a = 1\nb = \"oops\n", 2, 5},
+		{"a = 1\nb = \n", 2, 5},
+		{"[table\nkey = 1\n", 1, 7},
+		{"\"ключ\" = 1 2\n", 1, 12},
+		{"a = 01\n", 1, 5},
+		{"a = [1, -01]\n", 1, 9},
+		{"a = 00.5\n", 1, 5},
+		{"a = 1__2\n", 1, 5},
+		{"a = _1\n", 1, 5},
+		{"a = 1_\n", 1, 5},
+		{"a = 1_.5\n", 1, 5},
+		{"a = 1.e5\n", 1, 5},
+		{"a = 0x\n", 1, 5},
+		{"a = 0xG1\n", 1, 5},
+		{"a = 0b102\n", 1, 5},
+		{"a = \"\"\"без конца\n", 1, 5},
+	}
+
+	for _, tt := range tests {
+		var result map[string]interface{}
+		err := New().Unmarshal([]byte(tt.input), &result)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Unmarshal(%q) error = %v, want *SyntaxError", tt.input, err)
+			continue
+		}
+		if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+			t.Errorf("Unmarshal(%q) позиция = %d:%d, want %d:%d", tt.input, syntaxErr.Line, syntaxErr.Column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestTOMLSpecExamples(t *testing.T) {
+	input := `str1 = """
+Roses are red
+Violets are blue"""
+str2 = """\
+       The quick brown \
+       fox jumps over \
+       the lazy dog.\
+       """
+str4 = """Here are two quotation marks: "". Simple enough."""
+str5 = """Here are three quotation marks: ""\"."""
+str7 = """"This," she said, "is just a pointless statement.""""
+lines = '''
+The first newline is
+trimmed in raw strings.
+'''
+quot15 = '''Here are fifteen quotation marks: """""""""""""""'''
+str = ''''That,' she said, 'is still pointless.''''
+hex1 = 0xDEADBEEF
+hex3 = 0xdead_beef
+oct1 = 0o01234567
+oct2 = 0o755
+bin1 = 0b11010110
+int5 = 1_000
+int6 = 5_349_221
+flt8 = 224_617.445_991_228
+flt9 = -1_0e1_0
+odt1 = 1979-05-27T07:32:00Z
+odt4 = 1979-05-27 07:32:00Z
+ldt1 = 1979-05-27 07:32:00
+`
+
+	var result map[string]interface{}
+	if err := New().Unmarshal([]byte(input), &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	date := time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)
+	want := map[string]interface{}{
+		"str1":   "Roses are red\nViolets are blue",
+		"str2":   "The quick brown fox jumps over the lazy dog.",
+		"str4":   `Here are two quotation marks: "". Simple enough.`,
+		"str5":   `Here are three quotation marks: """.`,
+		"str7":   `"This," she said, "is just a pointless statement."`,
+		"lines":  "The first newline is\ntrimmed in raw strings.\n",
+		"quot15": `Here are fifteen quotation marks: """""""""""""""`,
+		"str":    "'That,' she said, 'is still pointless.'",
+		"hex1":   int64(0xDEADBEEF),
+		"hex3":   int64(0xDEADBEEF),
+		"oct1":   int64(0o1234567),
+		"oct2":   int64(0o755),
+		"bin1":   int64(0b11010110),
+		"int5":   int64(1000),
+		"int6":   int64(5349221),
+		"flt8":   224617.445991228,
+		"flt9":   -10e10,
+		"odt1":   date,
+		"odt4":   date,
+		"ldt1":   date,
+	}
+	for key, value := range want {
+		if got := result[key]; !reflect.DeepEqual(got, value) {
+			t.Errorf("%s = %#v, want %#v", key, got, value)
+		}
+	}
+
+	doc, err := ParseDocument([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if got := doc.Bytes(); string(got) != input {
+		t.Errorf("Bytes() изменил документ:\n%s", got)
+	}
+}
+
+func TestTOMLIndent(t *testing.T) {
+	type Server struct {
+		Name    string `toml:"name"`
+		Address string `toml:"address"`
+	}
+	type Config struct {
+		Title   string   `toml:"title"`
+		Enabled bool     `toml:"enabled"`
+		Hosts   []string `toml:"hosts"`
+		Ports   []int    `toml:"ports"`
+		Server  Server   `toml:"server"`
+	}
+
+	config := Config{
+		Title:   "Пример",
+		Enabled: true,
+		Hosts:   []string{"alpha.example.com", "beta.example.com", "gamma.example.com", "delta.example.com"},
+		Ports:   []int{80, 443},
+		Server:  Server{Name: "main", Address: "10.0.0.1"},
+	}
+
+	want := `title   = "Пример"
+enabled = true
+hosts   = [
+  "alpha.example.com",
+  "beta.example.com",
+  "gamma.example.com",
+  "delta.example.com",
+]
+ports   = [80, 443]
+
+[server]
+name    = "main"
+address = "10.0.0.1"
+`
+
+	serializer := New(WithIndent("  "))
+	data, err := serializer.Marshal(config)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+
+	var result Config
+	if err := serializer.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(result, config) {
+		t.Errorf("Unmarshal() = %v, want %v", result, config)
+	}
+
+	data, err = New(WithIndent("    "), WithLineWidth(12)).Marshal(map[string][]int{"ports": {80, 443}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := "ports = [\n    80,\n    443,\n]\n"; string(data) != want {
+		t.Errorf("Marshal() с WithLineWidth =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestTOMLMapKeyOrder(t *testing.T) {
+	m := map[string]interface{}{
+		"port":   8080,
+		"host":   "localhost",
+		"limits": map[string]int{"write": 2, "read": 1},
+		"debug":  true,
+	}
+	want := "debug = true\nhost = \"localhost\"\nport = 8080\n\n[limits]\nread = 1\nwrite = 2\n"
+
+	for i := 0; i < 20; i++ {
+		data, err := New().Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != want {
+			t.Fatalf("Marshal() =\n%s\nwant\n%s", data, want)
+		}
+	}
+
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+	data, err := New(WithKeyComparator(reverse)).Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want = "port = 8080\nhost = \"localhost\"\ndebug = true\n\n[limits]\nwrite = 2\nread = 1\n"
+	if string(data) != want {
+		t.Errorf("Marshal() with comparator =\n%s\nwant\n%s", data, want)
+	}
+
+	data, err = New(WithUnsortedKeys()).Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var result map[string]interface{}
+	if err := New().Unmarshal(data, &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(result) != len(m) {
+		t.Errorf("unsorted Marshal() =\n%s", data)
+	}
+}
+
+type point struct{ X, Y int }
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *point) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func TestTOMLMapKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"int", map[int]string{2: "b", -1: "a"}, "-1 = \"a\"\n2 = \"b\"\n"},
+		{"uint8", map[uint8]bool{7: true}, "7 = true\n"},
+		{"text", map[point]int{{1, 2}: 3}, "\"1,2\" = 3\n"},
+		{"nested", map[string]map[int]int{"ports": {80: 1}}, "[ports]\n80 = 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := New().Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", data, tt.want)
+			}
+
+			result := reflect.New(reflect.TypeOf(tt.value))
+			if err := New().Unmarshal(data, result.Interface()); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.Elem().Interface(), tt.value) {
+				t.Errorf("Unmarshal() = %v, want %v", result.Elem(), tt.value)
+			}
+		})
+	}
+
+	var small map[int8]string
+	if err := New().Unmarshal([]byte("300 = \"x\"\n"), &small); err == nil {
+		t.Error("ожидалась ошибка для ключа вне диапазона int8")
+	}
+	if _, err := New().Marshal(map[float64]int{1.5: 1}); err == nil {
+		t.Error("ожидалась ошибка для ключа типа float64")
+	}
+}
+
+func TestTOMLExponents(t *testing.T) {
+	var config struct {
+		Scale   float64 `toml:"scale"`
+		Small   float64 `toml:"small"`
+		Timeout int     `toml:"timeout"`
+	}
+	input := "scale = 1e3\nsmall = 5E-3\ntimeout = 3e1\n"
+	if err := New().Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if config.Scale != 1000 || config.Small != 0.005 || config.Timeout != 30 {
+		t.Errorf("Unmarshal() = %+v", config)
+	}
+
+	var generic map[string]interface{}
+	if err := New().Unmarshal([]byte("a = 2e2\nb = 200\n"), &generic); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if generic["a"] != float64(200) || generic["b"] != int64(200) {
+		t.Errorf("Unmarshal() = %#v", generic)
+	}
+
+	if err := New().Unmarshal([]byte("timeout = 1.5\n"), &config); err == nil {
+		t.Error("ожидалась ошибка для дробного значения в int")
+	}
+}
+
+func TestTOMLTypeError(t *testing.T) {
+	type Server struct {
+		Port   uint16  `toml:"port"`
+		Weight int8    `toml:"weight"`
+		Load   float32 `toml:"load"`
+		Name   string  `toml:"name"`
+	}
+	type Config struct {
+		Servers []Server         `toml:"servers"`
+		Limits  map[string]uint8 `toml:"limits"`
+	}
+
+	tests := []struct {
+		input string
+		value string
+		field string
+	}{
+		{"[[servers]]\nport = 70000\n", "integer 70000", "servers.0.port"},
+		{"[[servers]]\n[[servers]]\nport = -1\n", "integer -1", "servers.1.port"},
+		{"[[servers]]\nweight = 2.5\n", "float 2.5", "servers.0.weight"},
+		{"[[servers]]\nload = 1e39\n", "float 1e+39", "servers.0.load"},
+		{"[[servers]]\nname = 1\n", "integer 1", "servers.0.name"},
+		{"[limits]\nconn = 256\n", "integer 256", "limits.conn"},
+		{"servers = 1\n", "integer 1", "servers"},
+		{"[[servers]]\nport = 99999999999999999999\n", "integer 99999999999999999999", "servers.0.port"},
+		{"limits = {a = [1, 99999999999999999999]}\n", "integer 99999999999999999999", "limits.a.1"},
+	}
+	for _, tt := range tests {
+		var config Config
+		err := New().Unmarshal([]byte(tt.input), &config)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("Unmarshal(%q) error = %v, want *TypeError", tt.input, err)
+			continue
+		}
+		if typeErr.Value != tt.value || typeErr.Field != tt.field {
+			t.Errorf("Unmarshal(%q) = %+v, want value %q, field %q", tt.input, typeErr, tt.value, tt.field)
+		}
+	}
+
+	// Целые вне int64 отвергаются ещё при разборе, с позицией литерала.
+	var generic map[string]interface{}
+	err := New().Unmarshal([]byte("a = 1\nb = 9223372036854775808\n"), &generic)
+	var typeErr *TypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "b" || typeErr.Line != 2 || typeErr.Column != 5 {
+		t.Errorf("Unmarshal() error = %+v, want TypeError for b at 2:5", err)
+	}
+}
+
+func TestTOMLFloats(t *testing.T) {
+	type config struct {
+		Ratio float32 `toml:"ratio"`
+		Whole float64 `toml:"whole"`
+		Huge  float64 `toml:"huge"`
+		Tiny  float64 `toml:"tiny"`
+		Max   float64 `toml:"max"`
+		Min   float64 `toml:"min"`
+		Bad   float64 `toml:"bad"`
+	}
+	in := config{Ratio: 0.1, Whole: 2, Huge: 1e21, Tiny: 1.5e-7, Max: math.Inf(1), Min: math.Inf(-1), Bad: math.NaN()}
+
+	data, err := New().Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "ratio = 0.1\nwhole = 2.0\nhuge = 1e+21\ntiny = 1.5e-07\nmax = inf\nmin = -inf\nbad = nan\n"
+	if string(data) != want {
+		t.Errorf("Marshal() = %q, want %q", data, want)
+	}
+
+	var out config
+	if err := New().Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !math.IsNaN(out.Bad) {
+		t.Errorf("Unmarshal() bad = %v, want NaN", out.Bad)
+	}
+	out.Bad, in.Bad = 0, 0
+	if out != in {
+		t.Errorf("Unmarshal() = %+v, want %+v", out, in)
+	}
+
+	var generic map[string]interface{}
+	if err := New().Unmarshal([]byte("a = +inf\nb = 2.0\ninf = 1\n"), &generic); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if generic["a"] != math.Inf(1) || generic["b"] != float64(2) || generic["inf"] != int64(1) {
+		t.Errorf("Unmarshal() = %#v", generic)
+	}
+}
+
+type Base struct {
+	ID   int    `toml:"id"`
+	Name string `toml:"name"`
+}
+
+type Audit struct {
+	Created string `toml:"created"`
+}
+
+func TestTOMLEmbedded(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host"`
+	}
+	type Item struct {
+		Base
+		*Audit
+		Name   string `toml:"name"`
+		Server Server `toml:",inline"`
+	}
+
+	item := Item{Base: Base{ID: 1, Name: "base"}, Name: "item", Server: Server{Host: "localhost"}}
+	data, err := New().Marshal(item)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := "id = 1\nname = \"item\"\nhost = \"localhost\"\n"; string(data) != want {
+		t.Errorf("Marshal() = %q, want %q", data, want)
+	}
+
+	var decoded Item
+	if err := New().Unmarshal([]byte("id = 2\ncreated = \"today\"\nhost = \"example.com\"\n"), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.ID != 2 || decoded.Audit == nil || decoded.Created != "today" || decoded.Server.Host != "example.com" {
+		t.Errorf("Unmarshal() = %+v", decoded)
+	}
+
+	var typeErr *TypeError
+	err = New().Unmarshal([]byte("id = \"x\"\n"), &decoded)
+	if !errors.As(err, &typeErr) || typeErr.Field != "id" {
+		t.Errorf("Unmarshal() error = %v, want TypeError for id", err)
+	}
+}
+
+type window struct{ From, To int }
+
+func (w window) IsZero() bool { return w.From == w.To }
+
+func TestTOMLTagOptions(t *testing.T) {
+	type Options struct {
+		Name    string   `toml:"name,omitempty"`
+		Tags    []string `toml:"tags,omitempty"`
+		Window  window   `toml:"window,omitzero"`
+		Count   int64    `toml:"count,string"`
+		Enabled bool     `toml:"enabled,string,omitempty"`
+	}
+
+	data, err := New().Marshal(Options{Window: window{3, 3}, Count: 42})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := "count = \"42\"\n"; string(data) != want {
+		t.Errorf("Marshal() = %q, want %q", data, want)
+	}
+
+	var decoded Options
+	if err := New().Unmarshal([]byte("count = \"-7\"\nenabled = \"true\"\n"), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Count != -7 || !decoded.Enabled {
+		t.Errorf("Unmarshal() = %+v", decoded)
+	}
+	var typeErr *TypeError
+	err = New().Unmarshal([]byte("count = \"7 apples\"\n"), &decoded)
+	if !errors.As(err, &typeErr) || typeErr.Field != "count" {
+		t.Errorf("Unmarshal() error = %v, want TypeError for count", err)
+	}
+
+	type Server struct {
+		Host string `toml:"host,required"`
+		Port int    `toml:"port"`
+	}
+	type Config struct {
+		Servers []Server `toml:"servers"`
+	}
+	var config Config
+	err = New().Unmarshal([]byte("[[servers]]\nhost = \"a\"\n\n[[servers]]\nport = 80\n"), &config)
+	var missing *MissingFieldError
+	if !errors.As(err, &missing) || missing.Field != "servers.1.host" {
+		t.Errorf("Unmarshal() error = %v, want missing servers.1.host", err)
+	}
+}
+
+func TestTOMLStrict(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host"`
+	}
+	type Config struct {
+		Name    string   `toml:"name"`
+		Servers []Server `toml:"servers"`
+	}
+
+	input := "name = \"a\"\n\n[[servers]]\nhost = \"h\"\nport = 80\n"
+	var config Config
+	if err := New().Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	var unknown *UnknownFieldError
+	err := New(Strict()).Unmarshal([]byte(input), &config)
+	if !errors.As(err, &unknown) || unknown.Field != "servers.0.port" {
+		t.Errorf("Unmarshal() error = %v, want unknown servers.0.port", err)
+	}
+
+	duplicates := []string{
+		"name = \"a\"\nname = \"b\"\n",
+		"[server]\nhost = \"a\"\n[server]\nport = 1\n",
+		"server = { host = \"a\", host = \"b\" }\n",
+	}
+	for _, input := range duplicates {
+		var generic map[string]interface{}
+		var syntaxErr *SyntaxError
+		if err := New(Strict()).Unmarshal([]byte(input), &generic); !errors.As(err, &syntaxErr) {
+			t.Errorf("Unmarshal(%q) error = %v, want SyntaxError", input, err)
+		}
+		if err := New().Unmarshal([]byte(input), &generic); err != nil {
+			t.Errorf("Unmarshal(%q) без Strict error = %v", input, err)
+		}
+	}
+
+	var generic map[string]interface{}
+	if err := New(Strict()).Unmarshal([]byte("[[a]]\nx = 1\n[[a]]\nx = 2\n[b.c]\n[b]\n"), &generic); err != nil {
+		t.Errorf("Unmarshal() error = %v", err)
+	}
+}
+
+func TestTOMLFieldMatching(t *testing.T) {
+	type User struct {
+		UserID int    `toml:"userId" alias:"user_id,uid"`
+		Name   string `toml:"name"`
+	}
+
+	inputs := []string{
+		"userId = 5\nname = \"a\"\n",
+		"UserID = 5\nNAME = \"a\"\n",
+		"user_id = 5\nname = \"a\"\n",
+		"UID = 5\nName = \"a\"\n",
+	}
+	for _, input := range inputs {
+		var u User
+		if err := New(Strict()).Unmarshal([]byte(input), &u); err != nil {
+			t.Errorf("Unmarshal(%q) error = %v", input, err)
+			continue
+		}
+		if u.UserID != 5 || u.Name != "a" {
+			t.Errorf("Unmarshal(%q) = %+v", input, u)
+		}
+	}
+
+	// Точное имя побеждает, даже если ключ без учёта регистра идёт раньше.
+	var u User
+	if err := New().Unmarshal([]byte("Name = \"x\"\nname = \"a\"\n"), &u); err != nil || u.Name != "a" {
+		t.Errorf("Unmarshal() = %+v, %v", u, err)
+	}
+
+	var config struct {
+		Users []User `toml:"users"`
+	}
+	var ambiguousErr *AmbiguousFieldError
+	err := New(Strict()).Unmarshal([]byte("[[users]]\nuserId = 1\nuser_id = 2\n"), &config)
+	if !errors.As(err, &ambiguousErr) || ambiguousErr.Field != "users.0.user_id" {
+		t.Errorf("Unmarshal() error = %v, want ambiguous users.0.user_id", err)
+	}
+}
+
+func TestTOMLMerge(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	}
+	type Config struct {
+		Name    string                 `toml:"name"`
+		Labels  map[string]string      `toml:"labels"`
+		Servers []Server               `toml:"servers"`
+		Tags    []string               `toml:"tags"`
+		Extra   map[string]interface{} `toml:"extra"`
+	}
+	defaults := func() Config {
+		return Config{
+			Name:    "default",
+			Labels:  map[string]string{"env": "dev", "team": "core"},
+			Servers: []Server{{Host: "a", Port: 80}, {Host: "b", Port: 81}},
+			Tags:    []string{"x"},
+			Extra:   map[string]interface{}{"db": map[string]interface{}{"user": "root", "pool": int64(4)}},
+		}
+	}
+	input := "tags = [\"y\"]\n\n[labels]\nenv = \"prod\"\n\n[extra.db]\npool = 8\n\n[[servers]]\nport = 8080\n"
+
+	config := defaults()
+	if err := New(WithMerge(MergeSliceElements)).Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Config{
+		Name:    "default",
+		Labels:  map[string]string{"env": "prod", "team": "core"},
+		Servers: []Server{{Host: "a", Port: 8080}, {Host: "b", Port: 81}},
+		Tags:    []string{"y"},
+		Extra:   map[string]interface{}{"db": map[string]interface{}{"user": "root", "pool": int64(8)}},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", config, want)
+	}
+
+	config = defaults()
+	if err := New(WithMerge(AppendSlices)).Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(config.Servers) != 3 || config.Servers[2] != (Server{Port: 8080}) || !reflect.DeepEqual(config.Tags, []string{"x", "y"}) {
+		t.Errorf("Unmarshal() с AppendSlices = %+v", config)
+	}
+
+	config = defaults()
+	if err := New().Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(config.Labels) != 1 || len(config.Servers) != 1 || config.Servers[0].Host != "" {
+		t.Errorf("Unmarshal() без WithMerge = %+v", config)
+	}
+}
+
+func TestTOMLArrays(t *testing.T) {
+	type Point struct {
+		Coords [3]int    `toml:"coords"`
+		Grid   [2][2]int `toml:"grid"`
+		Ptr    uintptr   `toml:"ptr"`
+		Names  [2]string `toml:"names"`
+	}
+	in := Point{Coords: [3]int{1, 2, 3}, Grid: [2][2]int{{1, 2}, {3, 4}}, Ptr: 7, Names: [2]string{"a", "b"}}
+
+	data, err := New().Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var out Point
+	if err := New().Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out != in {
+		t.Errorf("Unmarshal() = %+v, want %+v", out, in)
+	}
+
+	for _, input := range []string{"coords = [1, 2]\n", "coords = [1, 2, 3, 4]\n"} {
+		var p Point
+		err := New().Unmarshal([]byte(input), &p)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) || typeErr.Field != "coords" {
+			t.Errorf("Unmarshal(%q) error = %v, want TypeError for coords", input, err)
+		}
+	}
+
+	var p Point
+	p.Coords = [3]int{9, 9, 9}
+	if err := New(WithArrayLength(ArrayTruncate)).Unmarshal([]byte("coords = [1, 2]\nnames = [\"x\", \"y\", \"z\"]\n"), &p); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if p.Coords != [3]int{1, 2, 0} || p.Names != [2]string{"x", "y"} {
+		t.Errorf("Unmarshal() с ArrayTruncate = %+v", p)
+	}
+}
+
+func TestTOMLComplex(t *testing.T) {
+	type Signal struct {
+		Z complex128 `toml:"z"`
+		W complex64  `toml:"w"`
+	}
+	in := Signal{Z: complex(1.5, -2), W: complex(0, 1)}
+
+	if _, err := New().Marshal(in); err == nil {
+		t.Error("Marshal() без WithComplexFormat должен вернуть ошибку")
+	}
+
+	tests := []struct {
+		format ComplexFormat
+		want   string
+	}{
+		{ComplexString, "z = \"1.5-2i\"\nw = \"0+1i\"\n"},
+		{ComplexArray, "z = [1.5, -2.0]\nw = [0.0, 1.0]\n"},
+	}
+	for _, tt := range tests {
+		s := New(WithComplexFormat(tt.format))
+		data, err := s.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal() = %q, want %q", data, tt.want)
+		}
+		var out Signal
+		if err := s.Unmarshal([]byte(tt.want), &out); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if out != in {
+			t.Errorf("Unmarshal(%q) = %+v, want %+v", tt.want, out, in)
+		}
+	}
+
+	for _, input := range []string{"z = [1]\n", "z = \"abc\"\n", "z = [1, \"a\"]\n"} {
+		var out Signal
+		err := New(WithComplexFormat(ComplexArray)).Unmarshal([]byte(input), &out)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("Unmarshal(%q) error = %v, want TypeError", input, err)
+		}
+	}
+}
+
+func TestTOMLCycles(t *testing.T) {
+	type Node struct {
+		Name     string         `toml:"name"`
+		Next     *Node          `toml:"next"`
+		Children []any          `toml:"children"`
+		Meta     map[string]any `toml:"meta"`
+	}
+
+	self := &Node{Name: "a"}
+	self.Next = &Node{Name: "b", Next: self}
+
+	list := &Node{Name: "list"}
+	list.Children = []any{1, nil}
+	list.Children[1] = list.Children
+
+	meta := map[string]any{}
+	meta["self"] = meta
+	withMap := &Node{Name: "map", Meta: meta}
+
+	tests := []struct {
+		name  string
+		value any
+		field string
+	}{
+		{"указатель", self, "next.next"},
+		{"срез", list, "children.1"},
+		{"map", withMap, "meta.self"},
+	}
+	for _, tt := range tests {
+		_, err := New().Marshal(tt.value)
+		var cycleErr *CycleError
+		if !errors.As(err, &cycleErr) || cycleErr.Field != tt.field {
+			t.Errorf("%s: Marshal() error = %v, want cycle at %s", tt.name, err, tt.field)
+		}
+	}
+
+	shared := &Node{Name: "shared"}
+	if _, err := New().Marshal(map[string]*Node{"a": shared, "b": shared}); err != nil {
+		t.Errorf("Marshal() error = %v", err)
+	}
+
+	// Длинная цепочка: открытые значения глубже первых нескольких
+	// отслеживаются отдельно, цикл в глубине тоже должен находиться.
+	s := New()
+	head := &Node{Name: "0"}
+	tail := head
+	for i := 1; i < 100; i++ {
+		tail.Next = &Node{Name: strconv.Itoa(i)}
+		tail = tail.Next
+	}
+	tail.Next = head.Next.Next
+	var cycleErr *CycleError
+	if _, err := s.Marshal(head); !errors.As(err, &cycleErr) || strings.Count(cycleErr.Field, "next") != 100 {
+		t.Errorf("Marshal() error = %v, want cycle 100 levels deep", err)
+	}
+	tail.Next = nil
+	if _, err := s.Marshal(head); err != nil {
+		t.Errorf("Marshal() после цикла error = %v", err)
+	}
+}
+
+func TestTOMLMaxDepth(t *testing.T) {
+	var v map[string]any
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"массивы", "a = " + strings.Repeat("[", 10) + strings.Repeat("]", 10) + "\n"},
+		{"встроенные таблицы", "a = " + strings.Repeat("{ b = ", 10) + "1" + strings.Repeat(" }", 10) + "\n"},
+		{"составной ключ", strings.Repeat("a.", 10) + "b = 1\n"},
+		{"заголовок", "[" + strings.Repeat("a.", 10) + "b]\n"},
+	}
+	for _, tt := range tests {
+		if err := New().Unmarshal([]byte(tt.input), &v); err != nil {
+			t.Errorf("%s: Unmarshal() error = %v", tt.name, err)
+		}
+		var limitErr *LimitError
+		if err := New(WithMaxDepth(5)).Unmarshal([]byte(tt.input), &v); !errors.As(err, &limitErr) || limitErr.Limit != "depth" {
+			t.Errorf("%s: Unmarshal() error = %v, want depth limit", tt.name, err)
+		}
+	}
+
+	type Tree struct {
+		Items []any `toml:"items"`
+	}
+	if _, err := New(WithMaxDepth(3)).Marshal(Tree{Items: []any{[]any{1}}}); err != nil {
+		t.Errorf("Marshal() error = %v", err)
+	}
+	_, err := New(WithMaxDepth(3)).Marshal(Tree{Items: []any{[]any{[]any{1}}}})
+	var depthErr *DepthError
+	if !errors.As(err, &depthErr) || depthErr.Field != "items.0.0" || depthErr.Depth != 3 {
+		t.Errorf("Marshal() error = %v, want DepthError at items.0.0", err)
+	}
+}
+
+func TestTOMLLimits(t *testing.T) {
+	limits := Limits{
+		MaxInputSize:    100,
+		MaxStringLength: 5,
+		MaxElements:     3,
+		MaxAllocations:  6,
+	}
+	tests := []struct {
+		input string
+		limit string
+	}{
+		{"a = \"" + strings.Repeat("a", 100) + "\"\n", "input size"},
+		{"a = [\"abcdef\"]\n", "string length"},
+		{"abcdef = 1\n", "string length"},
+		{"\"abcdef\" = 1\n", "string length"},
+		{"a = [1, 2, 3, 4]\n", "elements"},
+		{"a = 1\nb = 2\nc = 3\nd = 4\n", "elements"},
+		{"a = { b = 1, c = 2, d = 3, e = 4 }\n", "elements"},
+		{"[[a]]\n[[a]]\n[[a]]\n[[a]]\n", "elements"},
+		{"a = [[1, 2], [3, 4], [5]]\n", "allocations"},
+	}
+	s := New(WithLimits(limits))
+	for _, tt := range tests {
+		var v map[string]any
+		err := s.Unmarshal([]byte(tt.input), &v)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tt.limit || !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Unmarshal(%.20q) error = %v, want %s limit", tt.input, err, tt.limit)
+		}
+	}
+
+	type Config struct {
+		Name string `toml:"name"`
+		Tags []int  `toml:"tags"`
+	}
+	var config Config
+	if err := s.Unmarshal([]byte("name = \"abcde\"\ntags = [1, 2, 3]\n"), &config); err != nil {
+		t.Errorf("Unmarshal() в пределах ограничений error = %v", err)
+	}
+	err := s.Unmarshal([]byte("tags = [1]\nname = \"abcdef\"\n"), &config)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Line != 2 || limitErr.Column != 8 {
+		t.Errorf("Unmarshal() error = %v, want string length limit at line 2, column 8", err)
+	}
+
+	var v map[string]any
+	if err := New(WithMaxDepth(50), WithLimits(Limits{MaxDepth: 2})).Unmarshal([]byte("a = [[1]]\n"), &v); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Unmarshal() error = %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestTOMLCollectErrors(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host,required"`
+		Port int    `toml:"port"`
+	}
+	type Config struct {
+		Timeout int               `toml:"timeout" alias:"wait"`
+		Owner   struct{ Age int } `toml:"owner"`
+		Limits  map[string]int    `toml:"limits"`
+		Tags    []int             `toml:"tags"`
+		Servers []Server          `toml:"servers"`
+		Debug   bool              `toml:"debug"`
+	}
+	input := `wait = "5s"
+owner.age = "old"
+limits = { cpu = 2, mem = "1G" }
+tags = [1, "two", 3]
+debug = true
+
+[[servers]]
+host = "a"
+port = 1
+
+[[servers]]
+port = "x"
+`
+	config := Config{Timeout: 30}
+	err := New(CollectErrors()).Unmarshal([]byte(input), &config)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("Unmarshal() error = %v, want *MultiError", err)
+	}
+	want := []struct {
+		field        string
+		line, column int
+	}{
+		{"timeout", 1, 1},
+		{"owner.Age", 2, 1},
+		{"limits.mem", 3, 21},
+		{"tags.1", 4, 12},
+		{"servers.1.host", 11, 1},
+		{"servers.1.port", 12, 1},
+	}
+	if len(multi.Errors) != len(want) {
+		t.Fatalf("Errors = %v, want %d errors", multi.Errors, len(want))
+	}
+	for i, w := range want {
+		var field string
+		var line, column int
+		switch e := multi.Errors[i].(type) {
+		case *TypeError:
+			field, line, column = e.Field, e.Line, e.Column
+		case *MissingFieldError:
+			field, line, column = e.Field, e.Line, e.Column
+		}
+		if field != w.field || line != w.line || column != w.column {
+			t.Errorf("Errors[%d] = %v at %d:%d, want %s at %d:%d", i, multi.Errors[i], line, column, w.field, w.line, w.column)
+		}
+	}
+
+	// Поля с ошибками обнулены, остальные декодированы.
+	if config.Timeout != 0 || !config.Debug || config.Limits["cpu"] != 2 || fmt.Sprint(config.Tags) != "[1 0 3]" ||
+		config.Servers[0] != (Server{Host: "a", Port: 1}) || config.Servers[1] != (Server{}) {
+		t.Errorf("config = %+v", config)
+	}
+
+	// В строгом режиме собираются и неизвестные поля.
+	err = New(Strict(), CollectErrors()).Unmarshal([]byte("debug = 1\n\n[owner]\nname = \"x\"\n"), &config)
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("Unmarshal() error = %v, want 2 errors", err)
+	}
+	var unknown *UnknownFieldError
+	if !errors.As(multi.Errors[0], &unknown) || unknown.Field != "owner.name" || unknown.Line != 4 {
+		t.Errorf("Errors[0] = %v, want unknown field owner.name on line 4", multi.Errors[0])
+	}
+
+	// Без CollectErrors позиции не заполняются.
+	err = New().Unmarshal([]byte("debug = 1\n"), &config)
+	var typeErr *TypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "debug" || typeErr.Line != 0 {
+		t.Errorf("Unmarshal() error = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+// Migration upgrades a decoded document by exactly one schema version.
+type Migration func(doc map[string]any) error
+
+// VersionedSerializer stores a schema version in every payload and runs the
+// registered migrations on older documents before decoding them into the
+// current struct. Documents without the version field are treated as
+// version 0.
+type VersionedSerializer struct {
+	inner   Serializer
+	field   string
+	current int
+
+	mu         sync.RWMutex
+	migrations map[int]Migration
+}
+
+func Versioned(inner Serializer, field string, current int) *VersionedSerializer {
+	return &VersionedSerializer{
+		inner:      inner,
+		field:      field,
+		current:    current,
+		migrations: make(map[int]Migration),
+	}
+}
+
+func NewVersioned(format string, field string, current int) (*VersionedSerializer, error) {
+	s, err := New(format)
+	if err != nil {
+		return nil, err
+	}
+	return Versioned(s, field, current), nil
+}
+
+// Register sets the migration from version from to version from+1.
+func (s *VersionedSerializer) Register(from int, m Migration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.migrations[from] = m
+}
+
+func (s *VersionedSerializer) migration(from int) (Migration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.migrations[from]
+	return m, ok
+}
+
+func (s *VersionedSerializer) Marshal(v any) ([]byte, error) {
+	data, err := s.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := s.inner.Unmarshal(data, &doc); err != nil || doc == nil {
+		return nil, fmt.Errorf("versioned payload must be an object, got %T", v)
+	}
+	_, present := doc[s.field]
+	if payload, ok := s.splice(data, present); ok {
+		return payload, nil
+	}
+	doc[s.field] = int64(s.current)
+	return s.inner.Marshal(doc)
+}
+
+// splice writes the version field in front of the keys of the encoded
+// object, replacing one v already has, so that types with their own
+// Marshaler keep their encoding. It only knows the JSON and TOML
+// serializers of this module; for others the payload is re-encoded from
+// its decoded document.
+func (s *VersionedSerializer) splice(data []byte, present bool) ([]byte, bool) {
+	header, err := s.inner.Marshal(map[string]any{s.field: int64(s.current)})
+	if err != nil {
+		return nil, false
+	}
+
+	switch s.inner.(type) {
+	case *toml.TOMLSerializer:
+		if present {
+			doc, err := toml.ParseDocument(data)
+			if err != nil || doc.Delete(s.field) != nil {
+				return nil, false
+			}
+			data = doc.Bytes()
+		}
+		return append(header, data...), true
+	case *json.JSONSerializer:
+		if present {
+			key, err := s.inner.Marshal(s.field)
+			if err != nil {
+				return nil, false
+			}
+			data = dropMember(data, key)
+		}
+		header = bytes.TrimRight(header, " \t\r\n")
+		body := bytes.TrimLeft(data, " \t\r\n")
+		if len(body) == 0 || body[0] != '{' || header[len(header)-1] != '}' {
+			return nil, false
+		}
+		header = bytes.TrimRight(header[:len(header)-1], " \t\r\n")
+		if rest := bytes.TrimLeft(body[1:], " \t\r\n"); len(rest) > 0 && rest[0] != '}' {
+			header = append(header, ',')
+		}
+		return append(header, body[1:]...), true
+	}
+	return nil, false
+}
+
+// dropMember removes the member with the encoded key from the top-level
+// JSON object data, along with the comma separating it from the others.
+func dropMember(data, key []byte) []byte {
+	depth, delim, match := 0, 0, false
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			end := i + 1
+			for ; end < len(data) && data[end] != '"'; end++ {
+				if data[end] == '\\' {
+					end++
+				}
+			}
+			if depth == 1 && len(bytes.TrimSpace(data[delim+1:i])) == 0 && bytes.Equal(data[i:min(end+1, len(data))], key) {
+				match = true
+			}
+			i = end
+		case '{', '[':
+			depth++
+			if depth == 1 {
+				delim = i
+			}
+		case ',':
+			if depth == 1 {
+				if match {
+					return append(data[:delim+1:delim+1], data[i+1:]...)
+				}
+				delim = i
+			}
+		case '}', ']':
+			if depth == 1 && match {
+				if data[delim] == ',' {
+					return append(data[:delim:delim], data[i:]...)
+				}
+				return append(data[:delim+1:delim+1], data[i:]...)
+			}
+			depth--
+		}
+	}
+	return data
+}
+
+func (s *VersionedSerializer) Unmarshal(data []byte, v any) error {
+	var doc map[string]any
+	if err := s.inner.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	version, err := s.version(doc)
+	if err != nil {
+		return err
+	}
+	if version > s.current {
+		return fmt.Errorf("%w: %d is newer than %d", ErrNewerVersion, version, s.current)
+	}
+	accepts := s.accepts(v)
+	if version == s.current && accepts {
+		return s.inner.Unmarshal(data, v)
+	}
+
+	for ; version < s.current; version++ {
+		m, ok := s.migration(version)
+		if !ok {
+			return fmt.Errorf("%w: from %d to %d", ErrNoMigration, version, version+1)
+		}
+		if err := m(doc); err != nil {
+			return fmt.Errorf("migration from %d to %d: %w", version, version+1, err)
+		}
+	}
+	if accepts {
+		doc[s.field] = int64(s.current)
+	} else {
+		delete(doc, s.field)
+	}
+
+	migrated, err := s.inner.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return s.inner.Unmarshal(migrated, v)
+}
+
+// accepts reports whether the type v points to takes the version field. A
+// strict inner serializer rejects it for types without such a field, so
+// they are decoded from the document with the field removed.
+func (s *VersionedSerializer) accepts(v any) bool {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return true
+	}
+	probe, err := s.inner.Marshal(map[string]any{s.field: int64(s.current)})
+	if err != nil {
+		return true
+	}
+
+	err = s.inner.Unmarshal(probe, reflect.New(rv.Type().Elem()).Interface())
+	var jsonErr *json.UnknownFieldError
+	var tomlErr *toml.UnknownFieldError
+	return !(errors.As(err, &jsonErr) && jsonErr.Field == s.field || errors.As(err, &tomlErr) && tomlErr.Field == s.field)
+}
+
+func (s *VersionedSerializer) version(doc map[string]any) (int, error) {
+	switch v := doc[s.field].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		if v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid payload version %v", doc[s.field])
+}
+
+func (s *VersionedSerializer) Format() string {
+	return s.inner.Format()
+}
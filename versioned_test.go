@@ -0,0 +1,182 @@
+package serializer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/saneechka/serializer/json"
+	"github.com/saneechka/serializer/toml"
+)
+
+type versionedUser struct {
+	Version  int    `json:"version" toml:"version"`
+	FullName string `json:"full_name" toml:"full_name"`
+	Email    string `json:"email" toml:"email"`
+}
+
+func newUserSerializer(t *testing.T, format string) *VersionedSerializer {
+	t.Helper()
+	s, err := NewVersioned(format, "version", 2)
+	if err != nil {
+		t.Fatalf("NewVersioned() error = %v", err)
+	}
+
+	// 0 -> 1: "name" renamed to "full_name"
+	s.Register(0, func(doc map[string]any) error {
+		doc["full_name"] = doc["name"]
+		delete(doc, "name")
+		return nil
+	})
+	// 1 -> 2: "mail" renamed to "email"
+	s.Register(1, func(doc map[string]any) error {
+		doc["email"] = doc["mail"]
+		delete(doc, "mail")
+		return nil
+	})
+	return s
+}
+
+func TestVersionedMigrations(t *testing.T) {
+	tests := []struct {
+		format string
+		v0     string
+		v1     string
+		v2     string
+	}{
+		{"json", `{"name":"Иван","mail":"ivan@example.com"}`, `{"version":1,"full_name":"Иван","mail":"ivan@example.com"}`, `{"version":2,"full_name":"Иван"`},
+		{"toml", "name = \"Иван\"\nmail = \"ivan@example.com\"\n", "version = 1\nfull_name = \"Иван\"\nmail = \"ivan@example.com\"\n", "version = 2\nfull_name = \"Иван\"\n"},
+	}
+
+	want := versionedUser{Version: 2, FullName: "Иван", Email: "ivan@example.com"}
+
+	for _, tt := range tests {
+		s := newUserSerializer(t, tt.format)
+
+		for _, payload := range []string{tt.v0, tt.v1} {
+			var result versionedUser
+			if err := s.Unmarshal([]byte(payload), &result); err != nil {
+				t.Fatalf("%s: Unmarshal() error = %v", tt.format, err)
+			}
+			if result != want {
+				t.Errorf("%s: Unmarshal() = %v, want %v", tt.format, result, want)
+			}
+		}
+
+		data, err := s.Marshal(versionedUser{FullName: "Иван", Email: "ivan@example.com"})
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", tt.format, err)
+		}
+
+		if !strings.HasPrefix(string(data), tt.v2) {
+			t.Errorf("%s: Marshal() = %q, want prefix %q", tt.format, data, tt.v2)
+		}
+		var result versionedUser
+		if err := s.Unmarshal(data, &result); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", tt.format, err)
+		}
+		if result != want {
+			t.Errorf("%s: Marshal() не записал версию: %s", tt.format, data)
+		}
+
+		data, err = s.Marshal(map[string]any{"full_name": "Иван", "email": "ivan@example.com"})
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", tt.format, err)
+		}
+		result = versionedUser{}
+		if err := s.Unmarshal(data, &result); err != nil || result != want {
+			t.Errorf("%s: Unmarshal(Marshal(map)) = %v, %v, want %v", tt.format, result, err, want)
+		}
+	}
+}
+
+type versionedPoint struct {
+	X, Y int
+}
+
+func (p versionedPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"x":%d,"y":%d}`, p.X, p.Y)), nil
+}
+
+type versionedName struct {
+	Name string `json:"name" toml:"name"`
+}
+
+func TestVersionedInnerSerializers(t *testing.T) {
+	data, err := Versioned(json.New(), "version", 1).Marshal(versionedPoint{X: 1, Y: 2})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"version":1,"x":1,"y":2}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	inners := []Serializer{
+		json.New(json.Strict()),
+		json.New(json.Strict(), json.UseNumber(), json.WithIndent("  ")),
+		toml.New(toml.Strict()),
+	}
+	for _, inner := range inners {
+		s := Versioned(inner, "version", 1)
+		s.Register(0, func(doc map[string]any) error {
+			doc["name"] = strings.ToUpper(doc["name"].(string))
+			return nil
+		})
+
+		data, err := s.Marshal(versionedName{Name: "ivan"})
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", inner.Format(), err)
+		}
+		var result versionedName
+		if err := s.Unmarshal(data, &result); err != nil || result.Name != "ivan" {
+			t.Errorf("%s: Unmarshal(%s) = %v, %v, want ivan", inner.Format(), data, result, err)
+		}
+
+		old, err := inner.Marshal(versionedName{Name: "ivan"})
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", inner.Format(), err)
+		}
+		result = versionedName{}
+		if err := s.Unmarshal(old, &result); err != nil || result.Name != "IVAN" {
+			t.Errorf("%s: Unmarshal(%s) = %v, %v, want IVAN", inner.Format(), old, result, err)
+		}
+
+		var withVersion struct {
+			Version int    `json:"version" toml:"version"`
+			Name    string `json:"name" toml:"name"`
+		}
+		if err := s.Unmarshal(data, &withVersion); err != nil || withVersion.Version != 1 {
+			t.Errorf("%s: Unmarshal() версия = %d, %v, want 1", inner.Format(), withVersion.Version, err)
+		}
+	}
+}
+
+func TestVersionedErrors(t *testing.T) {
+	s, err := NewVersioned("json", "version", 2)
+	if err != nil {
+		t.Fatalf("NewVersioned() error = %v", err)
+	}
+	s.Register(0, func(doc map[string]any) error { return nil })
+
+	var result versionedUser
+	err = s.Unmarshal([]byte(`{"version":1}`), &result)
+	if !errors.Is(err, ErrNoMigration) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrNoMigration)
+	}
+
+	err = s.Unmarshal([]byte(`{"version":3}`), &result)
+	if !errors.Is(err, ErrNewerVersion) || errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrNewerVersion)
+	}
+
+	if _, err := s.Marshal([]int{1}); err == nil {
+		t.Errorf("Marshal() не объекта: ожидалась ошибка")
+	}
+
+	s.Register(1, func(doc map[string]any) error { return errors.New("broken") })
+	err = s.Unmarshal([]byte(`{"version":1}`), &result)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Unmarshal() error = %v, want migration error", err)
+	}
+}